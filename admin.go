@@ -0,0 +1,224 @@
+package rmhttp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ADMIN CONFIG
+// ------------------------------------------------------------------------------------------------
+
+// The AdminConfig configures an optional admin server, which serves operational endpoints on a
+// separate listener, away from public traffic. The admin server is only started if Port is set.
+//
+// The admin server serves the following endpoints -
+//
+//	/healthz       Liveness. Always 200 while the process is running.
+//	/readyz        Readiness. 200 while the main server is serving, and 503 before it has started
+//	               or once it has begun draining.
+//	/debug/pprof/  The net/http/pprof profiles.
+//	/debug/vars    The expvar variables.
+//	/routes        The App's route table, as JSON.
+//	/config        The App's effective settings, with secrets redacted, as JSON.
+//
+// Each of the optional endpoints can be disabled.
+type AdminConfig struct {
+	Port          int    `env:"PORT"`
+	Host          string `env:"HOST"`
+	DisablePprof  bool   `env:"DISABLE_PPROF"`
+	DisableExpvar bool   `env:"DISABLE_EXPVAR"`
+	DisableRoutes bool   `env:"DISABLE_ROUTES"`
+	DisableConfig bool   `env:"DISABLE_CONFIG"`
+}
+
+// Addr returns the address that the admin server listens on.
+func (c AdminConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// ------------------------------------------------------------------------------------------------
+// ADMIN SERVER
+// ------------------------------------------------------------------------------------------------
+
+// The possible readiness states of the main server, as reported by the admin server.
+const (
+	adminStarting int32 = iota
+	adminServing
+	adminDraining
+)
+
+// AdminServer serves operational endpoints for an App on a separate listener. Its lifecycle is
+// bound to the App - it starts when the App starts serving, reports the App as not ready as soon
+// as the App's Server begins shutting down, and is itself shut down once the App's Server has
+// finished draining.
+type AdminServer struct {
+	Server http.Server
+	mux    *http.ServeMux
+	app    *App
+	state  atomic.Int32
+	health atomic.Pointer[HealthRegistry]
+}
+
+// newAdminServer creates, initialises and returns a pointer to an AdminServer for the passed App.
+func newAdminServer(cfg AdminConfig, app *App) *AdminServer {
+	admin := &AdminServer{
+		mux: http.NewServeMux(),
+		app: app,
+	}
+	admin.Server = http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           admin.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	admin.mux.HandleFunc("GET /healthz", admin.handleHealthz)
+	admin.mux.HandleFunc("GET /readyz", admin.handleReadyz)
+	if !cfg.DisablePprof {
+		admin.mux.HandleFunc("/debug/pprof/", pprof.Index)
+		admin.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		admin.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		admin.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		admin.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	if !cfg.DisableExpvar {
+		admin.mux.Handle("GET /debug/vars", expvar.Handler())
+	}
+	if !cfg.DisableRoutes {
+		admin.mux.HandleFunc("GET /routes", admin.handleRoutes)
+	}
+	if !cfg.DisableConfig {
+		admin.mux.HandleFunc("GET /config", admin.handleConfig)
+	}
+
+	// Readiness is only reported once the main server has bound its listeners, and drops as soon
+	// as it starts to shut down, so that load balancers stop sending traffic whilst in flight
+	// requests are drained.
+	app.Server.onListening = func() {
+		admin.state.CompareAndSwap(adminStarting, adminServing)
+	}
+	app.Server.Server.RegisterOnShutdown(func() {
+		admin.state.Store(adminDraining)
+	})
+	return admin
+}
+
+// Handle registers an additional handler on the admin server, using http.ServeMux patterns.
+// Handlers must be registered before the App starts serving.
+func (admin *AdminServer) Handle(pattern string, handler http.Handler) {
+	admin.mux.Handle(pattern, handler)
+}
+
+// WithHealthChecks adds the checks in the passed HealthRegistry to the /healthz and /readyz
+// endpoints, which then respond with the application/health+json report. Readiness still fails
+// whilst the main server is starting or draining, regardless of the checks.
+//
+// This method will return a pointer to the receiver AdminServer.
+func (admin *AdminServer) WithHealthChecks(health *HealthRegistry) *AdminServer {
+	admin.health.Store(health)
+	return admin
+}
+
+// Ready returns true if the main server is serving, and has not begun draining.
+func (admin *AdminServer) Ready() bool {
+	return admin.state.Load() == adminServing
+}
+
+// start binds the admin listener, and serves the admin endpoints in the background. The main
+// server is reported as ready once it has bound its own listeners.
+func (admin *AdminServer) start() error {
+	ln, err := net.Listen("tcp", admin.Server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start admin server: %w", err)
+	}
+	go func() {
+		_ = admin.Server.Serve(ln)
+	}()
+	return nil
+}
+
+// Shutdown gracefully stops the admin server.
+func (admin *AdminServer) Shutdown(ctx context.Context) error {
+	admin.state.Store(adminDraining)
+	return admin.Server.Shutdown(ctx)
+}
+
+// close immediately stops the admin server. It is used when the main server fails.
+func (admin *AdminServer) close() {
+	admin.state.Store(adminDraining)
+	_ = admin.Server.Close()
+}
+
+// handleHealthz reports that the process is alive.
+func (admin *AdminServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if health := admin.health.Load(); health != nil {
+		health.Liveness(r.Context()).ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok"))
+}
+
+// handleReadyz reports whether the main server is ready to receive traffic.
+func (admin *AdminServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	state := admin.state.Load()
+	if health := admin.health.Load(); health != nil && state == adminServing {
+		health.Readiness(r.Context()).ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	switch state {
+	case adminServing:
+		_, _ = w.Write([]byte("ready"))
+	case adminDraining:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("draining"))
+	default:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("starting"))
+	}
+}
+
+// adminRoute describes a single entry in the route table.
+type adminRoute struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+}
+
+// handleRoutes writes the App's route table, sorted by pattern and then method.
+func (admin *AdminServer) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := []adminRoute{}
+	for _, route := range admin.app.Routes() {
+		routes = append(routes, adminRoute{Method: route.Method, Pattern: route.ComputedPattern()})
+	}
+	slices.SortFunc(routes, func(a, b adminRoute) int {
+		return cmp.Or(strings.Compare(a.Pattern, b.Pattern), strings.Compare(a.Method, b.Method))
+	})
+	writeAdminJSON(w, routes)
+}
+
+// handleConfig writes the App's effective settings, with secrets redacted.
+func (admin *AdminServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeAdminJSON(w, admin.app.Settings())
+}
+
+// writeAdminJSON writes the passed value as indented JSON.
+func writeAdminJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,249 @@
+package rmhttp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ADMIN TESTS
+// ------------------------------------------------------------------------------------------------
+
+// adminGet sends a GET request to the passed URL, and returns the status code and body.
+func adminGet(t *testing.T, url string) (int, string) {
+	t.Helper()
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(body)
+}
+
+// Test_AdminServer_endpoints checks each of the admin endpoints, without starting a listener.
+func Test_AdminServer_endpoints(t *testing.T) {
+	app := New(Config{Admin: AdminConfig{Port: 9876}})
+	app.Get("/users", createTestHandlerFunc(http.StatusOK, "users"))
+	app.Post("/users", createTestHandlerFunc(http.StatusCreated, "created"))
+	app.Group("/api").Get("/status", createTestHandlerFunc(http.StatusOK, "status"))
+	require.NotNil(t, app.Admin)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.Admin.Server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	assert.Equal(t, "ok", serve("/healthz").Body.String())
+
+	w := serve("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "starting", w.Body.String())
+
+	w = serve("/routes")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[
+		{"method": "GET", "pattern": "/api/status"},
+		{"method": "GET", "pattern": "/users"},
+		{"method": "POST", "pattern": "/users"}
+	]`, w.Body.String())
+
+	var settings []Setting
+	w = serve("/config")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &settings))
+	byName := settingsByName(settings)
+	assert.Equal(t, "9876", byName["Admin.Port"].Value)
+	assert.Equal(t, SourceCode, byName["Admin.Port"].Source)
+
+	assert.Equal(t, http.StatusOK, serve("/debug/vars").Code)
+	assert.Equal(t, http.StatusOK, serve("/debug/pprof/").Code)
+
+	custom := http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "custom"))
+	app.Admin.Handle("GET /custom", custom)
+	assert.Equal(t, "custom", serve("/custom").Body.String())
+}
+
+// Test_AdminServer_disabled_endpoints checks that optional endpoints can be disabled, and that no
+// admin server is created unless a port is set.
+func Test_AdminServer_disabled_endpoints(t *testing.T) {
+	assert.Nil(t, New().Admin)
+
+	app := New(Config{Admin: AdminConfig{
+		Port:          9876,
+		DisablePprof:  true,
+		DisableExpvar: true,
+		DisableRoutes: true,
+		DisableConfig: true,
+	}})
+	for _, path := range []string{"/debug/pprof/", "/debug/vars", "/routes", "/config"} {
+		w := httptest.NewRecorder()
+		app.Admin.Server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+
+// Test_AdminServer_lifecycle checks that the admin server starts with the App, reports the App as
+// not ready while it drains, and stops once the App has shut down.
+func Test_AdminServer_lifecycle(t *testing.T) {
+	port := freePort(t)
+	adminPort := freePort(t)
+	app := New(Config{
+		Server: ServerConfig{Host: "127.0.0.1", Port: port},
+		Admin:  AdminConfig{Host: "127.0.0.1", Port: adminPort},
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	app.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("done"))
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- app.ListenAndServe()
+	}()
+	require.NoError(t, waitForServerAvailable(port, 5*time.Second))
+	readyURL := "http://127.0.0.1:" + strconv.Itoa(adminPort) + "/readyz"
+
+	code, body := adminGet(t, readyURL)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body)
+	assert.True(t, app.Admin.Ready())
+
+	// Start a slow request, so that shutting down has to drain it.
+	slow := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://127.0.0.1:" + strconv.Itoa(port) + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		slow <- string(body)
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- app.Shutdown(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		res, err := http.Get(readyURL)
+		if err != nil {
+			return false
+		}
+		_ = res.Body.Close()
+		return res.StatusCode == http.StatusServiceUnavailable
+	}, 5*time.Second, 10*time.Millisecond)
+	_, body = adminGet(t, readyURL)
+	assert.Equal(t, "draining", body)
+
+	close(release)
+	assert.Equal(t, "done", <-slow)
+	require.NoError(t, <-shutdown)
+	assert.ErrorIs(t, <-served, http.ErrServerClosed)
+
+	_, err := http.Get(readyURL)
+	assert.Error(t, err)
+}
+
+// Test_AdminServer_main_server_fails checks that the admin server is stopped if the main server
+// cannot start, and that the App fails to start if the admin server cannot bind.
+func Test_AdminServer_main_server_fails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+	busyPort := ln.Addr().(*net.TCPAddr).Port
+
+	app := New(Config{
+		Server: ServerConfig{Host: "127.0.0.1", Port: busyPort},
+		Admin:  AdminConfig{Host: "127.0.0.1", Port: freePort(t)},
+	})
+	assert.Error(t, app.ListenAndServe())
+	assert.False(t, app.Admin.Ready())
+
+	app = New(Config{
+		Server: ServerConfig{Host: "127.0.0.1", Port: freePort(t)},
+		Admin:  AdminConfig{Host: "127.0.0.1", Port: busyPort},
+	})
+	assert.ErrorContains(t, app.ListenAndServe(), "failed to start admin server")
+}
+
+// Test_AdminServer_not_ready_until_bound checks that the main server is only reported as ready
+// once its listener has been bound, so a main server that fails to bind is never reported ready.
+func Test_AdminServer_not_ready_until_bound(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+	busyPort := ln.Addr().(*net.TCPAddr).Port
+	adminPort := freePort(t)
+
+	app := New(Config{
+		Server: ServerConfig{Host: "127.0.0.1", Port: busyPort},
+		Admin:  AdminConfig{Host: "127.0.0.1", Port: adminPort},
+	})
+	require.NoError(t, app.Admin.start())
+	defer app.Admin.close()
+	readyURL := "http://127.0.0.1:" + strconv.Itoa(adminPort) + "/readyz"
+
+	code, body := adminGet(t, readyURL)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "starting", body)
+
+	assert.Error(t, app.Server.ListenAndServe())
+	code, body = adminGet(t, readyURL)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "starting", body)
+	assert.False(t, app.Admin.Ready())
+}
+
+// Test_Config_Validate_admin checks that invalid admin ports are reported.
+func Test_Config_Validate_admin(t *testing.T) {
+	cfg := Config{Server: defaultServerConfig, Admin: AdminConfig{Port: 70000}}
+	assert.ErrorContains(t, cfg.Validate(), "Admin.Port must be between 0 and 65535")
+
+	cfg.Admin.Port = cfg.Server.Port
+	assert.ErrorContains(t, cfg.Validate(), "Admin.Port must differ from the Server ports")
+
+	cfg.Admin.Port = 9090
+	assert.NoError(t, cfg.Validate())
+}
+
+// Test_LoadConfig_admin_env checks that the admin server reads its own namespaced variables, so
+// that an App with an ADMIN_ prefix doesn't configure another App's admin server.
+func Test_LoadConfig_admin_env(t *testing.T) {
+	t.Setenv("ADMIN_PORT", "9091")
+	t.Setenv("ADMIN_SERVER_PORT", "9092")
+	t.Setenv("ADMIN_SERVER_DISABLE_PPROF", "true")
+
+	cfg, err := LoadConfig(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, 9092, cfg.Admin.Port)
+	assert.True(t, cfg.Admin.DisablePprof)
+	assert.Equal(t, "ADMIN_SERVER_PORT", settingsByName(cfg.Settings())["Admin.Port"].Key)
+
+	prefixed, err := LoadConfig(Config{EnvPrefix: "ADMIN_"})
+	require.NoError(t, err)
+	assert.Equal(t, 9091, prefixed.Server.Port)
+	assert.Equal(t, 0, prefixed.Admin.Port)
+}
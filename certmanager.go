@@ -0,0 +1,302 @@
+package rmhttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CERTIFICATE MANAGER
+// ------------------------------------------------------------------------------------------------
+
+// CertInfo describes a certificate loaded by a CertManager.
+type CertInfo struct {
+	// Source is the certificate file path, or "memory" for PEM supplied directly.
+	Source   string
+	Names    []string
+	NotAfter time.Time
+}
+
+// certSource is a single certificate/key pair, loaded either from files or from memory.
+type certSource struct {
+	certFile string
+	keyFile  string
+	certPEM  []byte
+	keyPEM   []byte
+	modTimes [2]time.Time
+	cert     *tls.Certificate
+}
+
+// certStore is an immutable snapshot of the loaded certificates, indexed for SNI lookups.
+type certStore struct {
+	byName   map[string]*tls.Certificate
+	fallback *tls.Certificate
+	infos    []CertInfo
+}
+
+// A CertManager loads one or more TLS certificate/key pairs and selects between them by SNI. It
+// can watch certificate files for changes and reload them without a restart. Certificates are
+// swapped atomically, so in flight handshakes always see a complete set.
+//
+// To use a CertManager, set ServerConfig.TLSConfig to the value returned by TLSConfig(), and pass
+// empty strings as the cert and key to ListenAndServeTLS.
+//
+// CertManager is safe for concurrent use.
+type CertManager struct {
+	mu      sync.Mutex
+	sources []*certSource
+	store   atomic.Pointer[certStore]
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// NewCertManager creates, initialises, and returns a pointer to a CertManager.
+func NewCertManager() *CertManager {
+	cm := &CertManager{}
+	cm.store.Store(&certStore{byName: map[string]*tls.Certificate{}})
+	return cm
+}
+
+// AddFile loads the certificate and key from the passed PEM encoded files and adds them to the
+// CertManager. The files will be checked for changes if Watch has been called.
+func (cm *CertManager) AddFile(certFile string, keyFile string) error {
+	src := &certSource{certFile: certFile, keyFile: keyFile}
+	if err := src.load(); err != nil {
+		return err
+	}
+	cm.add(src)
+	return nil
+}
+
+// AddPEM adds the passed PEM encoded certificate and key to the CertManager.
+func (cm *CertManager) AddPEM(certPEM []byte, keyPEM []byte) error {
+	src := &certSource{certPEM: certPEM, keyPEM: keyPEM}
+	if err := src.load(); err != nil {
+		return err
+	}
+	cm.add(src)
+	return nil
+}
+
+// add appends the passed certSource and rebuilds the certificate store.
+func (cm *CertManager) add(src *certSource) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.sources = append(cm.sources, src)
+	cm.rebuild()
+}
+
+// GetCertificate returns the certificate that best matches the server name requested by the
+// client. Exact names are preferred over wildcard names, and the first certificate added is used
+// if nothing matches. Its signature matches tls.Config.GetCertificate.
+func (cm *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	store := cm.store.Load()
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	if name != "" {
+		if cert, ok := store.byName[name]; ok {
+			return cert, nil
+		}
+		if _, rest, ok := strings.Cut(name, "."); ok {
+			if cert, ok := store.byName["*."+rest]; ok {
+				return cert, nil
+			}
+		}
+	}
+	if store.fallback == nil {
+		return nil, errors.New("no certificates available")
+	}
+	return store.fallback, nil
+}
+
+// TLSConfig returns a new tls.Config that sources its certificates from the CertManager.
+func (cm *CertManager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cm.GetCertificate,
+	}
+}
+
+// Certificates returns information about every loaded certificate, including when it expires.
+func (cm *CertManager) Certificates() []CertInfo {
+	return append([]CertInfo(nil), cm.store.Load().infos...)
+}
+
+// NextExpiry returns the earliest expiry time of all of the loaded certificates. The zero time is
+// returned if no certificates have been loaded.
+func (cm *CertManager) NextExpiry() time.Time {
+	var next time.Time
+	for _, info := range cm.store.Load().infos {
+		if next.IsZero() || info.NotAfter.Before(next) {
+			next = info.NotAfter
+		}
+	}
+	return next
+}
+
+// Reload reloads every file based certificate that has changed since it was last loaded. If a
+// certificate fails to load, the previous version is kept and the errors are returned together.
+func (cm *CertManager) Reload() error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	var errs []error
+	changed := false
+	for _, src := range cm.sources {
+		if src.certFile == "" || !src.modified() {
+			continue
+		}
+		if err := src.load(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changed = true
+	}
+	if changed {
+		cm.rebuild()
+	}
+	return errors.Join(errs...)
+}
+
+// Watch starts checking the certificate files for changes at the passed interval, reloading them
+// when they change. Reload errors are logged, and the previous certificates remain in use.
+// Calling Watch again replaces the existing watcher.
+func (cm *CertManager) Watch(interval time.Duration) {
+	cm.Close()
+
+	cm.mu.Lock()
+	stop := make(chan struct{})
+	cm.stop = stop
+	cm.mu.Unlock()
+
+	cm.stopped.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := cm.Reload(); err != nil {
+					slog.Error("failed to reload TLS certificates", "error", err)
+				}
+			}
+		}
+	})
+}
+
+// Close stops watching the certificate files, if Watch has been called.
+func (cm *CertManager) Close() {
+	cm.mu.Lock()
+	if cm.stop != nil {
+		close(cm.stop)
+		cm.stop = nil
+	}
+	cm.mu.Unlock()
+	cm.stopped.Wait()
+}
+
+// rebuild creates a new certStore from the current sources and swaps it in. It must be called
+// with the mutex held.
+func (cm *CertManager) rebuild() {
+	store := &certStore{byName: map[string]*tls.Certificate{}}
+	for _, src := range cm.sources {
+		leaf := src.cert.Leaf
+		names := certNames(leaf)
+		for _, name := range names {
+			if _, ok := store.byName[name]; !ok {
+				store.byName[name] = src.cert
+			}
+		}
+		if store.fallback == nil {
+			store.fallback = src.cert
+		}
+
+		source := src.certFile
+		if source == "" {
+			source = "memory"
+		}
+		store.infos = append(store.infos, CertInfo{
+			Source:   source,
+			Names:    names,
+			NotAfter: leaf.NotAfter,
+		})
+	}
+	cm.store.Store(store)
+}
+
+// load parses the certificate and key for this source, and records the file modification times
+// so that changes can be detected. The modification times are only recorded once the pair has
+// loaded successfully, so a half written pair will be retried on the next reload.
+func (src *certSource) load() error {
+	var modTimes [2]time.Time
+	certPEM, keyPEM := src.certPEM, src.keyPEM
+	if src.certFile != "" {
+		var err error
+		if modTimes, err = src.statFiles(); err != nil {
+			return err
+		}
+		if certPEM, err = os.ReadFile(src.certFile); err != nil {
+			return fmt.Errorf("failed to read certificate file: %w", err)
+		}
+		if keyPEM, err = os.ReadFile(src.keyFile); err != nil {
+			return fmt.Errorf("failed to read key file: %w", err)
+		}
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse certificate: %w", err)
+		}
+	}
+	src.cert = &cert
+	src.modTimes = modTimes
+	return nil
+}
+
+// modified returns true if either file has changed since it was last loaded.
+func (src *certSource) modified() bool {
+	modTimes, err := src.statFiles()
+	if err != nil {
+		return false
+	}
+	return modTimes != src.modTimes
+}
+
+// statFiles returns the modification times of the certificate and key files.
+func (src *certSource) statFiles() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{src.certFile, src.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// certNames returns the lowercased DNS names that a certificate is valid for, falling back to
+// the subject common name if there are none.
+func certNames(leaf *x509.Certificate) []string {
+	names := make([]string, 0, len(leaf.DNSNames)+1)
+	for _, name := range leaf.DNSNames {
+		names = append(names, strings.ToLower(name))
+	}
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = append(names, strings.ToLower(leaf.Subject.CommonName))
+	}
+	return names
+}
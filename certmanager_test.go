@@ -0,0 +1,184 @@
+package rmhttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CERTIFICATE MANAGER TESTS
+// ------------------------------------------------------------------------------------------------
+
+// createTestCertPEM generates a self-signed certificate and key for the passed names, which
+// expires at the passed time.
+func createTestCertPEM(t *testing.T, notAfter time.Time, names ...string) ([]byte, []byte) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "failed to generate key pair")
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err, "failed to generate serial number")
+
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: names[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     names,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(t, err, "failed to create certificate")
+
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err, "failed to marshal private key")
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTestCertFiles writes the passed certificate and key to files in dir, setting the
+// modification time so that changes are always detected.
+func writeTestCertFiles(t *testing.T, dir string, certPEM, keyPEM []byte, modTime time.Time) (
+	string,
+	string,
+) {
+	t.Helper()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	return certFile, keyFile
+}
+
+// Test_CertManager_SNI checks that certificates are selected by server name, including wildcard
+// names, and that the first certificate is used as a fallback.
+func Test_CertManager_SNI(t *testing.T) {
+	expiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	aCert, aKey := createTestCertPEM(t, expiry, "a.test")
+	bCert, bKey := createTestCertPEM(t, expiry, "*.b.test")
+
+	cm := NewCertManager()
+	require.NoError(t, cm.AddPEM(aCert, aKey))
+	require.NoError(t, cm.AddPEM(bCert, bKey))
+
+	tests := []struct {
+		serverName string
+		expected   string
+	}{
+		{"a.test", "a.test"},
+		{"A.TEST.", "a.test"},
+		{"www.b.test", "*.b.test"},
+		{"unknown.test", "a.test"},
+		{"", "a.test"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.serverName, func(t *testing.T) {
+			cert, err := cm.GetCertificate(&tls.ClientHelloInfo{ServerName: test.serverName})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, cert.Leaf.DNSNames[0])
+		})
+	}
+
+	infos := cm.Certificates()
+	require.Len(t, infos, 2)
+	assert.Equal(t, "memory", infos[0].Source)
+	assert.Equal(t, []string{"*.b.test"}, infos[1].Names)
+	assert.True(t, expiry.Equal(infos[0].NotAfter))
+}
+
+// Test_CertManager_empty checks that an error is returned when no certificates are loaded.
+func Test_CertManager_empty(t *testing.T) {
+	cm := NewCertManager()
+	_, err := cm.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.test"})
+	assert.Error(t, err)
+	assert.True(t, cm.NextExpiry().IsZero())
+}
+
+// Test_CertManager_invalid checks that invalid certificates are rejected.
+func Test_CertManager_invalid(t *testing.T) {
+	cm := NewCertManager()
+	assert.Error(t, cm.AddPEM([]byte("not a cert"), []byte("not a key")))
+	assert.Error(t, cm.AddFile("/nonexistent/cert.pem", "/nonexistent/key.pem"))
+	assert.Empty(t, cm.Certificates())
+}
+
+// Test_CertManager_rotation checks that rotated certificate files are picked up by the watcher,
+// that a broken rotation keeps the previous certificate, and that a TLS handshake sees the new
+// certificate.
+func Test_CertManager_rotation(t *testing.T) {
+	dir := t.TempDir()
+	firstExpiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	secondExpiry := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+
+	certPEM, keyPEM := createTestCertPEM(t, firstExpiry, "localhost")
+	modTime := time.Now().Add(-time.Minute)
+	certFile, keyFile := writeTestCertFiles(t, dir, certPEM, keyPEM, modTime)
+
+	cm := NewCertManager()
+	require.NoError(t, cm.AddFile(certFile, keyFile))
+	assert.True(t, firstExpiry.Equal(cm.NextExpiry()))
+
+	// A mismatched pair should be rejected, keeping the original certificate in place.
+	otherCert, _ := createTestCertPEM(t, secondExpiry, "localhost")
+	writeTestCertFiles(t, dir, otherCert, keyPEM, modTime.Add(time.Second))
+	assert.Error(t, cm.Reload())
+	assert.True(t, firstExpiry.Equal(cm.NextExpiry()))
+
+	// A complete rotation should be picked up by the watcher.
+	cm.Watch(10 * time.Millisecond)
+	defer cm.Close()
+	certPEM, keyPEM = createTestCertPEM(t, secondExpiry, "localhost")
+	writeTestCertFiles(t, dir, certPEM, keyPEM, modTime.Add(2*time.Second))
+
+	assert.Eventually(t, func() bool {
+		return secondExpiry.Equal(cm.NextExpiry())
+	}, 2*time.Second, 10*time.Millisecond)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cm.TLSConfig())
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		_ = conn.Close()
+	}()
+
+	conn, err := tls.DialWithDialer(
+		&net.Dialer{Timeout: time.Second},
+		"tcp",
+		ln.Addr().String(),
+		// #nosec G402 - the test certificate is self-signed
+		&tls.Config{ServerName: "localhost", InsecureSkipVerify: true},
+	)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	peer := conn.ConnectionState().PeerCertificates[0]
+	assert.True(t, secondExpiry.Equal(peer.NotAfter))
+}
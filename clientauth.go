@@ -0,0 +1,328 @@
+package rmhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CLIENT AUTH CONFIG
+// ------------------------------------------------------------------------------------------------
+
+// Client certificate verification modes, for use in ClientAuthConfig.Mode.
+const (
+	ClientAuthRequest       = "request"
+	ClientAuthRequire       = "require"
+	ClientAuthVerifyIfGiven = "verify-if-given"
+	ClientAuthVerify        = "verify"
+)
+
+// The ClientAuthConfig contains settings for mutual TLS (client certificate) authentication. It
+// is only applied when Mode is set, and the Server is started with TLS.
+//
+// The allow lists are enforced during the handshake, so a client presenting a verified
+// certificate that does not match either list will be rejected before any request is read. If
+// both lists are empty, any certificate signed by one of the CAs is accepted.
+type ClientAuthConfig struct {
+	// Mode is one of request, require, verify-if-given or verify. The request and require modes
+	// do not verify the certificate, so identities from those modes are never marked as verified.
+	Mode               string   `env:"TLS_CLIENT_AUTH"`
+	CAFile             string   `env:"TLS_CLIENT_CA_FILE"`
+	CRLFile            string   `env:"TLS_CLIENT_CRL_FILE"`
+	AllowedSPIFFEIDs   []string `env:"TLS_CLIENT_ALLOWED_SPIFFE_IDS"`
+	AllowedCommonNames []string `env:"TLS_CLIENT_ALLOWED_CNS"`
+
+	// CAPEM can be used to supply the client CA bundle from memory, in addition to CAFile.
+	CAPEM []byte
+}
+
+// tlsClientAuthType converts the configured Mode into a tls.ClientAuthType.
+func (c ClientAuthConfig) tlsClientAuthType() (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(c.Mode)) {
+	case "":
+		return tls.NoClientCert, nil
+	case ClientAuthRequest:
+		return tls.RequestClientCert, nil
+	case ClientAuthRequire:
+		return tls.RequireAnyClientCert, nil
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthVerify:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("invalid client auth mode: %s", c.Mode)
+}
+
+// apply returns a copy of the passed tls.Config (or a new one, if nil) with client certificate
+// authentication configured. Any VerifyConnection hook in the passed tls.Config is kept, and
+// called after the revocation and allow list checks.
+func (c ClientAuthConfig) apply(base *tls.Config) (*tls.Config, error) {
+	authType, err := c.tlsClientAuthType()
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg *tls.Config
+	if base != nil {
+		cfg = base.Clone()
+	} else {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	cfg.ClientAuth = authType
+	if authType == tls.NoClientCert {
+		return cfg, nil
+	}
+
+	cas, err := c.loadCAs()
+	if err != nil {
+		return nil, err
+	}
+	verifies := authType == tls.VerifyClientCertIfGiven ||
+		authType == tls.RequireAndVerifyClientCert
+	if verifies && len(cas) == 0 {
+		return nil, errors.New("client auth mode requires a client CA bundle")
+	}
+	if len(cas) > 0 {
+		pool := x509.NewCertPool()
+		for _, ca := range cas {
+			pool.AddCert(ca)
+		}
+		cfg.ClientCAs = pool
+	}
+
+	revoked, err := c.loadCRL(cas)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := cfg.VerifyConnection
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.VerifiedChains) > 0 {
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					if revoked.contains(cert) {
+						return errors.New("client certificate has been revoked")
+					}
+				}
+			}
+			if !c.allowed(newClientIdentity(&cs)) {
+				return errors.New("client certificate is not allowed")
+			}
+		}
+		if previous != nil {
+			return previous(cs)
+		}
+		return nil
+	}
+	return cfg, nil
+}
+
+// allowed returns true if the passed identity matches the allow lists, or if there are none.
+func (c ClientAuthConfig) allowed(identity *ClientIdentity) bool {
+	if len(c.AllowedSPIFFEIDs) == 0 && len(c.AllowedCommonNames) == 0 {
+		return true
+	}
+	return MatchSPIFFEID(c.AllowedSPIFFEIDs...)(identity) ||
+		MatchCommonName(c.AllowedCommonNames...)(identity)
+}
+
+// loadCAs parses every certificate in the CA file and CAPEM.
+func (c ClientAuthConfig) loadCAs() ([]*x509.Certificate, error) {
+	data := append([]byte(nil), c.CAPEM...)
+	if c.CAFile != "" {
+		file, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		data = append(data, '\n')
+		data = append(data, file...)
+	}
+
+	var cas []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse client CA certificate: %w", err)
+		}
+		cas = append(cas, ca)
+	}
+	return cas, nil
+}
+
+// revocationList holds the revoked serial numbers from a CRL, along with the issuer they apply
+// to.
+type revocationList struct {
+	issuer  []byte
+	serials map[string]struct{}
+}
+
+// contains returns true if the passed certificate has been revoked.
+func (rl *revocationList) contains(cert *x509.Certificate) bool {
+	if rl == nil || string(cert.RawIssuer) != string(rl.issuer) {
+		return false
+	}
+	_, ok := rl.serials[cert.SerialNumber.String()]
+	return ok
+}
+
+// loadCRL parses the configured CRL file (PEM or DER), and checks that it has been signed by one
+// of the passed CAs.
+func (c ClientAuthConfig) loadCRL(cas []*x509.Certificate) (*revocationList, error) {
+	if c.CRLFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(c.CRLFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CRL file: %w", err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client CRL: %w", err)
+	}
+
+	signed := slices.ContainsFunc(cas, func(ca *x509.Certificate) bool {
+		return crl.CheckSignatureFrom(ca) == nil
+	})
+	if !signed {
+		return nil, errors.New("client CRL is not signed by a client CA")
+	}
+
+	rl := &revocationList{
+		issuer:  crl.RawIssuer,
+		serials: make(map[string]struct{}, len(crl.RevokedCertificateEntries)),
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		rl.serials[(*big.Int)(entry.SerialNumber).String()] = struct{}{}
+	}
+	return rl, nil
+}
+
+// ------------------------------------------------------------------------------------------------
+// CLIENT IDENTITY
+// ------------------------------------------------------------------------------------------------
+
+// clientIdentityKey is the context key for the ClientIdentity.
+type clientIdentityKey struct{}
+
+// A ClientIdentity describes the peer that presented a client certificate.
+type ClientIdentity struct {
+	CommonName  string
+	SPIFFEID    string
+	DNSNames    []string
+	Certificate *x509.Certificate
+
+	// Verified is true if the certificate chain was verified against the client CAs.
+	Verified bool
+}
+
+// newClientIdentity creates a ClientIdentity from the passed connection state. It returns nil if
+// the peer did not present a certificate.
+func newClientIdentity(cs *tls.ConnectionState) *ClientIdentity {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := cs.PeerCertificates[0]
+	identity := &ClientIdentity{
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		Certificate: cert,
+		Verified:    len(cs.VerifiedChains) > 0,
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			identity.SPIFFEID = uri.String()
+			break
+		}
+	}
+	return identity
+}
+
+// ClientIdentityFromContext returns the ClientIdentity stored in the passed context by
+// ClientCertMiddleware, if present.
+func ClientIdentityFromContext(ctx context.Context) (*ClientIdentity, bool) {
+	identity, ok := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	return identity, ok && identity != nil
+}
+
+// clientIdentity returns the ClientIdentity for the passed request, preferring the one stored
+// in the request context.
+func clientIdentity(r *http.Request) *ClientIdentity {
+	if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+		return identity
+	}
+	return newClientIdentity(r.TLS)
+}
+
+// ------------------------------------------------------------------------------------------------
+// CLIENT CERT MIDDLEWARE
+// ------------------------------------------------------------------------------------------------
+
+// ClientCertMiddleware creates and returns a middleware function that stores the identity of the
+// client certificate (if any) in the request context, so that it can be retrieved via
+// ClientIdentityFromContext.
+func ClientCertMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if identity := newClientIdentity(r.TLS); identity != nil {
+				r = r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, identity))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireClientCertMiddleware creates and returns a middleware function that rejects requests
+// with a 403 response, unless the client presented a verified certificate that satisfies the
+// passed matcher. A nil matcher accepts any verified certificate.
+func RequireClientCertMiddleware(
+	match func(*ClientIdentity) bool,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity := clientIdentity(r)
+			if identity == nil || !identity.Verified || (match != nil && !match(identity)) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if _, ok := ClientIdentityFromContext(r.Context()); !ok {
+				r = r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, identity))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MatchSPIFFEID returns a matcher that accepts identities with one of the passed SPIFFE IDs.
+func MatchSPIFFEID(ids ...string) func(*ClientIdentity) bool {
+	return func(identity *ClientIdentity) bool {
+		return identity.SPIFFEID != "" && slices.Contains(ids, identity.SPIFFEID)
+	}
+}
+
+// MatchCommonName returns a matcher that accepts identities with one of the passed subject
+// common names.
+func MatchCommonName(names ...string) func(*ClientIdentity) bool {
+	return func(identity *ClientIdentity) bool {
+		return identity.CommonName != "" && slices.Contains(names, identity.CommonName)
+	}
+}
@@ -0,0 +1,354 @@
+package rmhttp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CLIENT AUTH TESTS
+// ------------------------------------------------------------------------------------------------
+
+// testCA is a certificate authority used to issue test certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+	pem  []byte
+}
+
+// newTestCA generates a self-signed certificate authority.
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue creates a certificate signed by the CA. Client certificates get the passed common name and
+// SPIFFE ID, while server certificates are valid for 127.0.0.1.
+func (ca testCA) issue(
+	t *testing.T,
+	serial int64,
+	cn string,
+	spiffeID string,
+	client bool,
+) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if client {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		template.IPAddresses = nil
+	}
+	if spiffeID != "" {
+		uri, err := url.Parse(spiffeID)
+		require.NoError(t, err)
+		template.URIs = []*url.URL{uri}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// crlFile writes a CRL revoking the passed serial numbers, and returns its path.
+func (ca testCA) crlFile(t *testing.T, serials ...int64) string {
+	t.Helper()
+	entries := make([]x509.RevocationListEntry, 0, len(serials))
+	for _, serial := range serials {
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "crl.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// Test_ClientAuthConfig_modes checks that each mode maps to the expected tls.ClientAuthType.
+func Test_ClientAuthConfig_modes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected tls.ClientAuthType
+	}{
+		{"", tls.NoClientCert},
+		{ClientAuthRequest, tls.RequestClientCert},
+		{ClientAuthRequire, tls.RequireAnyClientCert},
+		{ClientAuthVerifyIfGiven, tls.VerifyClientCertIfGiven},
+		{"VERIFY", tls.RequireAndVerifyClientCert},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			authType, err := ClientAuthConfig{Mode: test.mode}.tlsClientAuthType()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, authType)
+		})
+	}
+
+	_, err := ClientAuthConfig{Mode: "sometimes"}.tlsClientAuthType()
+	assert.Error(t, err)
+}
+
+// Test_ClientAuthConfig_apply_errors checks that invalid client auth configs are rejected.
+func Test_ClientAuthConfig_apply_errors(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	tests := []struct {
+		name   string
+		config ClientAuthConfig
+	}{
+		{"verify without a CA", ClientAuthConfig{Mode: ClientAuthVerify}},
+		{"missing CA file", ClientAuthConfig{Mode: ClientAuthVerify, CAFile: "/nonexistent"}},
+		{
+			"CRL signed by another CA",
+			ClientAuthConfig{Mode: ClientAuthVerify, CAPEM: ca.pem, CRLFile: otherCA.crlFile(t)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.config.apply(nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// Test_ClientAuthConfig_apply_verify_connection checks that an existing VerifyConnection hook
+// still runs after the revocation and allow list checks.
+func Test_ClientAuthConfig_apply_verify_connection(t *testing.T) {
+	ca := newTestCA(t)
+	allowed := ca.issue(t, 11, "billing", "spiffe://example.org/billing", true)
+	pinned := ca.issue(t, 12, "pinned", "spiffe://example.org/billing", true)
+	revoked := ca.issue(t, 13, "revoked", "spiffe://example.org/billing", true)
+	disallowed := ca.issue(t, 14, "intruder", "spiffe://example.org/intruder", true)
+
+	var calls []string
+	base := &tls.Config{
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			cn := ""
+			if len(cs.VerifiedChains) > 0 {
+				cn = cs.VerifiedChains[0][0].Subject.CommonName
+			}
+			calls = append(calls, cn)
+			if cn == "pinned" {
+				return errors.New("certificate is not pinned")
+			}
+			return nil
+		},
+	}
+	cfg, err := ClientAuthConfig{
+		Mode:             ClientAuthVerifyIfGiven,
+		CAPEM:            ca.pem,
+		CRLFile:          ca.crlFile(t, 13),
+		AllowedSPIFFEIDs: []string{"spiffe://example.org/billing"},
+	}.apply(base)
+	require.NoError(t, err)
+
+	verify := func(cert tls.Certificate) error {
+		return cfg.VerifyConnection(tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert.Leaf},
+			VerifiedChains:   [][]*x509.Certificate{{cert.Leaf, ca.cert}},
+		})
+	}
+	assert.NoError(t, verify(allowed))
+	assert.ErrorContains(t, verify(pinned), "not pinned")
+	assert.ErrorContains(t, verify(revoked), "revoked")
+	assert.ErrorContains(t, verify(disallowed), "not allowed")
+	assert.NoError(t, cfg.VerifyConnection(tls.ConnectionState{}))
+	assert.Equal(t, []string{"billing", "pinned", ""}, calls)
+}
+
+// Test_RequireClientCertMiddleware checks that requests are only allowed through when a verified
+// certificate satisfying the matcher has been presented.
+func Test_RequireClientCertMiddleware(t *testing.T) {
+	ca := newTestCA(t)
+	cert := ca.issue(t, 2, "billing", "spiffe://example.org/billing", true)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := ClientIdentityFromContext(r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(identity.SPIFFEID))
+	})
+
+	tests := []struct {
+		name         string
+		state        *tls.ConnectionState
+		match        func(*ClientIdentity) bool
+		expectedCode int
+	}{
+		{"no TLS", nil, nil, http.StatusForbidden},
+		{
+			"unverified certificate",
+			&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}},
+			nil,
+			http.StatusForbidden,
+		},
+		{
+			"verified certificate without a matcher",
+			&tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert.Leaf},
+				VerifiedChains:   [][]*x509.Certificate{{cert.Leaf, ca.cert}},
+			},
+			nil,
+			http.StatusOK,
+		},
+		{
+			"verified certificate matching the SPIFFE ID",
+			&tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert.Leaf},
+				VerifiedChains:   [][]*x509.Certificate{{cert.Leaf, ca.cert}},
+			},
+			MatchSPIFFEID("spiffe://example.org/billing"),
+			http.StatusOK,
+		},
+		{
+			"verified certificate not matching the common name",
+			&tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert.Leaf},
+				VerifiedChains:   [][]*x509.Certificate{{cert.Leaf, ca.cert}},
+			},
+			MatchCommonName("payments"),
+			http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := RequireClientCertMiddleware(test.match)(handler)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = test.state
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, test.expectedCode, w.Code)
+		})
+	}
+}
+
+// Test_ClientAuth_server checks mutual TLS end to end, including allow lists, CRLs and the group
+// level guard.
+func Test_ClientAuth_server(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert := ca.issue(t, 10, "server", "", false)
+	allowed := ca.issue(t, 11, "billing", "spiffe://example.org/billing", true)
+	disallowed := ca.issue(t, 12, "intruder", "spiffe://example.org/intruder", true)
+	revoked := ca.issue(t, 13, "revoked", "spiffe://example.org/billing", true)
+
+	port := freePort(t)
+	app := New(Config{
+		Server: ServerConfig{
+			Host:      "127.0.0.1",
+			Port:      port,
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{serverCert}},
+			ClientAuth: ClientAuthConfig{
+				Mode:             ClientAuthVerify,
+				CAPEM:            ca.pem,
+				CRLFile:          ca.crlFile(t, 13),
+				AllowedSPIFFEIDs: []string{"spiffe://example.org/billing"},
+			},
+		},
+	})
+	app.Use(ClientCertMiddleware())
+	app.Group("/internal").
+		RequireClientCert(MatchCommonName("billing")).
+		Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+			identity, _ := ClientIdentityFromContext(r.Context())
+			_, _ = w.Write([]byte(identity.CommonName))
+		})
+
+	go func() {
+		_ = app.ListenAndServeTLS("", "")
+	}()
+	defer func() {
+		_ = app.Shutdown(context.Background())
+	}()
+	require.NoError(t, waitForServerAvailable(port, 5*time.Second))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(cert tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			},
+		}}
+		res, err := client.Get("https://127.0.0.1:" + strconv.Itoa(port) + "/internal/whoami")
+		if err != nil {
+			return "", err
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
+		body, _ := io.ReadAll(res.Body)
+		return strconv.Itoa(res.StatusCode) + " " + string(body), nil
+	}
+
+	body, err := get(allowed)
+	require.NoError(t, err)
+	assert.Equal(t, "200 billing", body)
+
+	_, err = get(disallowed)
+	assert.Error(t, err)
+
+	_, err = get(revoked)
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package rmhttp
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -42,6 +43,11 @@ func defaultProtocols() *http.Protocols {
 // The ServerConfig contains settings (with defaults) for configuring the underlying http.Server,
 // as well as some additional timeout related properties. The server properties correlate to
 // those found at https://pkg.go.dev/net/http#Server.
+//
+// Timeouts are set in whole seconds. Each has a matching Duration field, which takes precedence
+// when it is greater than zero, for timeouts that need more precision (such as 500ms). When set
+// via environment variables or a config file, the Duration fields accept Go duration strings
+// (such as 500ms or 1m30s), and plain numbers are treated as seconds.
 type ServerConfig struct {
 	TCPReadTimeout               int    `env:"TCP_READ_TIMEOUT"          envDefault:"17"`
 	TCPReadHeaderTimeout         int    `env:"TCP_READ_HEADER_TIMEOUT"   envDefault:"5"`
@@ -63,10 +69,53 @@ type ServerConfig struct {
 	HTTP2                        *http.HTTP2Config
 	Protocols                    *http.Protocols
 
+	// The Duration fields override the matching integer timeouts above when they are greater
+	// than zero.
+	TCPReadTimeoutDuration         time.Duration `env:"TCP_READ_TIMEOUT_DURATION"`
+	TCPReadHeaderTimeoutDuration   time.Duration `env:"TCP_READ_HEADER_TIMEOUT_DURATION"`
+	TCPIdleTimeoutDuration         time.Duration `env:"TCP_IDLE_TIMEOUT_DURATION"`
+	TCPWriteTimeoutDuration        time.Duration `env:"TCP_WRITE_TIMEOUT_DURATION"`
+	TCPWriteTimeoutPaddingDuration time.Duration `env:"TCP_WRITE_TIMEOUT_PADDING_DURATION"`
+	RequestTimeoutDuration         time.Duration `env:"HTTP_REQUEST_TIMEOUT_DURATION"`
+
+	// HTTPProtocols explicitly sets the protocols that the Server will accept, as a list of
+	// http1, h2 and h2c. Unlike Protocols, which is merged with the defaults, HTTPProtocols fully
+	// replaces them, so it can be used to disable protocols. Invalid names, or combinations that
+	// cannot be served on a listener (such as h2 only without TLS), are reported when the Server
+	// starts.
+	HTTPProtocols []string `env:"HTTP_PROTOCOLS"`
+
 	// TCPKeepAlive enables TCP keep-alive on connections. This is particularly important
 	// for long-lived connections like SSE, as it helps detect and close dead connections.
 	// If set to false, HTTP keep-alives are also disabled.
 	TCPKeepAlive bool `env:"TCP_KEEP_ALIVE" envDefault:"true"`
+
+	// PinTCPTimeouts stops the Server from automatically raising the TCP read and write timeouts
+	// to accommodate the longest request timeout. Validate will report a RequestTimeout that is
+	// longer than a pinned TCPWriteTimeout.
+	PinTCPTimeouts bool `env:"TCP_PIN_TIMEOUTS"`
+
+	// TrackConnections enables the Server's ConnTracker, which exposes live connection counts per
+	// state and per remote IP, and forcibly closes idle connections when the Server shuts down.
+	// Any user supplied ConnState hook will still be called.
+	TrackConnections bool `env:"TCP_TRACK_CONNECTIONS"`
+
+	// HTTPRedirectPort, if set, adds a plain HTTP listener on this port that redirects every
+	// request to HTTPS, apart from those under HTTPSRedirectExemptPrefix. HTTPSPort sets the port
+	// used in the redirect URL, and defaults to Port. The exempt prefix defaults to the ACME
+	// challenge path.
+	HTTPRedirectPort          int    `env:"HTTP_REDIRECT_PORT"`
+	HTTPSPort                 int    `env:"HTTPS_PORT"`
+	HTTPSRedirectExemptPrefix string `env:"HTTPS_REDIRECT_EXEMPT_PREFIX"`
+
+	// HSTSMaxAge, if greater than zero, sets the Strict-Transport-Security header (in seconds) on
+	// every response served over TLS.
+	HSTSMaxAge            int  `env:"HSTS_MAX_AGE"`
+	HSTSIncludeSubdomains bool `env:"HSTS_INCLUDE_SUBDOMAINS"`
+	HSTSPreload           bool `env:"HSTS_PRELOAD"`
+
+	// ClientAuth configures mutual TLS. It is applied when the Server starts.
+	ClientAuth ClientAuthConfig
 }
 
 // ------------------------------------------------------------------------------------------------
@@ -75,22 +124,61 @@ type ServerConfig struct {
 
 // The Config contains settings (with defaults) for configuring the app, server and router.
 type Config struct {
+	// Debug enables developer error pages, panic recovery, logging of 404s with suggestions of
+	// similar routes, and a startup banner. It exposes internal details, so it must never be
+	// enabled in production.
 	Debug  bool `env:"DEBUG"`
 	Server ServerConfig
+
+	// Admin configures an optional admin server for operational endpoints. See AdminConfig. Its
+	// environment variables are namespaced with ADMIN_SERVER_, such as ADMIN_SERVER_PORT.
+	Admin AdminConfig `envPrefix:"ADMIN_SERVER_"`
+
+	// ConfigFile is the path to an optional YAML, JSON or TOML config file. See LoadConfig for
+	// details.
+	ConfigFile string `env:"CONFIG_FILE"`
+
+	// EnvPrefix is prepended to every environment variable name, so that multiple Apps in the
+	// same process can be configured independently. For example, a prefix of INTERNAL_ reads
+	// INTERNAL_PORT rather than PORT. The prefix also applies to CONFIG_FILE and _FILE variables,
+	// but not to the keys within a config file.
+	EnvPrefix string
+
+	// sources records where each setting came from. It is populated by LoadConfig.
+	sources map[string]configOrigin
 }
 
-// loadConfig parses the environment variables defined in the Config objects (with defaults), then merges those
-// with the config that the user may have supplied. This function only gets called during app initialisation.
+// LoadConfig builds the config from layered sources, with each layer overriding the last -
+//
+//  1. The defaults.
+//  2. The config file, if one has been set via Config.ConfigFile or the CONFIG_FILE environment
+//     variable. Its keys are the environment variable names, in any case, and nested tables
+//     are joined with underscores (so tcp: {read_timeout: 2s} sets TCP_READ_TIMEOUT).
+//  3. Environment variables. Any variable can instead be read from a file, by setting the same
+//     name with a _FILE suffix (e.g. PORT_FILE), which is useful for secrets.
+//  4. The config supplied in code, which is merged on top.
 //
-// This function will return a completed config, or error if the environment variables cannot be parsed.
+// If Config.EnvPrefix is set, it is prepended to every environment variable name. The source of
+// each setting is recorded, and can be listed with Config.Settings.
+//
+// Finally, the config is validated. This function only gets called during app initialisation.
+//
+// This function will return a completed config, or error if the sources cannot be parsed, or the
+// resulting config is invalid.
 func LoadConfig(cfg Config) (Config, error) {
 	config := Config{}
-	if err := env.Parse(&config); err != nil {
+
+	vars, origins, err := configVars(cfg.ConfigFile, cfg.EnvPrefix)
+	if err != nil {
+		return config, err
+	}
+
+	if err := env.ParseWithOptions(&config, envOptions(vars, cfg.EnvPrefix)); err != nil {
 		return config, fmt.Errorf("failed to load environment variables: %v", err)
 	}
 
 	// Merge the main config
-	err := mergo.Merge(&config, cfg, mergo.WithOverride)
+	err = mergo.Merge(&config, cfg, mergo.WithOverride)
 	if err != nil {
 		return config, fmt.Errorf("failed to merge user supplied and default configs: %v", err)
 	}
@@ -104,5 +192,137 @@ func LoadConfig(cfg Config) (Config, error) {
 		)
 	}
 
+	config.sources = configSources(cfg, vars, origins)
+
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return config, nil
 }
+
+// ------------------------------------------------------------------------------------------------
+// VALIDATION
+// ------------------------------------------------------------------------------------------------
+
+// Validate checks the Config for invalid or inconsistent settings. Every problem found is
+// reported, joined into a single error.
+func (c Config) Validate() error {
+	errs := []error{c.Server.Validate()}
+
+	if c.Admin.Port < 0 || c.Admin.Port > 65535 {
+		errs = append(errs, fmt.Errorf(
+			"Admin.Port must be between 0 and 65535, got %d",
+			c.Admin.Port,
+		))
+	}
+	if c.Admin.Port > 0 &&
+		(c.Admin.Port == c.Server.Port || c.Admin.Port == c.Server.HTTPRedirectPort) {
+		errs = append(errs, fmt.Errorf(
+			"Admin.Port must differ from the Server ports (%d)",
+			c.Admin.Port,
+		))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the ServerConfig for invalid or inconsistent settings. Every problem found is
+// reported, joined into a single error.
+func (c ServerConfig) Validate() error {
+	var errs []error
+
+	durations := []struct {
+		name     string
+		seconds  int
+		duration time.Duration
+	}{
+		{"TCPReadTimeout", c.TCPReadTimeout, c.TCPReadTimeoutDuration},
+		{"TCPReadHeaderTimeout", c.TCPReadHeaderTimeout, c.TCPReadHeaderTimeoutDuration},
+		{"TCPIdleTimeout", c.TCPIdleTimeout, c.TCPIdleTimeoutDuration},
+		{"TCPWriteTimeout", c.TCPWriteTimeout, c.TCPWriteTimeoutDuration},
+		{"TCPWriteTimeoutPadding", c.TCPWriteTimeoutPadding, c.TCPWriteTimeoutPaddingDuration},
+		{"RequestTimeout", c.RequestTimeout, c.RequestTimeoutDuration},
+	}
+	for _, d := range durations {
+		if d.seconds < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", d.name, d.seconds))
+		}
+		switch {
+		case d.duration < 0:
+			errs = append(errs, fmt.Errorf(
+				"%sDuration must not be negative, got %s",
+				d.name,
+				d.duration,
+			))
+		case d.duration > 0 && d.duration < time.Millisecond:
+			// This is almost certainly a plain number of seconds set in code, where it is
+			// interpreted as nanoseconds.
+			errs = append(errs, fmt.Errorf(
+				"%sDuration of %s is less than 1ms; use a time.Duration such as 10 * time.Second",
+				d.name,
+				d.duration,
+			))
+		}
+	}
+
+	ports := []struct {
+		name  string
+		value int
+	}{
+		{"Port", c.Port},
+		{"HTTPRedirectPort", c.HTTPRedirectPort},
+		{"HTTPSPort", c.HTTPSPort},
+	}
+	for _, p := range ports {
+		if p.value < 0 || p.value > 65535 {
+			errs = append(errs, fmt.Errorf(
+				"%s must be between 0 and 65535, got %d",
+				p.name,
+				p.value,
+			))
+		}
+	}
+	if c.HTTPRedirectPort > 0 && c.HTTPRedirectPort == c.Port {
+		errs = append(errs, fmt.Errorf("HTTPRedirectPort must differ from Port (%d)", c.Port))
+	}
+
+	readTimeout := effectiveTimeout(c.TCPReadTimeout, c.TCPReadTimeoutDuration)
+	readHeaderTimeout := effectiveTimeout(c.TCPReadHeaderTimeout, c.TCPReadHeaderTimeoutDuration)
+	if readTimeout > 0 && readHeaderTimeout > readTimeout {
+		errs = append(errs, fmt.Errorf(
+			"TCPReadHeaderTimeout (%s) must not be longer than TCPReadTimeout (%s)",
+			readHeaderTimeout,
+			readTimeout,
+		))
+	}
+	writeTimeout := effectiveTimeout(c.TCPWriteTimeout, c.TCPWriteTimeoutDuration)
+	requestTimeout := effectiveTimeout(c.RequestTimeout, c.RequestTimeoutDuration)
+	if c.PinTCPTimeouts && writeTimeout > 0 && requestTimeout > writeTimeout {
+		errs = append(errs, fmt.Errorf(
+			"RequestTimeout (%s) must not be longer than the pinned TCPWriteTimeout (%s)",
+			requestTimeout,
+			writeTimeout,
+		))
+	}
+
+	if len(c.HTTPProtocols) > 0 {
+		if _, err := ParseProtocols(c.HTTPProtocols); err != nil {
+			errs = append(errs, fmt.Errorf("HTTPProtocols: %w", err))
+		}
+	}
+	if _, err := c.ClientAuth.tlsClientAuthType(); err != nil {
+		errs = append(errs, fmt.Errorf("ClientAuth: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// effectiveTimeout returns the passed Duration if it is greater than zero, or else the passed
+// number of seconds as a Duration.
+func effectiveTimeout(seconds int, d time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return time.Duration(seconds) * time.Second
+}
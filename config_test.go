@@ -1,9 +1,13 @@
 package rmhttp
 
 import (
+	"crypto/tls"
+	"net"
+	"net/http"
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -296,3 +300,73 @@ func Test_LoadConfig_with_user_partially_defined_config(t *testing.T) {
 		})
 	}
 }
+
+// Test_LoadConfig_preserves_http_server_fields checks that the http.Server related fields that
+// cannot be set via environment variables survive the merge with the default config.
+func Test_LoadConfig_preserves_http_server_fields(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	cfg, err := LoadConfig(Config{
+		Server: ServerConfig{
+			TLSConfig:      tlsConfig,
+			ConnState:      func(net.Conn, http.ConnState) {},
+			MaxHeaderBytes: 2048,
+		},
+	})
+	if err != nil {
+		t.Errorf("LoadConfig returned error: %v", err)
+	}
+
+	assert.Same(t, tlsConfig, cfg.Server.TLSConfig)
+	assert.NotNil(t, cfg.Server.ConnState)
+	assert.Equal(t, 2048, cfg.Server.MaxHeaderBytes)
+}
+
+// Test_LoadConfig_protocols_from_env checks that the explicit protocol list can be set via an
+// environment variable.
+func Test_LoadConfig_protocols_from_env(t *testing.T) {
+	t.Setenv("HTTP_PROTOCOLS", "http1,h2")
+
+	cfg, err := LoadConfig(Config{})
+	if err != nil {
+		t.Errorf("LoadConfig returned error: %v", err)
+	}
+
+	assert.Equal(t, []string{"http1", "h2"}, cfg.Server.HTTPProtocols)
+}
+
+// Test_LoadConfig_timeout_durations checks that integer timeouts keep their meaning of whole
+// seconds, and that the Duration fields can be set via environment variables and take
+// precedence.
+func Test_LoadConfig_timeout_durations(t *testing.T) {
+	t.Setenv("TCP_READ_TIMEOUT", "30")
+	t.Setenv("TCP_WRITE_TIMEOUT_DURATION", "1m30s")
+	t.Setenv("TCP_IDLE_TIMEOUT_DURATION", "45")
+
+	cfg, err := LoadConfig(Config{
+		Server: ServerConfig{
+			RequestTimeout:         120,
+			TCPReadHeaderTimeout:   2,
+			TCPReadTimeoutDuration: 2500 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Errorf("LoadConfig returned error: %v", err)
+	}
+
+	assert.Equal(t, 30, cfg.Server.TCPReadTimeout)
+	assert.Equal(t, 2500*time.Millisecond, cfg.Server.TCPReadTimeoutDuration)
+	assert.Equal(t, 90*time.Second, cfg.Server.TCPWriteTimeoutDuration)
+	assert.Equal(t, 45*time.Second, cfg.Server.TCPIdleTimeoutDuration)
+
+	srv := NewServer(cfg.Server, nil)
+	assert.Equal(t, 2500*time.Millisecond, srv.Server.ReadTimeout)
+	assert.Equal(t, 2*time.Second, srv.Server.ReadHeaderTimeout)
+	assert.Equal(t, 90*time.Second, srv.Server.WriteTimeout)
+	assert.Equal(t, 45*time.Second, srv.Server.IdleTimeout)
+
+	srv.maybeUpdateTimeout(
+		effectiveTimeout(cfg.Server.RequestTimeout, cfg.Server.RequestTimeoutDuration),
+	)
+	assert.Equal(t, 123*time.Second, srv.Server.ReadTimeout)
+	assert.Equal(t, 121*time.Second, srv.Server.WriteTimeout)
+}
@@ -0,0 +1,191 @@
+package rmhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	env "github.com/caarlos0/env/v11"
+	"gopkg.in/yaml.v3"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CONFIG SOURCES
+// ------------------------------------------------------------------------------------------------
+
+// secretFileSuffix is appended to a variable name to read its value from a file instead.
+const secretFileSuffix = "_FILE"
+
+// configOrigin records where the value of a config variable came from.
+type configOrigin struct {
+	source SettingSource
+	from   string
+	secret bool
+}
+
+// envOptions returns the options used to parse the passed variables into a Config.
+func envOptions(vars map[string]string, prefix string) env.Options {
+	return env.Options{
+		Environment: vars,
+		Prefix:      prefix,
+		FuncMap: map[reflect.Type]env.ParserFunc{
+			reflect.TypeFor[time.Duration](): parseDuration,
+		},
+	}
+}
+
+// parseDuration parses a Go duration string. Plain numbers are treated as seconds, so that
+// configs written when timeouts were integer seconds continue to work.
+func parseDuration(value string) (any, error) {
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	return d, nil
+}
+
+// configVars builds the map of variables that the Config will be parsed from, along with the
+// origin of each one. The config file (if any) is read first, and then overridden by the
+// environment. Finally, any variables that have been set via a _FILE variable are resolved.
+//
+// The prefix is prepended to the config file keys, so that they match the prefixed environment
+// variable names.
+func configVars(file string, prefix string) (map[string]string, map[string]configOrigin, error) {
+	environment := env.ToMap(os.Environ())
+	if file == "" {
+		file = environment[prefix+"CONFIG_FILE"]
+	}
+
+	vars := map[string]string{}
+	origins := map[string]configOrigin{}
+	if file != "" {
+		fileVars, err := readConfigFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, value := range fileVars {
+			vars[prefix+key] = value
+			origins[prefix+key] = configOrigin{source: SourceFile, from: file}
+		}
+	}
+	maps.Copy(vars, environment)
+	for key := range environment {
+		origins[key] = configOrigin{source: SourceEnv, from: key}
+	}
+
+	if err := resolveSecretFiles(vars, origins, prefix); err != nil {
+		return nil, nil, err
+	}
+	return vars, origins, nil
+}
+
+// readConfigFile reads the passed YAML, JSON or TOML file (determined by its extension), and
+// flattens it into a map of variable names to values.
+func readConfigFile(file string) (map[string]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", file, err)
+	}
+
+	vars := map[string]string{}
+	flattenConfig(vars, "", raw)
+	return vars, nil
+}
+
+// flattenConfig converts a nested config map into variable names and values. Keys are
+// uppercased and nested keys are joined with underscores, while lists are joined with commas.
+func flattenConfig(vars map[string]string, prefix string, values map[string]any) {
+	for key, value := range values {
+		name := prefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		switch v := value.(type) {
+		case map[string]any:
+			flattenConfig(vars, name+"_", v)
+		case []any:
+			parts := make([]string, 0, len(v))
+			for _, part := range v {
+				parts = append(parts, configValueString(part))
+			}
+			vars[name] = strings.Join(parts, ",")
+		default:
+			vars[name] = configValueString(v)
+		}
+	}
+}
+
+// configValueString converts a single decoded config value into a string.
+func configValueString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Duration:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// resolveSecretFiles sets the value of every known config variable that has not been set
+// directly, but has a matching _FILE variable, to the contents of that file. A single trailing
+// newline is removed from the contents, and the value is marked as secret.
+func resolveSecretFiles(
+	vars map[string]string,
+	origins map[string]configOrigin,
+	prefix string,
+) error {
+	for _, key := range configKeys(prefix) {
+		file, ok := vars[key+secretFileSuffix]
+		if !ok || file == "" || vars[key] != "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s%s: %w", key, secretFileSuffix, err)
+		}
+		value := strings.TrimSuffix(string(data), "\n")
+		vars[key] = strings.TrimSuffix(value, "\r")
+		origins[key] = configOrigin{source: SourceEnv, from: key + secretFileSuffix, secret: true}
+	}
+	return nil
+}
+
+// configKeys returns the (prefixed) variable names of every field in the Config.
+func configKeys(prefix string) []string {
+	params, err := env.GetFieldParamsWithOptions(&Config{}, env.Options{Prefix: prefix})
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(params))
+	for _, p := range params {
+		keys = append(keys, p.Key)
+	}
+	return keys
+}
@@ -0,0 +1,231 @@
+package rmhttp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CONFIG SOURCE TESTS
+// ------------------------------------------------------------------------------------------------
+
+// writeTestConfigFile writes the passed content to a file with the passed name in a temporary
+// directory, and returns its path.
+func writeTestConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// Test_parseDuration checks that durations can be set as Go duration strings or plain seconds.
+func Test_parseDuration(t *testing.T) {
+	tests := []struct {
+		value         string
+		expected      time.Duration
+		errorExpected bool
+	}{
+		{"10", 10 * time.Second, false},
+		{" 500ms ", 500 * time.Millisecond, false},
+		{"1m30s", 90 * time.Second, false},
+		{"0", 0, false},
+		{"ten", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			d, err := parseDuration(test.value)
+			if test.errorExpected {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, d)
+		})
+	}
+}
+
+// Test_LoadConfig_file_formats checks that YAML, JSON and TOML config files are all supported,
+// including nested keys and lists.
+func Test_LoadConfig_file_formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			"config.yaml",
+			"port: 9000\ntcp:\n  read_timeout_duration: 30s\nhttp_protocols: [http1, h2]\n",
+		},
+		{
+			"config.json",
+			`{"PORT": 9000, "TCP_READ_TIMEOUT_DURATION": "30s", "http_protocols": ["http1", "h2"]}`,
+		},
+		{
+			"config.toml",
+			"port = 9000\nhttp_protocols = [\"http1\", \"h2\"]\n" +
+				"[tcp]\nread_timeout_duration = \"30s\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeTestConfigFile(t, test.name, test.content)
+			cfg, err := LoadConfig(Config{ConfigFile: path})
+			require.NoError(t, err)
+			assert.Equal(t, 9000, cfg.Server.Port)
+			assert.Equal(t, 30*time.Second, cfg.Server.TCPReadTimeoutDuration)
+			assert.Equal(t, []string{"http1", "h2"}, cfg.Server.HTTPProtocols)
+			assert.Equal(t, defaultServerConfig.TCPIdleTimeout, cfg.Server.TCPIdleTimeout)
+		})
+	}
+}
+
+// Test_LoadConfig_layering checks that each config source overrides the one before it.
+func Test_LoadConfig_layering(t *testing.T) {
+	path := writeTestConfigFile(
+		t,
+		"config.yaml",
+		"host: file-host\nport: 9000\nhttp_timeout_message: from file\n",
+	)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("PORT", "9001")
+	t.Setenv("HTTP_TIMEOUT_MESSAGE", "from env")
+
+	cfg, err := LoadConfig(Config{Server: ServerConfig{TimeoutMessage: "from code"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, path, cfg.ConfigFile)
+	assert.Equal(t, "file-host", cfg.Server.Host)
+	assert.Equal(t, 9001, cfg.Server.Port)
+	assert.Equal(t, "from code", cfg.Server.TimeoutMessage)
+	assert.Equal(t, defaultServerConfig.TCPReadTimeout, cfg.Server.TCPReadTimeout)
+}
+
+// Test_LoadConfig_file_errors checks that unreadable or unsupported config files are reported.
+func Test_LoadConfig_file_errors(t *testing.T) {
+	_, err := LoadConfig(Config{ConfigFile: "/nonexistent/config.yaml"})
+	assert.Error(t, err)
+
+	_, err = LoadConfig(Config{ConfigFile: writeTestConfigFile(t, "config.ini", "port=1")})
+	assert.ErrorContains(t, err, "unsupported config file format")
+
+	_, err = LoadConfig(Config{ConfigFile: writeTestConfigFile(t, "config.json", "{")})
+	assert.ErrorContains(t, err, "failed to parse config file")
+}
+
+// Test_LoadConfig_secret_files checks that variables can be read from _FILE variables, and that
+// directly set variables take precedence.
+func Test_LoadConfig_secret_files(t *testing.T) {
+	t.Setenv("HTTP_TIMEOUT_MESSAGE_FILE", writeTestConfigFile(t, "message", "from a file\n"))
+	t.Setenv("HOST_FILE", writeTestConfigFile(t, "host", "ignored"))
+	t.Setenv("HOST", "from-env")
+
+	cfg, err := LoadConfig(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "from a file", cfg.Server.TimeoutMessage)
+	assert.Equal(t, "from-env", cfg.Server.Host)
+
+	t.Setenv("PORT_FILE", "/nonexistent/port")
+	_, err = LoadConfig(Config{})
+	assert.ErrorContains(t, err, "PORT_FILE")
+}
+
+// Test_ServerConfig_Validate checks that invalid and inconsistent settings are reported.
+func Test_ServerConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		modify        func(c *ServerConfig)
+		expectedError string
+	}{
+		{"default config is valid", func(c *ServerConfig) {}, ""},
+		{
+			"negative timeout",
+			func(c *ServerConfig) { c.TCPIdleTimeout = -1 },
+			"TCPIdleTimeout must not be negative",
+		},
+		{
+			"negative duration",
+			func(c *ServerConfig) { c.TCPIdleTimeoutDuration = -time.Second },
+			"TCPIdleTimeoutDuration must not be negative",
+		},
+		{
+			"duration set as plain seconds in code",
+			func(c *ServerConfig) { c.RequestTimeoutDuration = 10 },
+			"RequestTimeoutDuration of 10ns is less than 1ms",
+		},
+		{
+			"port out of range",
+			func(c *ServerConfig) { c.Port = 70000 },
+			"Port must be between 0 and 65535",
+		},
+		{
+			"redirect port equal to port",
+			func(c *ServerConfig) { c.HTTPRedirectPort = c.Port },
+			"HTTPRedirectPort must differ from Port",
+		},
+		{
+			"read header timeout longer than read timeout",
+			func(c *ServerConfig) { c.TCPReadHeaderTimeoutDuration = time.Minute },
+			"TCPReadHeaderTimeout (1m0s) must not be longer than TCPReadTimeout",
+		},
+		{
+			"request timeout longer than unpinned write timeout",
+			func(c *ServerConfig) { c.RequestTimeout = 60 },
+			"",
+		},
+		{
+			"request timeout longer than pinned write timeout",
+			func(c *ServerConfig) {
+				c.RequestTimeoutDuration = time.Minute
+				c.PinTCPTimeouts = true
+			},
+			"RequestTimeout (1m0s) must not be longer than the pinned TCPWriteTimeout (12s)",
+		},
+		{
+			"invalid protocols",
+			func(c *ServerConfig) { c.HTTPProtocols = []string{"gopher"} },
+			"HTTPProtocols: unknown HTTP protocol",
+		},
+		{
+			"invalid client auth mode",
+			func(c *ServerConfig) { c.ClientAuth.Mode = "maybe" },
+			"ClientAuth: invalid client auth mode",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := defaultServerConfig
+			test.modify(&c)
+			err := c.Validate()
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, test.expectedError)
+		})
+	}
+}
+
+// Test_LoadConfig_validates checks that LoadConfig rejects an invalid config.
+func Test_LoadConfig_validates(t *testing.T) {
+	_, err := LoadConfig(Config{Server: ServerConfig{Port: -1}})
+	assert.ErrorContains(t, err, "invalid config")
+}
+
+// Test_maybeUpdateTimeout_pinned checks that pinned TCP timeouts are never raised.
+func Test_maybeUpdateTimeout_pinned(t *testing.T) {
+	srv := NewServer(ServerConfig{
+		TCPReadTimeout:  5,
+		TCPWriteTimeout: 5,
+		PinTCPTimeouts:  true,
+	}, nil)
+	srv.maybeUpdateTimeout(time.Minute)
+	assert.Equal(t, 5*time.Second, srv.Server.ReadTimeout)
+	assert.Equal(t, 5*time.Second, srv.Server.WriteTimeout)
+}
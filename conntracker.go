@@ -0,0 +1,135 @@
+package rmhttp
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CONNECTION TRACKER
+// ------------------------------------------------------------------------------------------------
+
+// trackedConn holds the last known state and remote IP of a tracked connection.
+type trackedConn struct {
+	state http.ConnState
+	ip    string
+}
+
+// A ConnTracker keeps track of the connections open on a Server. It provides live counts of
+// connections per http.ConnState and per remote IP, and allows idle connections to be
+// forcibly closed.
+//
+// ConnTracker is safe for concurrent use.
+type ConnTracker struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]trackedConn
+	states map[http.ConnState]int
+	ips    map[string]int
+}
+
+// NewConnTracker creates, initialises, and returns a pointer to a ConnTracker.
+func NewConnTracker() *ConnTracker {
+	return &ConnTracker{
+		conns:  make(map[net.Conn]trackedConn),
+		states: make(map[http.ConnState]int),
+		ips:    make(map[string]int),
+	}
+}
+
+// ConnState records a connection state change. Its signature matches http.Server.ConnState, so
+// it can be used directly as the hook.
+func (ct *ConnTracker) ConnState(c net.Conn, state http.ConnState) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	tc, ok := ct.conns[c]
+	if ok {
+		ct.states[tc.state]--
+		if ct.states[tc.state] <= 0 {
+			delete(ct.states, tc.state)
+		}
+	}
+
+	// Hijacked and closed connections are no longer managed by the Server, so we stop tracking
+	// them altogether.
+	if state == http.StateHijacked || state == http.StateClosed {
+		if ok {
+			delete(ct.conns, c)
+			ct.ips[tc.ip]--
+			if ct.ips[tc.ip] <= 0 {
+				delete(ct.ips, tc.ip)
+			}
+		}
+		return
+	}
+
+	if !ok {
+		tc.ip = remoteIP(c)
+		ct.ips[tc.ip]++
+	}
+	tc.state = state
+	ct.conns[c] = tc
+	ct.states[state]++
+}
+
+// Count returns the number of currently open connections.
+func (ct *ConnTracker) Count() int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	return len(ct.conns)
+}
+
+// CountByState returns a snapshot of the number of open connections in each http.ConnState.
+func (ct *ConnTracker) CountByState() map[http.ConnState]int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	counts := make(map[http.ConnState]int, len(ct.states))
+	for state, count := range ct.states {
+		counts[state] = count
+	}
+	return counts
+}
+
+// CountByIP returns a snapshot of the number of open connections for each remote IP.
+func (ct *ConnTracker) CountByIP() map[string]int {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	counts := make(map[string]int, len(ct.ips))
+	for ip, count := range ct.ips {
+		counts[ip] = count
+	}
+	return counts
+}
+
+// CloseIdle forcibly closes every connection that is currently idle, and returns the number of
+// connections that were closed. The Server will report the closed state for each connection,
+// so they will be removed from the tracker via ConnState.
+func (ct *ConnTracker) CloseIdle() int {
+	ct.mu.Lock()
+	idle := make([]net.Conn, 0, ct.states[http.StateIdle])
+	for c, tc := range ct.conns {
+		if tc.state == http.StateIdle {
+			idle = append(idle, c)
+		}
+	}
+	ct.mu.Unlock()
+
+	for _, c := range idle {
+		_ = c.Close()
+	}
+	return len(idle)
+}
+
+// remoteIP returns the IP address of the remote end of the passed connection, without the port.
+func remoteIP(c net.Conn) string {
+	addr := c.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
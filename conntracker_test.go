@@ -0,0 +1,124 @@
+package rmhttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CONNECTION TRACKER TESTS
+// ------------------------------------------------------------------------------------------------
+
+// testConn is a minimal net.Conn with a configurable remote address.
+type testConn struct {
+	net.Conn
+	remote net.Addr
+	closed bool
+}
+
+func (c *testConn) RemoteAddr() net.Addr { return c.remote }
+func (c *testConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newTestConn(addr string) *testConn {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	return &testConn{remote: tcpAddr}
+}
+
+// Test_ConnTracker_counts checks that state and IP counts follow connection state changes.
+func Test_ConnTracker_counts(t *testing.T) {
+	ct := NewConnTracker()
+	a := newTestConn("10.0.0.1:1000")
+	b := newTestConn("10.0.0.1:1001")
+	c := newTestConn("10.0.0.2:1000")
+
+	ct.ConnState(a, http.StateNew)
+	ct.ConnState(b, http.StateNew)
+	ct.ConnState(c, http.StateNew)
+	ct.ConnState(a, http.StateActive)
+	ct.ConnState(b, http.StateActive)
+	ct.ConnState(b, http.StateIdle)
+
+	assert.Equal(t, 3, ct.Count())
+	assert.Equal(
+		t,
+		map[http.ConnState]int{http.StateNew: 1, http.StateActive: 1, http.StateIdle: 1},
+		ct.CountByState(),
+	)
+	assert.Equal(t, map[string]int{"10.0.0.1": 2, "10.0.0.2": 1}, ct.CountByIP())
+
+	ct.ConnState(a, http.StateClosed)
+	ct.ConnState(c, http.StateHijacked)
+
+	assert.Equal(t, 1, ct.Count())
+	assert.Equal(t, map[http.ConnState]int{http.StateIdle: 1}, ct.CountByState())
+	assert.Equal(t, map[string]int{"10.0.0.1": 1}, ct.CountByIP())
+
+	// A closed state for an unknown connection should be ignored.
+	ct.ConnState(newTestConn("10.0.0.3:1000"), http.StateClosed)
+	assert.Equal(t, 1, ct.Count())
+}
+
+// Test_ConnTracker_CloseIdle checks that only idle connections are closed.
+func Test_ConnTracker_CloseIdle(t *testing.T) {
+	ct := NewConnTracker()
+	active := newTestConn("10.0.0.1:1000")
+	idle := newTestConn("10.0.0.1:1001")
+
+	ct.ConnState(active, http.StateActive)
+	ct.ConnState(idle, http.StateIdle)
+
+	assert.Equal(t, 1, ct.CloseIdle())
+	assert.True(t, idle.closed)
+	assert.False(t, active.closed)
+}
+
+// Test_ConnTracker_server checks that a Server with connection tracking enabled records real
+// connections, calls the user ConnState hook, and closes idle connections on shutdown.
+func Test_ConnTracker_server(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	states := make(chan http.ConnState, 16)
+	srv := NewServer(ServerConfig{
+		TrackConnections: true,
+		TCPKeepAlive:     true,
+		ConnState: func(c net.Conn, state http.ConnState) {
+			states <- state
+		},
+	}, http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "ok")))
+	require.NotNil(t, srv.ConnTracker)
+
+	go func() {
+		_ = srv.Server.Serve(ln)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	res, err := client.Get("http://" + ln.Addr().String())
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	assert.Eventually(t, func() bool {
+		return srv.ConnTracker.CountByState()[http.StateIdle] == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]int{"127.0.0.1": 1}, srv.ConnTracker.CountByIP())
+	assert.Equal(t, http.StateNew, <-states)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+
+	assert.Eventually(t, func() bool {
+		return srv.ConnTracker.Count() == 0
+	}, time.Second, 10*time.Millisecond)
+}
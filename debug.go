@@ -0,0 +1,372 @@
+package rmhttp
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/recoverer"
+)
+
+// ------------------------------------------------------------------------------------------------
+// DEBUG MODE
+// ------------------------------------------------------------------------------------------------
+//
+// When Config.Debug is set, the App -
+//
+//   - replaces the default 404, 405 and 500 handlers with developer error pages, which show the
+//     panic and stack (if any), a dump of the request, the matched route and its middleware
+//     chain, "did you mean" suggestions, and the route table
+//   - recovers panics in every route, and responds with the 500 handler
+//   - logs every 404 with suggestions based on similar registered patterns
+//   - writes a startup banner listing the routes and effective config
+//
+// Debug mode exposes internal details of the App, so it must never be enabled in production.
+
+// maxSuggestions is the maximum number of similar routes suggested for a 404.
+const maxSuggestions = 3
+
+// redactedHeaders are the request headers whose values are hidden in request dumps.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// funcSuffix matches the suffixes that the compiler adds to the names of closures and method
+// values.
+var funcSuffix = regexp.MustCompile(`(\.func\d+|\.gowrap\d+|-fm|\.\d+)+$`)
+
+// majorVersion matches the major version suffix of a module path in a function name.
+var majorVersion = regexp.MustCompile(`/v\d+\.`)
+
+// debugRoute describes a compiled Route, for the debug pages and startup banner.
+type debugRoute struct {
+	Method     string
+	Pattern    string
+	Middleware []string
+	Timeout    string
+}
+
+// debugPage holds everything shown on a developer error page.
+type debugPage struct {
+	Status      int
+	StatusText  string
+	Method      string
+	Path        string
+	Panic       string
+	Stack       string
+	Request     string
+	Route       string
+	Middleware  []string
+	Suggestions []string
+	Routes      []debugRoute
+}
+
+// debugErrorHandler returns a handler that responds with a developer error page for the passed
+// status code. The page is HTML if the client accepts it, and plain text otherwise.
+func (app *App) debugErrorHandler(code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := app.newDebugPage(r, code)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(code)
+			_ = debugTemplate.Execute(w, page)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		page.writeText(w)
+	})
+}
+
+// newDebugPage gathers the details of the passed request for a developer error page.
+func (app *App) newDebugPage(r *http.Request, code int) *debugPage {
+	page := &debugPage{
+		Status:     code,
+		StatusText: http.StatusText(code),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Request:    dumpRequest(r),
+		Route:      r.Pattern,
+		Middleware: app.debugChains[r.Pattern],
+		Routes:     app.debugRoutes(),
+	}
+	if p, ok := recoverer.FromContext(r.Context()); ok {
+		page.Panic = p.Error()
+		page.Stack = string(p.Stack)
+	}
+	if code == http.StatusNotFound {
+		page.Suggestions = app.suggestRoutes(r.URL.Path)
+	}
+	return page
+}
+
+// writeText writes the page as plain text.
+func (page *debugPage) writeText(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "%d %s: %s %s\n", page.Status, page.StatusText, page.Method, page.Path)
+	if page.Panic != "" {
+		_, _ = fmt.Fprintf(w, "\n%s\n\n%s\n", page.Panic, page.Stack)
+	}
+	if page.Route != "" {
+		_, _ = fmt.Fprintf(w, "\nRoute: %s\n", page.Route)
+		_, _ = fmt.Fprintf(w, "Middleware: %s\n", strings.Join(page.Middleware, " → "))
+	}
+	if len(page.Suggestions) > 0 {
+		_, _ = fmt.Fprintf(w, "\nDid you mean:\n")
+		for _, suggestion := range page.Suggestions {
+			_, _ = fmt.Fprintf(w, "  %s\n", suggestion)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\nRequest:\n%s\n", page.Request)
+	_, _ = fmt.Fprintf(w, "Routes:\n")
+	writeRouteTable(w, page.Routes)
+}
+
+// logNotFound wraps the passed 404 handler, so that each 404 is logged along with suggestions of
+// similar registered routes.
+func (app *App) logNotFound(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Warn(
+			"route not found",
+			"type", "debug",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"suggestions", app.suggestRoutes(r.URL.Path),
+		)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeBanner writes the startup banner, listing the addresses, routes and effective config.
+func (app *App) writeBanner(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "rmhttp is running in debug mode. Do not use debug mode in production.\n\n")
+
+	_, _ = fmt.Fprintf(w, "Listening on:\n")
+	_, _ = fmt.Fprintf(w, "  %s\n", app.Server.Server.Addr)
+	app.Server.mu.Lock()
+	for _, l := range app.Server.listeners {
+		_, _ = fmt.Fprintf(w, "  %s\n", l.Addr())
+	}
+	app.Server.mu.Unlock()
+	if app.Admin != nil {
+		_, _ = fmt.Fprintf(w, "  %s (admin)\n", app.Admin.Server.Addr)
+	}
+
+	_, _ = fmt.Fprintf(w, "\nRoutes:\n")
+	writeRouteTable(w, app.debugRoutes())
+
+	_, _ = fmt.Fprintf(w, "\nConfig:\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, setting := range app.Settings() {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t(%s)\n", setting.Name, setting.Value, setting.Source)
+	}
+	_ = tw.Flush()
+	_, _ = fmt.Fprintln(w)
+}
+
+// writeRouteTable writes the passed routes as an aligned table.
+func writeRouteTable(w io.Writer, routes []debugRoute) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, route := range routes {
+		_, _ = fmt.Fprintf(
+			tw,
+			"  %s\t%s\t%s\t%s\n",
+			route.Method,
+			route.Pattern,
+			route.Timeout,
+			strings.Join(route.Middleware, " → "),
+		)
+	}
+	_ = tw.Flush()
+}
+
+// debugRoutes returns the compiled Routes, sorted by pattern and then method.
+func (app *App) debugRoutes() []debugRoute {
+	routes := []debugRoute{}
+	for _, route := range app.Routes() {
+		timeout := ""
+		if t := route.ComputedTimeout(); t.Enabled {
+			timeout = t.Duration.String()
+		}
+		pattern := route.ComputedPattern()
+		routes = append(routes, debugRoute{
+			Method:     route.Method,
+			Pattern:    pattern,
+			Middleware: app.debugChains[route.Method+" "+pattern],
+			Timeout:    timeout,
+		})
+	}
+	slices.SortFunc(routes, func(a, b debugRoute) int {
+		return cmp.Or(cmp.Compare(a.Pattern, b.Pattern), cmp.Compare(a.Method, b.Method))
+	})
+	return routes
+}
+
+// suggestRoutes returns up to maxSuggestions registered routes whose patterns are similar to
+// the passed path, most similar first.
+func (app *App) suggestRoutes(path string) []string {
+	type suggestion struct {
+		route    string
+		distance int
+	}
+	limit := max(2, len(path)/3)
+	suggestions := []suggestion{}
+	for _, route := range app.debugRoutes() {
+		if d := patternDistance(route.Pattern, path); d <= limit {
+			suggestions = append(suggestions, suggestion{route.Method + " " + route.Pattern, d})
+		}
+	}
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		return cmp.Compare(a.distance, b.distance)
+	})
+
+	routes := []string{}
+	for _, s := range suggestions[:min(len(suggestions), maxSuggestions)] {
+		routes = append(routes, s.route)
+	}
+	return routes
+}
+
+// patternDistance returns the edit distance between the passed path and the closest path that
+// the passed pattern would match. Wildcard segments match the corresponding path segments.
+func patternDistance(pattern string, path string) int {
+	// Patterns may be prefixed with a host, which isn't part of the path.
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	for i, segment := range patternSegments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if strings.HasSuffix(segment, "...}") {
+			rest := pathSegments[min(i, len(pathSegments)):]
+			patternSegments = append(patternSegments[:i:i], rest...)
+			break
+		}
+		if segment == "{$}" {
+			patternSegments[i] = ""
+		} else if i < len(pathSegments) {
+			patternSegments[i] = pathSegments[i]
+		}
+	}
+	return levenshtein(strings.Join(patternSegments, "/"), path)
+}
+
+// levenshtein returns the number of single byte insertions, deletions and substitutions needed
+// to turn a into b.
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// middlewareNames returns a readable name for each of the passed middleware functions, such as
+// recoverer.Middleware.
+func middlewareNames(middlewares []func(http.Handler) http.Handler) []string {
+	names := make([]string, 0, len(middlewares))
+	for _, middleware := range middlewares {
+		name := "unknown"
+		if fn := runtime.FuncForPC(reflect.ValueOf(middleware).Pointer()); fn != nil {
+			name = majorVersion.ReplaceAllString(fn.Name(), ".")
+			name = name[strings.LastIndexByte(name, '/')+1:]
+			name = funcSuffix.ReplaceAllString(name, "")
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// dumpRequest returns the request line and headers of the passed request, with credentials
+// redacted.
+func dumpRequest(r *http.Request) string {
+	clone := r.Clone(r.Context())
+	for _, key := range redactedHeaders {
+		if clone.Header.Get(key) != "" {
+			clone.Header.Set(key, RedactedValue)
+		}
+	}
+	dump, err := httputil.DumpRequest(clone, false)
+	if err != nil {
+		return err.Error()
+	}
+	return strings.ReplaceAll(strings.TrimSpace(string(dump)), "\r\n", "\n")
+}
+
+// debugTemplate renders the HTML developer error page.
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Status}} {{.StatusText}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 0; color: #222; }
+header { background: #b3261e; color: #fff; padding: 1.5rem 2rem; }
+header h1 { margin: 0 0 .25rem; font-size: 1.5rem; }
+section { padding: 1rem 2rem; border-bottom: 1px solid #eee; }
+h2 { font-size: 1.1rem; }
+pre { background: #f6f6f6; padding: 1rem; overflow-x: auto; font-size: .85rem; }
+table { border-collapse: collapse; font-size: .9rem; }
+td, th { text-align: left; padding: .25rem 1rem .25rem 0; vertical-align: top; }
+code { font-size: .9rem; }
+.matched { font-weight: bold; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Status}} {{.StatusText}}</h1>
+<div>{{.Method}} {{.Path}}</div>
+</header>
+{{if .Panic}}<section>
+<h2>{{.Panic}}</h2>
+<pre>{{.Stack}}</pre>
+</section>{{end}}
+{{if .Suggestions}}<section>
+<h2>Did you mean</h2>
+<ul>{{range .Suggestions}}<li><code>{{.}}</code></li>{{end}}</ul>
+</section>{{end}}
+{{if .Route}}<section>
+<h2>Matched route</h2>
+<p><code>{{.Route}}</code></p>
+<h2>Middleware</h2>
+<ol>{{range .Middleware}}<li><code>{{.}}</code></li>{{else}}<li>None</li>{{end}}</ol>
+</section>{{end}}
+<section>
+<h2>Request</h2>
+<pre>{{.Request}}</pre>
+</section>
+<section>
+<h2>Routes</h2>
+<table>
+<tr><th>Method</th><th>Pattern</th><th>Timeout</th><th>Middleware</th></tr>
+{{range .Routes}}<tr><td>{{.Method}}</td><td><code>{{.Pattern}}</code></td><td>{{.Timeout}}</td>
+<td>{{range $i, $m := .Middleware}}{{if $i}} → {{end}}<code>{{$m}}</code>{{end}}</td></tr>
+{{end}}</table>
+</section>
+</body>
+</html>
+`))
@@ -0,0 +1,164 @@
+package rmhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// DEBUG MODE TESTS
+// ------------------------------------------------------------------------------------------------
+
+// newTestDebugApp returns a compiled App in debug mode with a few routes.
+func newTestDebugApp() *App {
+	app := New(Config{Debug: true})
+	app.Use(createTestMiddlewareHandler("X-Global", "global"))
+	app.Get("/users", createTestHandlerFunc(http.StatusOK, "users"))
+	app.Get("/users/{id}", createTestHandlerFunc(http.StatusOK, "user"))
+	app.Post("/orders", createTestHandlerFunc(http.StatusCreated, "order")).
+		WithTimeout(5*time.Second, "timeout")
+	app.Get("/explode", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		panic("kaboom")
+	})
+	app.Compile()
+	return app
+}
+
+// Test_Debug_panic_page checks that panics are recovered in debug mode, and respond with a
+// developer error page showing the panic, request, matched route and route table.
+func Test_Debug_panic_page(t *testing.T) {
+	app := newTestDebugApp()
+
+	req := httptest.NewRequest(http.MethodGet, "/explode", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, body, "panic: kaboom")
+	assert.Contains(t, body, "debug_test.go")
+	assert.Contains(t, body, "<code>GET /explode</code>")
+	assert.Contains(t, body, "<code>recoverer.Middleware</code>")
+	assert.Contains(t, body, "<code>/users/{id}</code>")
+	assert.Contains(t, body, "Authorization: [REDACTED]")
+	assert.NotContains(t, body, "secret-token")
+
+	// Clients that don't accept HTML get the same details as plain text.
+	req.Header.Del("Accept")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	body = w.Body.String()
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(body, "500 Internal Server Error: GET /explode\n"))
+	assert.Contains(t, body, "Route: GET /explode\n")
+	assert.Contains(t, body, "recoverer.Middleware → rmhttp.createTestMiddlewareHandler\n")
+	assert.Contains(t, body, "POST  /orders")
+}
+
+// Test_Debug_not_found checks that 404s are logged, and show suggestions of similar routes.
+func Test_Debug_not_found(t *testing.T) {
+	app := newTestDebugApp()
+
+	out.Reset()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usres/42", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "Did you mean:\n  GET /users/{id}\n")
+
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "route not found", record["msg"])
+	assert.Equal(t, "/usres/42", record["path"])
+	assert.Equal(t, []any{"GET /users/{id}"}, record["suggestions"])
+
+	// Custom handlers are still used, and 404s are still logged.
+	app = New(Config{Debug: true})
+	app.Get("/users", createTestHandlerFunc(http.StatusOK, "users"))
+	app.StatusNotFoundHandler(createTestHandlerFunc(http.StatusNotFound, "custom"))
+	out.Reset()
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, "custom", w.Body.String())
+	assert.Contains(t, out.String(), `"suggestions":["GET /users"]`)
+}
+
+// Test_Debug_disabled checks that nothing changes when debug mode is off.
+func Test_Debug_disabled(t *testing.T) {
+	app := New(Config{})
+	app.Get("/users", createTestHandlerFunc(http.StatusOK, "users"))
+
+	out.Reset()
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, "Not Found", w.Body.String())
+	assert.Empty(t, out.String())
+	assert.Empty(t, app.debugChains)
+}
+
+// Test_Debug_banner checks that the startup banner lists the routes and config.
+func Test_Debug_banner(t *testing.T) {
+	app := newTestDebugApp()
+
+	buf := &bytes.Buffer{}
+	app.writeBanner(buf)
+	banner := buf.String()
+	assert.Contains(t, banner, "debug mode")
+	assert.Contains(t, banner, app.Server.Server.Addr)
+	assert.Contains(t, banner, "GET   /users/{id}")
+	assert.Contains(t, banner, "5s")
+	assert.Contains(t, banner, "Server.Port")
+	assert.Contains(t, banner, "(default)")
+}
+
+// Test_patternDistance checks the distance between paths and route patterns.
+func Test_patternDistance(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected int
+	}{
+		{"/users", "/users", 0},
+		{"/users", "/user", 1},
+		{"/users", "/usres", 2},
+		{"/users/{id}", "/users/42", 0},
+		{"/users/{id}", "/usres/42", 2},
+		{"/users/{id}/posts", "/users/42/post", 1},
+		{"/files/{path...}", "/file/a/b/c", 1},
+		{"/{$}", "/", 0},
+		{"example.com/users", "/users", 0},
+		{"/orders", "/users", 3},
+	}
+	for _, test := range tests {
+		assert.Equal(
+			t,
+			test.expected,
+			patternDistance(test.pattern, test.path),
+			test.pattern+" "+test.path,
+		)
+	}
+}
+
+// Test_middlewareNames checks that middleware functions are given readable names.
+func Test_middlewareNames(t *testing.T) {
+	names := middlewareNames([]func(http.Handler) http.Handler{
+		createTestMiddlewareHandler("X-Test", "test"),
+		TimeoutMiddleware(NewTimeout(time.Second, "timeout")),
+	})
+	assert.Equal(
+		t,
+		[]string{"rmhttp.createTestMiddlewareHandler", "rmhttp.TimeoutMiddleware"},
+		names,
+	)
+}
@@ -1,9 +1,16 @@
 package rmhttp
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrAlreadyCompiled is the error that the App panics with when routes, groups, middleware,
+// headers, timeouts or error handlers are added after it has been compiled. Compilation happens
+// when the App starts serving, or on the first request if it is used directly as an
+// http.Handler, so everything must be registered before then.
+var ErrAlreadyCompiled = errors.New("rmhttp: the App has already been compiled")
+
 // An HTTPError represents an error with an additional HTTP status code
 type HTTPError struct {
 	Err  error
@@ -9,15 +9,17 @@ retract (
 
 require (
 	dario.cat/mergo v1.0.2
+	github.com/BurntSushi/toml v1.6.0
 	github.com/caarlos0/env/v11 v11.4.1
 	github.com/felixge/httpsnoop v1.1.0
 	github.com/grokify/mogo v0.74.6
 	github.com/rs/cors v1.11.1
 	github.com/stretchr/testify v1.11.1
+	golang.org/x/crypto v0.57.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,6 +22,7 @@ type Group struct {
 	Parent     *Group
 	Routes     map[string]*Route
 	Groups     map[string]*Group
+	sealed     atomic.Bool
 }
 
 // NewGroup creates, initialises, and returns a pointer to a new Group
@@ -130,6 +132,7 @@ func (group *Group) Options(
 // This method will return a pointer to the receiver Group, allowing the user to chain any of the
 // other builder methods that Group implements.
 func (group *Group) Route(route *Route) *Group {
+	group.checkNotSealed("add route " + route.String())
 	// Use the fully computed pattern to avoid collisions
 	// We need to temporarily set the parent to compute the full pattern
 	route.Parent = group
@@ -143,6 +146,7 @@ func (group *Group) Route(route *Route) *Group {
 // This method will return a pointer to the receiver Group, allowing the user to chain any of the
 // other builder methods that Group implements.
 func (group *Group) Group(g *Group) *Group {
+	group.checkNotSealed("add group " + g.Pattern)
 	group.Groups[g.Pattern] = g
 	g.Parent = group
 	return group
@@ -166,6 +170,7 @@ func (group *Group) Group(g *Group) *Group {
 // This method will return a pointer to the receiver Group, allowing the user to chain any of the
 // other builder methods that Group implements.
 func (group *Group) WithMiddleware(middlewares ...func(http.Handler) http.Handler) *Group {
+	group.checkNotSealed("add middleware")
 	group.Middleware = append(group.Middleware, middlewares...)
 	return group
 }
@@ -179,12 +184,23 @@ func (group *Group) Use(middlewares ...func(http.Handler) http.Handler) *Group {
 	return group.WithMiddleware(middlewares...)
 }
 
+// RequireClientCert adds middleware to the receiver Group that rejects requests with a 403
+// response, unless the client presented a verified TLS certificate that satisfies the passed
+// matcher. A nil matcher accepts any verified certificate.
+//
+// This method will return a pointer to the receiver Group, allowing the user to chain any of the
+// other builder methods that Group implements.
+func (group *Group) RequireClientCert(match func(*ClientIdentity) bool) *Group {
+	return group.WithMiddleware(RequireClientCertMiddleware(match))
+}
+
 // WithHeader sets an HTTP header for this Group. Calling this method with the same key more than
 // once will overwrite the existing header.
 //
 // This method will return a pointer to the receiver Group, allowing the user to chain any of the
 // other builder methods that Group implements.
 func (group *Group) WithHeader(key, value string) *Group {
+	group.checkNotSealed("set header " + key)
 	group.Headers[key] = value
 	return group
 }
@@ -194,10 +210,41 @@ func (group *Group) WithHeader(key, value string) *Group {
 // This method will return a pointer to the receiver Group, allowing the user to chain any of the
 // other builder methods that Group implements.
 func (group *Group) WithTimeout(timeout time.Duration, message string) *Group {
+	group.checkNotSealed("set timeout")
 	group.Timeout = NewTimeout(timeout, message)
 	return group
 }
 
+// WithHealthChecks registers liveness and readiness handlers for the passed HealthRegistry on
+// this Group, at DefaultLivenessPath and DefaultReadinessPath (relative to the Group's pattern).
+//
+// This method will return a pointer to the receiver Group, allowing the user to chain any of the
+// other builder methods that Group implements.
+func (group *Group) WithHealthChecks(health *HealthRegistry) *Group {
+	health.mount(group)
+	return group
+}
+
+// seal marks the Group as compiled. Any further changes to the Group, its sub Groups or its
+// Routes will cause a panic.
+func (group *Group) seal() {
+	group.sealed.Store(true)
+}
+
+// checkNotSealed panics with ErrAlreadyCompiled if this Group, or any of its ancestors, has been
+// sealed. The action describes what the user was attempting to do.
+func (group *Group) checkNotSealed(action string) {
+	for g := group; g != nil; g = g.Parent {
+		if g.sealed.Load() {
+			panic(fmt.Errorf(
+				"%w: cannot %s; register everything before the App starts serving",
+				ErrAlreadyCompiled,
+				action,
+			))
+		}
+	}
+}
+
 // ComputedRoutes returns a map of unique Routes composed from this Group and any sub Groups of
 // this Group.
 func (group *Group) ComputedRoutes() map[string]*Route {
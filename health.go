@@ -0,0 +1,274 @@
+package rmhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// HEALTH CHECKS
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the HealthRegistry.
+const (
+	DefaultHealthCheckTimeout  = 2 * time.Second
+	DefaultHealthCheckCacheTTL = time.Second
+	DefaultLivenessPath        = "/healthz"
+	DefaultReadinessPath       = "/readyz"
+	HealthContentType          = "application/health+json"
+)
+
+// HealthStatus is the status of a health check, or of a set of health checks, as defined by the
+// IETF health check response format draft.
+type HealthStatus string
+
+// The possible health statuses. A failing check that is not critical produces a warning, rather
+// than a failure.
+const (
+	HealthPass HealthStatus = "pass"
+	HealthWarn HealthStatus = "warn"
+	HealthFail HealthStatus = "fail"
+)
+
+// A HealthCheck is a single named check of a dependency, such as a database or downstream
+// service.
+type HealthCheck struct {
+	// Name identifies the check in the response.
+	Name string
+	// Check returns an error if the dependency is unhealthy. It should respect the cancellation of
+	// the passed context, although a check that does not will still be timed out.
+	Check func(ctx context.Context) error
+	// Timeout limits how long the check may run for. Defaults to DefaultHealthCheckTimeout.
+	Timeout time.Duration
+	// CacheTTL sets how long a result is reused for, to protect dependencies from frequent
+	// probes. Defaults to the HealthRegistry's CacheTTL. A negative value disables caching.
+	CacheTTL time.Duration
+	// Critical checks fail the overall status when they fail. Non critical checks only produce a
+	// warning.
+	Critical bool
+	// Liveness includes the check in liveness, as well as readiness. Liveness should usually only
+	// cover the process itself, as failing it will cause the process to be restarted.
+	Liveness bool
+}
+
+// A HealthCheckResult is the result of a single HealthCheck.
+type HealthCheckResult struct {
+	Status        HealthStatus `json:"status"`
+	Time          time.Time    `json:"time"`
+	ObservedValue int64        `json:"observedValue"`
+	ObservedUnit  string       `json:"observedUnit"`
+	Output        string       `json:"output,omitempty"`
+}
+
+// A HealthReport is the aggregated result of a set of HealthChecks, in the IETF health check
+// response format. Each check is keyed by name.
+type HealthReport struct {
+	Status HealthStatus                   `json:"status"`
+	Checks map[string][]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// StatusCode returns the HTTP status code for the report - 503 if it failed, otherwise 200.
+func (report HealthReport) StatusCode() int {
+	if report.Status == HealthFail {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// ServeHTTP writes the report as application/health+json.
+func (report HealthReport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", HealthContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(report.StatusCode())
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// registeredCheck is a HealthCheck along with its cached result.
+type registeredCheck struct {
+	HealthCheck
+	mu      sync.Mutex
+	result  HealthCheckResult
+	expires time.Time
+}
+
+// HealthRegistry holds a set of named HealthChecks. Checks are run concurrently, and their results
+// are cached, so it is safe to probe frequently.
+type HealthRegistry struct {
+	// CacheTTL is the default time that check results are reused for.
+	CacheTTL time.Duration
+
+	mu     sync.RWMutex
+	checks []*registeredCheck
+}
+
+// NewHealthRegistry creates, initialises and returns a pointer to a new HealthRegistry.
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{CacheTTL: DefaultHealthCheckCacheTTL}
+}
+
+// Register adds the passed HealthCheck to the registry. Registering a check with the same name as
+// an existing check replaces it. Checks without a name or check function are ignored.
+//
+// This method will return a pointer to the receiver HealthRegistry, allowing the user to chain
+// further registrations.
+func (h *HealthRegistry) Register(check HealthCheck) *HealthRegistry {
+	if check.Name == "" || check.Check == nil {
+		return h
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultHealthCheckTimeout
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rc := &registeredCheck{HealthCheck: check}
+	for i, existing := range h.checks {
+		if existing.Name == check.Name {
+			h.checks[i] = rc
+			return h
+		}
+	}
+	h.checks = append(h.checks, rc)
+	return h
+}
+
+// Liveness runs the checks that are included in liveness, and returns the aggregated report.
+func (h *HealthRegistry) Liveness(ctx context.Context) HealthReport {
+	return h.run(ctx, true)
+}
+
+// Readiness runs every check, and returns the aggregated report.
+func (h *HealthRegistry) Readiness(ctx context.Context) HealthReport {
+	return h.run(ctx, false)
+}
+
+// LivenessHandler returns an http.Handler that serves the liveness report.
+func (h *HealthRegistry) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Liveness(r.Context()).ServeHTTP(w, r)
+	})
+}
+
+// ReadinessHandler returns an http.Handler that serves the readiness report.
+func (h *HealthRegistry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Readiness(r.Context()).ServeHTTP(w, r)
+	})
+}
+
+// mount registers the liveness and readiness handlers on the passed Group, at DefaultLivenessPath
+// and DefaultReadinessPath.
+func (h *HealthRegistry) mount(group *Group) {
+	group.Handle(http.MethodGet, DefaultLivenessPath, h.LivenessHandler())
+	group.Handle(http.MethodGet, DefaultReadinessPath, h.ReadinessHandler())
+}
+
+// run runs the selected checks concurrently, and aggregates the results. The overall status is
+// the worst status of any check.
+func (h *HealthRegistry) run(ctx context.Context, livenessOnly bool) HealthReport {
+	h.mu.RLock()
+	checks := make([]*registeredCheck, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !livenessOnly || c.Liveness {
+			checks = append(checks, c)
+		}
+	}
+	ttl := h.CacheTTL
+	h.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Go(func() {
+			results[i] = c.run(ctx, ttl)
+		})
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthPass}
+	if len(checks) > 0 {
+		report.Checks = make(map[string][]HealthCheckResult, len(checks))
+	}
+	for i, c := range checks {
+		report.Checks[c.Name] = []HealthCheckResult{results[i]}
+		switch results[i].Status {
+		case HealthFail:
+			report.Status = HealthFail
+		case HealthWarn:
+			if report.Status == HealthPass {
+				report.Status = HealthWarn
+			}
+		}
+	}
+	return report
+}
+
+// run returns the cached result of the check if it is still valid, or runs the check. Concurrent
+// callers wait for a single run, rather than each running the check.
+func (c *registeredCheck) run(ctx context.Context, defaultTTL time.Duration) HealthCheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(c.expires) {
+		return c.result
+	}
+
+	err := c.execute(ctx)
+	c.result = HealthCheckResult{
+		Status:        HealthPass,
+		Time:          now.UTC(),
+		ObservedValue: time.Since(now).Milliseconds(),
+		ObservedUnit:  "ms",
+	}
+	if err != nil {
+		c.result.Status = HealthWarn
+		if c.Critical {
+			c.result.Status = HealthFail
+		}
+		c.result.Output = err.Error()
+	}
+
+	// A result caused by the caller giving up says nothing about the dependency, so don't reuse it.
+	ttl := c.CacheTTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	if ctx.Err() == nil {
+		c.expires = now.Add(ttl)
+	}
+	return c.result
+}
+
+// execute runs the check function with the check's timeout. The function is run in its own
+// goroutine, so that a check that ignores its context cannot block the caller, and any panic is
+// converted into an error.
+func (c *registeredCheck) execute(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("health check panicked: %v", p)
+			}
+		}()
+		done <- c.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("health check timed out after %s", c.Timeout)
+		}
+		return ctx.Err()
+	}
+}
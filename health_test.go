@@ -0,0 +1,248 @@
+package rmhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// HEALTH TESTS
+// ------------------------------------------------------------------------------------------------
+
+// errDown is returned by failing test checks.
+var errDown = errors.New("down")
+
+// healthCheckFunc returns a check function that returns the passed error.
+func healthCheckFunc(err error) func(context.Context) error {
+	return func(context.Context) error {
+		return err
+	}
+}
+
+// Test_HealthRegistry_status checks how check results are aggregated into the overall status.
+func Test_HealthRegistry_status(t *testing.T) {
+	failure := errors.New("connection refused")
+
+	tests := []struct {
+		name           string
+		checks         []HealthCheck
+		expectedStatus HealthStatus
+		expectedCode   int
+	}{
+		{"no checks", nil, HealthPass, http.StatusOK},
+		{
+			"all passing",
+			[]HealthCheck{
+				{Name: "db", Check: healthCheckFunc(nil), Critical: true},
+				{Name: "cache", Check: healthCheckFunc(nil)},
+			},
+			HealthPass,
+			http.StatusOK,
+		},
+		{
+			"non critical failure",
+			[]HealthCheck{
+				{Name: "db", Check: healthCheckFunc(nil), Critical: true},
+				{Name: "cache", Check: healthCheckFunc(failure)},
+			},
+			HealthWarn,
+			http.StatusOK,
+		},
+		{
+			"critical failure",
+			[]HealthCheck{
+				{Name: "db", Check: healthCheckFunc(failure), Critical: true},
+				{Name: "cache", Check: healthCheckFunc(failure)},
+			},
+			HealthFail,
+			http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			health := NewHealthRegistry()
+			for _, check := range test.checks {
+				health.Register(check)
+			}
+			report := health.Readiness(context.Background())
+			assert.Equal(t, test.expectedStatus, report.Status)
+			assert.Equal(t, test.expectedCode, report.StatusCode())
+			assert.Len(t, report.Checks, len(test.checks))
+		})
+	}
+}
+
+// Test_HealthRegistry_liveness checks that liveness only runs the checks included in it.
+func Test_HealthRegistry_liveness(t *testing.T) {
+	health := NewHealthRegistry().
+		Register(HealthCheck{Name: "db", Check: healthCheckFunc(errDown), Critical: true}).
+		Register(HealthCheck{Name: "goroutines", Check: healthCheckFunc(nil), Liveness: true})
+
+	live := health.Liveness(context.Background())
+	assert.Equal(t, HealthPass, live.Status)
+	assert.Contains(t, live.Checks, "goroutines")
+	assert.NotContains(t, live.Checks, "db")
+
+	ready := health.Readiness(context.Background())
+	assert.Equal(t, HealthFail, ready.Status)
+	assert.Equal(t, "down", ready.Checks["db"][0].Output)
+}
+
+// Test_HealthRegistry_timeouts_and_panics checks that slow or panicking checks fail, rather than
+// blocking or crashing the caller.
+func Test_HealthRegistry_timeouts_and_panics(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	health := NewHealthRegistry().
+		Register(HealthCheck{
+			Name:     "ignores context",
+			Check:    func(context.Context) error { <-block; return nil },
+			Timeout:  20 * time.Millisecond,
+			Critical: true,
+		}).
+		Register(HealthCheck{
+			Name:    "respects context",
+			Check:   func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+			Timeout: 20 * time.Millisecond,
+		}).
+		Register(HealthCheck{
+			Name:  "panics",
+			Check: func(context.Context) error { panic("boom") },
+		})
+
+	start := time.Now()
+	report := health.Readiness(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, HealthFail, report.Status)
+	assert.Equal(
+		t,
+		"health check timed out after 20ms",
+		report.Checks["ignores context"][0].Output,
+	)
+	assert.Equal(t, HealthWarn, report.Checks["respects context"][0].Status)
+	assert.Equal(t, "health check panicked: boom", report.Checks["panics"][0].Output)
+}
+
+// Test_HealthRegistry_caching checks that results are cached, that concurrent callers share a
+// single run, and that caching can be disabled per check.
+func Test_HealthRegistry_caching(t *testing.T) {
+	var cachedRuns, uncachedRuns atomic.Int32
+	health := NewHealthRegistry()
+	health.CacheTTL = time.Hour
+	health.
+		Register(HealthCheck{Name: "cached", Check: func(context.Context) error {
+			cachedRuns.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}}).
+		Register(HealthCheck{Name: "uncached", CacheTTL: -1, Check: func(context.Context) error {
+			uncachedRuns.Add(1)
+			return nil
+		}})
+
+	done := make(chan struct{})
+	for range 5 {
+		go func() {
+			health.Readiness(context.Background())
+			done <- struct{}{}
+		}()
+	}
+	for range 5 {
+		<-done
+	}
+
+	assert.Equal(t, int32(1), cachedRuns.Load())
+	assert.Equal(t, int32(5), uncachedRuns.Load())
+}
+
+// Test_HealthRegistry_Register checks that checks are replaced by name, and that invalid checks
+// are ignored.
+func Test_HealthRegistry_Register(t *testing.T) {
+	health := NewHealthRegistry().
+		Register(HealthCheck{Name: "db", Check: healthCheckFunc(errDown), Critical: true}).
+		Register(HealthCheck{Name: "db", Check: healthCheckFunc(nil), Critical: true}).
+		Register(HealthCheck{Name: "", Check: healthCheckFunc(nil)}).
+		Register(HealthCheck{Name: "no func"})
+
+	report := health.Readiness(context.Background())
+	assert.Equal(t, HealthPass, report.Status)
+	assert.Len(t, report.Checks, 1)
+}
+
+// Test_HealthRegistry_mount checks that the handlers can be mounted on an App or Group, and that
+// they respond with application/health+json.
+func Test_HealthRegistry_mount(t *testing.T) {
+	health := NewHealthRegistry().
+		Register(HealthCheck{Name: "db", Check: healthCheckFunc(errDown), Critical: true})
+
+	app := New()
+	app.WithHealthChecks(health)
+	app.Group("/internal").WithHealthChecks(health)
+
+	tests := []struct {
+		path         string
+		expectedCode int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+		{"/internal/healthz", http.StatusOK},
+		{"/internal/readyz", http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, HealthContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+			var report HealthReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "fail", body["status"])
+	check := body["checks"].(map[string]any)["db"].([]any)[0].(map[string]any)
+	assert.Equal(t, "fail", check["status"])
+	assert.Equal(t, "down", check["output"])
+	assert.Equal(t, "ms", check["observedUnit"])
+	assert.Contains(t, check, "time")
+}
+
+// Test_AdminServer_WithHealthChecks checks that the admin endpoints include the registered checks,
+// and that readiness still depends on the main server's state.
+func Test_AdminServer_WithHealthChecks(t *testing.T) {
+	health := NewHealthRegistry().
+		Register(HealthCheck{Name: "db", Check: healthCheckFunc(nil), Critical: true})
+	app := New(Config{Admin: AdminConfig{Port: 9876}})
+	app.Admin.WithHealthChecks(health)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.Admin.Server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	assert.Equal(t, HealthContentType, serve("/healthz").Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/readyz").Code)
+
+	app.Admin.state.Store(adminServing)
+	w := serve("/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HealthContentType, w.Header().Get("Content-Type"))
+}
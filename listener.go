@@ -0,0 +1,290 @@
+package rmhttp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------------------------
+// LISTENER
+// ------------------------------------------------------------------------------------------------
+
+// DefaultACMEChallengePrefix is the path prefix used by the ACME HTTP-01 challenge. Requests
+// with this prefix are never redirected to HTTPS, so that certificates can be issued and
+// renewed.
+const DefaultACMEChallengePrefix = "/.well-known/acme-challenge/"
+
+// A Listener describes an additional address that a Server should accept connections on. Every
+// Listener shares the routes, timeouts and lifecycle of the Server it is added to.
+type Listener struct {
+	Host string
+	Port int
+
+	// TLS enables TLS on the Listener. It is implied if CertFile and KeyFile are set; otherwise,
+	// the certificates must be supplied via ServerConfig.TLSConfig.
+	TLS      bool
+	CertFile string
+	KeyFile  string
+
+	// RedirectToHTTPS causes the Listener to redirect every request to HTTPS, instead of serving
+	// the routes. Requests under the ACME challenge prefix are still served by the routes.
+	RedirectToHTTPS bool
+}
+
+// Addr returns the address that the Listener will bind to.
+func (l Listener) Addr() string {
+	return net.JoinHostPort(l.Host, strconv.Itoa(l.Port))
+}
+
+// isTLS returns true if the Listener should serve TLS.
+func (l Listener) isTLS() bool {
+	return l.TLS || (l.CertFile != "" && l.KeyFile != "")
+}
+
+// ------------------------------------------------------------------------------------------------
+// HTTPS REDIRECT
+// ------------------------------------------------------------------------------------------------
+
+// httpsRedirectHandler creates and returns a handler that redirects every request to the same
+// host and URI over HTTPS. The port is omitted from the target URL when it is the standard HTTPS
+// port. Requests under the exempt prefix are passed to the next handler instead.
+//
+// GET and HEAD requests receive a 301 response, while all other methods receive a 308, so that
+// the method and body are preserved.
+func httpsRedirectHandler(httpsPort int, exemptPrefix string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exemptPrefix != "" && strings.HasPrefix(r.URL.Path, exemptPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if httpsPort != 0 && httpsPort != 443 {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			// Bare IPv6 addresses must be bracketed in URLs.
+			host = "[" + host + "]"
+		}
+
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
+
+// ------------------------------------------------------------------------------------------------
+// HSTS
+// ------------------------------------------------------------------------------------------------
+
+// hstsHeaderValue builds the Strict-Transport-Security header value from the passed settings. An
+// empty string is returned if HSTS is disabled.
+func hstsHeaderValue(maxAge int, includeSubdomains bool, preload bool) string {
+	if maxAge <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("max-age=%d", maxAge)
+	if includeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// hstsHandler creates and returns a handler that sets the Strict-Transport-Security header on
+// every response to a request received over TLS. Browsers ignore the header over plain HTTP, so
+// it is not sent there.
+func hstsHandler(value string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ------------------------------------------------------------------------------------------------
+// LISTENER LIFECYCLE
+// ------------------------------------------------------------------------------------------------
+
+// boundListener pairs a Listener with its http.Server and bound net.Listener.
+type boundListener struct {
+	listener Listener
+	server   *http.Server
+	ln       net.Listener
+}
+
+// serve serves the bound listener until it is shut down or fails.
+func (bl boundListener) serve() error {
+	if bl.listener.isTLS() {
+		return bl.server.ServeTLS(bl.ln, bl.listener.CertFile, bl.listener.KeyFile)
+	}
+	return bl.server.Serve(bl.ln)
+}
+
+// bindListeners creates an http.Server for every additional Listener and binds it to its
+// address. The http.Server settings are copied from the primary server, so that every Listener
+// shares the same timeouts and hooks. If any address cannot be bound, all previously bound
+// listeners are closed and the error is returned.
+func (srv *Server) bindListeners() ([]boundListener, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	bound := make([]boundListener, 0, len(srv.listeners))
+	for _, l := range srv.listeners {
+		ln, err := net.Listen("tcp", l.Addr())
+		if err != nil {
+			for _, b := range bound {
+				_ = b.ln.Close()
+			}
+			return nil, fmt.Errorf("failed to bind listener %s: %w", l.Addr(), err)
+		}
+
+		handler := srv.Server.Handler
+		if l.RedirectToHTTPS {
+			httpsPort := srv.httpsPort
+			if httpsPort == 0 {
+				httpsPort = srv.Port
+			}
+			handler = httpsRedirectHandler(httpsPort, srv.redirectExemptPrefix, handler)
+		}
+
+		bound = append(bound, boundListener{
+			listener: l,
+			server:   srv.cloneHTTPServer(handler),
+			ln:       ln,
+		})
+	}
+
+	srv.additional = make([]*http.Server, 0, len(bound))
+	for _, b := range bound {
+		srv.additional = append(srv.additional, b.server)
+	}
+	return bound, nil
+}
+
+// cloneHTTPServer creates a new http.Server with the same settings as the primary server, but
+// with the passed handler.
+func (srv *Server) cloneHTTPServer(handler http.Handler) *http.Server {
+	hs := &http.Server{
+		Handler:                      handler,
+		ReadTimeout:                  srv.Server.ReadTimeout,
+		ReadHeaderTimeout:            srv.Server.ReadHeaderTimeout,
+		WriteTimeout:                 srv.Server.WriteTimeout,
+		IdleTimeout:                  srv.Server.IdleTimeout,
+		MaxHeaderBytes:               srv.Server.MaxHeaderBytes,
+		DisableGeneralOptionsHandler: srv.Server.DisableGeneralOptionsHandler,
+		TLSNextProto:                 srv.Server.TLSNextProto,
+		ConnState:                    srv.Server.ConnState,
+		ErrorLog:                     srv.Server.ErrorLog,
+		BaseContext:                  srv.Server.BaseContext,
+		ConnContext:                  srv.Server.ConnContext,
+		HTTP2:                        srv.Server.HTTP2,
+		Protocols:                    srv.Server.Protocols,
+	}
+	if srv.Server.TLSConfig != nil {
+		hs.TLSConfig = srv.Server.TLSConfig.Clone()
+	}
+	if !srv.keepAlives {
+		hs.SetKeepAlivesEnabled(false)
+	}
+	if srv.ConnTracker != nil {
+		hs.RegisterOnShutdown(func() { srv.ConnTracker.CloseIdle() })
+	}
+	return hs
+}
+
+// serve validates the protocols and client auth config, then binds the primary server (unless the
+// passed listener is already bound) and every additional Listener, and serves them, passing the
+// primary listener to the passed function. All of them share a lifecycle, so if any one of them
+// stops with an error, the rest are closed and that error is returned.
+func (srv *Server) serve(tls bool, ln net.Listener, primary func(net.Listener) error) error {
+	if err := srv.validateProtocols(tls); err != nil {
+		return err
+	}
+
+	if srv.clientAuthErr != nil {
+		return srv.clientAuthErr
+	}
+
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", srv.Server.Addr); err != nil {
+			return err
+		}
+	}
+
+	bound, err := srv.bindListeners()
+	if err != nil {
+		_ = ln.Close()
+		return err
+	}
+	if srv.onListening != nil {
+		srv.onListening()
+	}
+	if len(bound) == 0 {
+		return primary(ln)
+	}
+
+	errs := make(chan error, len(bound)+1)
+	for _, b := range bound {
+		go func() {
+			errs <- b.serve()
+		}()
+	}
+	go func() {
+		errs <- primary(ln)
+	}()
+
+	err = <-errs
+	if !errors.Is(err, http.ErrServerClosed) {
+		_ = srv.Server.Close()
+		for _, hs := range srv.additionalServers() {
+			_ = hs.Close()
+		}
+	}
+	return err
+}
+
+// validateProtocols checks that the configured protocols are valid, and can be served by the
+// primary server and every additional Listener.
+func (srv *Server) validateProtocols(tls bool) error {
+	if len(srv.httpProtocols) > 0 {
+		if _, err := ParseProtocols(srv.httpProtocols); err != nil {
+			return fmt.Errorf("invalid HTTP protocols: %w", err)
+		}
+	}
+	if err := validateProtocols(srv.Server.Protocols, tls); err != nil {
+		return fmt.Errorf("invalid HTTP protocols for %s: %w", srv.Server.Addr, err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, l := range srv.listeners {
+		if err := validateProtocols(srv.Server.Protocols, l.isTLS()); err != nil {
+			return fmt.Errorf("invalid HTTP protocols for %s: %w", l.Addr(), err)
+		}
+	}
+	return nil
+}
+
+// additionalServers returns a snapshot of the http.Servers created for additional Listeners.
+func (srv *Server) additionalServers() []*http.Server {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]*http.Server(nil), srv.additional...)
+}
@@ -0,0 +1,251 @@
+package rmhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// LISTENER TESTS
+// ------------------------------------------------------------------------------------------------
+
+// freePort returns a TCP port on localhost that is currently free.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+	return port
+}
+
+// Test_httpsRedirectHandler checks the redirect targets and status codes produced by the HTTPS
+// redirect handler.
+func Test_httpsRedirectHandler(t *testing.T) {
+	next := http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "acme"))
+
+	tests := []struct {
+		name         string
+		httpsPort    int
+		method       string
+		host         string
+		target       string
+		expectedCode int
+		expectedLoc  string
+	}{
+		{
+			"GET is redirected with a 301 to the default port",
+			443, http.MethodGet, "example.com", "/a?b=c",
+			http.StatusMovedPermanently, "https://example.com/a?b=c",
+		},
+		{
+			"the incoming port is replaced",
+			443, http.MethodGet, "example.com:80", "/",
+			http.StatusMovedPermanently, "https://example.com/",
+		},
+		{
+			"a non standard HTTPS port is included",
+			8443, http.MethodGet, "example.com:8080", "/a",
+			http.StatusMovedPermanently, "https://example.com:8443/a",
+		},
+		{
+			"POST is redirected with a 308",
+			443, http.MethodPost, "example.com", "/form",
+			http.StatusPermanentRedirect, "https://example.com/form",
+		},
+		{
+			"IPv6 hosts are bracketed",
+			443, http.MethodGet, "[::1]:80", "/",
+			http.StatusMovedPermanently, "https://[::1]/",
+		},
+		{
+			"the ACME challenge prefix is not redirected",
+			443, http.MethodGet, "example.com", DefaultACMEChallengePrefix + "token",
+			http.StatusOK, "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := httpsRedirectHandler(test.httpsPort, DefaultACMEChallengePrefix, next)
+			req := httptest.NewRequest(test.method, test.target, nil)
+			req.Host = test.host
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedLoc, w.Header().Get("Location"))
+		})
+	}
+}
+
+// Test_hstsHeaderValue checks that the HSTS header value is built from the config settings.
+func Test_hstsHeaderValue(t *testing.T) {
+	assert.Empty(t, hstsHeaderValue(0, true, true))
+	assert.Equal(t, "max-age=60", hstsHeaderValue(60, false, false))
+	assert.Equal(
+		t,
+		"max-age=31536000; includeSubDomains; preload",
+		hstsHeaderValue(31536000, true, true),
+	)
+}
+
+// Test_hstsHandler checks that the HSTS header is only sent over TLS.
+func Test_hstsHandler(t *testing.T) {
+	h := hstsHandler("max-age=60", http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "")))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	req.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, "max-age=60", w.Header().Get("Strict-Transport-Security"))
+}
+
+// Test_Server_multiple_listeners checks that additional listeners share the routes of the Server,
+// that the redirect listener redirects to HTTPS, and that shutdown stops every listener.
+func Test_Server_multiple_listeners(t *testing.T) {
+	primaryPort := freePort(t)
+	extraPort := freePort(t)
+	redirectPort := freePort(t)
+
+	router := NewRouter()
+	router.Handle(http.MethodGet, "/hello", http.HandlerFunc(createTestHandlerFunc(200, "hello")))
+
+	srv := NewServer(ServerConfig{
+		Host:             "127.0.0.1",
+		Port:             primaryPort,
+		TCPKeepAlive:     true,
+		HTTPRedirectPort: redirectPort,
+		HTTPSPort:        8443,
+	}, router)
+	srv.AddListener(Listener{Host: "127.0.0.1", Port: extraPort})
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- srv.ListenAndServe()
+	}()
+	require.NoError(t, waitForServerAvailable(primaryPort, 5*time.Second))
+	require.NoError(t, waitForServerAvailable(extraPort, 5*time.Second))
+	require.NoError(t, waitForServerAvailable(redirectPort, 5*time.Second))
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, port := range []int{primaryPort, extraPort} {
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		res, err := client.Get("http://" + addr + "/hello")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "hello", string(body))
+	}
+
+	res, err := client.Get("http://localhost:" + strconv.Itoa(redirectPort) + "/hello?x=1")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusMovedPermanently, res.StatusCode)
+	assert.Equal(t, "https://localhost:8443/hello?x=1", res.Header.Get("Location"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+
+	select {
+	case err := <-errChan:
+		assert.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not stop")
+	}
+
+	for _, port := range []int{primaryPort, extraPort, redirectPort} {
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		_, err := net.DialTimeout("tcp", addr, time.Second)
+		assert.Error(t, err)
+	}
+}
+
+// Test_Server_listener_bind_error checks that a Listener that cannot bind its address causes the
+// Server to return an error without starting.
+func Test_Server_listener_bind_error(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	srv := NewServer(ServerConfig{Host: "127.0.0.1", Port: freePort(t)}, http.NewServeMux())
+	srv.AddListener(Listener{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port})
+
+	err = srv.ListenAndServe()
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to bind listener"))
+}
+
+// headerCountingWriter is a ResponseWriter that counts the calls to Header.
+type headerCountingWriter struct {
+	*httptest.ResponseRecorder
+	calls int
+}
+
+func (w *headerCountingWriter) Header() http.Header {
+	w.calls++
+	return w.ResponseRecorder.Header()
+}
+
+// Test_Server_restart_after_bind_error checks that starting the Server again after a failed start
+// doesn't wrap the handler in the HSTS handler, or apply client auth, a second time.
+func Test_Server_restart_after_bind_error(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	srv := NewServer(ServerConfig{
+		Host:       "127.0.0.1",
+		Port:       freePort(t),
+		HSTSMaxAge: 60,
+		ClientAuth: ClientAuthConfig{Mode: ClientAuthRequire},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.AddListener(Listener{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port})
+	tlsConfig := srv.Server.TLSConfig
+	require.NotNil(t, tlsConfig)
+
+	for range 2 {
+		assert.ErrorContains(t, srv.ListenAndServe(), "failed to bind listener")
+	}
+
+	assert.Same(t, tlsConfig, srv.Server.TLSConfig)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{}
+	w := &headerCountingWriter{ResponseRecorder: httptest.NewRecorder()}
+	srv.Server.Handler.ServeHTTP(w, req)
+	assert.Equal(t, 1, w.calls, "the HSTS handler should only be applied once")
+	assert.Equal(t, "max-age=60", w.Header().Get("Strict-Transport-Security"))
+
+	srv = NewServer(ServerConfig{
+		Host:       "127.0.0.1",
+		Port:       freePort(t),
+		ClientAuth: ClientAuthConfig{Mode: ClientAuthVerify},
+	}, nil)
+	assert.ErrorContains(t, srv.ListenAndServe(), "failed to configure client auth")
+}
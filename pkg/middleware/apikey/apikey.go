@@ -1,24 +1,348 @@
+// Package apikey provides middleware that authenticates requests with API keys.
+//
+// Keys are only ever held as SHA-256 hashes, and each key maps to an identity and a set of
+// scopes, which handlers can read with FromContext. Keys can be listed in the Options, or looked
+// up in a KeyStore, which allows keys to be added, expired and revoked at runtime.
+//
+//	auth, err := apikey.New(apikey.Options{
+//		Bearer: true,
+//		Keys: []apikey.Key{
+//			{Hash: "9f86d08...", Identity: "billing", Scopes: []string{"invoices:read"}},
+//		},
+//	})
+//	app.Use(auth.Middleware())
+//	app.Get("/invoices", listInvoices).Use(auth.RequireScopes("invoices:read"))
 package apikey
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Middleware creates and returns a MiddlewareFunc that validates the x-api-key header.
-// Keys are converted to a map for O(1) lookup instead of O(n) linear search.
+// ------------------------------------------------------------------------------------------------
+// KEYS
+// ------------------------------------------------------------------------------------------------
+
+// DefaultHeader is the header that keys are read from when no other source is configured.
+const DefaultHeader = "X-API-Key"
+
+// DefaultRealm is the realm reported in the WWW-Authenticate header.
+const DefaultRealm = "api"
+
+// ErrKeyNotFound is returned by a KeyStore when there is no key with the passed hash.
+var ErrKeyNotFound = errors.New("api key not found")
+
+// Key describes an API key, and the identity and scopes that it grants.
+type Key struct {
+	// Hash is the hex encoded SHA-256 hash of the key. Use HashKey to create it.
+	Hash string
+	// Identity names the client that the key belongs to, such as a service or user ID.
+	Identity string
+	// Scopes are the permissions that the key grants, checked by RequireScopes.
+	Scopes []string
+	// ExpiresAt is the time after which the key is rejected. The zero value never expires.
+	ExpiresAt time.Time
+	// Revoked keys are always rejected.
+	Revoked bool
+}
+
+// HasScope reports whether the key grants the passed scope.
+func (k *Key) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// valid reports whether the key can be used at the passed time.
+func (k *Key) valid(now time.Time) bool {
+	return !k.Revoked && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// HashKey returns the hex encoded SHA-256 hash of the passed key, as used by Key.Hash.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyStore looks up keys by their hash, allowing keys to be managed at runtime, such as in a
+// database. Lookup returns ErrKeyNotFound if there is no matching key. Expired and revoked keys
+// can be returned, as they are rejected by the middleware.
+type KeyStore interface {
+	Lookup(ctx context.Context, hash string) (Key, error)
+}
+
+// MemoryStore is a KeyStore that holds keys in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore creates, initialises and returns a pointer to a new MemoryStore, holding the
+// passed keys.
+func NewMemoryStore(keys ...Key) *MemoryStore {
+	store := &MemoryStore{keys: make(map[string]Key, len(keys))}
+	for _, key := range keys {
+		store.Add(key)
+	}
+	return store
+}
+
+// Lookup returns the key with the passed hash. Keys are indexed by their SHA-256 hash, so the
+// lookup time does not reveal anything about the keys themselves.
+func (s *MemoryStore) Lookup(_ context.Context, hash string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[strings.ToLower(hash)]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// Add adds the passed key, replacing any key with the same hash.
+func (s *MemoryStore) Add(key Key) {
+	key.Hash = strings.ToLower(key.Hash)
+	key.Scopes = slices.Clone(key.Scopes)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.Hash] = key
+}
+
+// Revoke marks the key with the passed hash as revoked, and reports whether it was found.
+func (s *MemoryStore) Revoke(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[strings.ToLower(hash)]
+	if ok {
+		key.Revoked = true
+		s.keys[key.Hash] = key
+	}
+	return ok
+}
+
+// Remove removes the key with the passed hash.
+func (s *MemoryStore) Remove(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, strings.ToLower(hash))
+}
+
+// staticStore is the KeyStore for keys listed in the Options. It compares the hash against every
+// key in constant time, so that the time taken does not depend on which key matched.
+type staticStore struct {
+	keys   []Key
+	hashes [][]byte
+}
+
+// Lookup returns the key with the passed hash.
+func (s *staticStore) Lookup(_ context.Context, hash string) (Key, error) {
+	presented, err := hex.DecodeString(hash)
+	if err != nil {
+		return Key{}, ErrKeyNotFound
+	}
+	match := -1
+	for i, h := range s.hashes {
+		if subtle.ConstantTimeCompare(h, presented) == 1 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return Key{}, ErrKeyNotFound
+	}
+	return s.keys[match], nil
+}
+
+// ------------------------------------------------------------------------------------------------
+// MIDDLEWARE
+// ------------------------------------------------------------------------------------------------
+
+// Options configures the API key middleware.
+type Options struct {
+	// Header is the request header that keys are read from. Defaults to DefaultHeader if neither
+	// Bearer nor QueryParam is set.
+	Header string
+	// Bearer reads keys from the Authorization header, using the Bearer scheme.
+	Bearer bool
+	// QueryParam is the query parameter that keys are read from. Keys in URLs tend to end up in
+	// logs, so only use this when clients cannot set headers.
+	QueryParam string
+	// Keys lists the accepted keys. Either Keys or Store must be set.
+	Keys []Key
+	// Store looks up the accepted keys. Either Keys or Store must be set.
+	Store KeyStore
+	// Realm is reported in the WWW-Authenticate header. Defaults to DefaultRealm.
+	Realm string
+}
+
+// Authenticator authenticates requests with API keys.
+type Authenticator struct {
+	options Options
+	store   KeyStore
+	scheme  string
+	now     func() time.Time
+}
+
+// keyContextKey is the context key for the authenticated Key.
+type keyContextKey struct{}
+
+// New creates, initialises and returns a pointer to a new Authenticator. An error is returned if
+// neither or both of Keys and Store are set, or if a key hash is not a hex encoded SHA-256 hash.
+func New(options Options) (*Authenticator, error) {
+	if (options.Keys == nil) == (options.Store == nil) {
+		return nil, errors.New("api key authenticator requires either keys or a store")
+	}
+	if options.Header == "" && !options.Bearer && options.QueryParam == "" {
+		options.Header = DefaultHeader
+	}
+	if options.Realm == "" {
+		options.Realm = DefaultRealm
+	}
+
+	a := &Authenticator{options: options, store: options.Store, scheme: "APIKey", now: time.Now}
+	if options.Bearer {
+		a.scheme = "Bearer"
+	}
+	if options.Keys != nil {
+		static := &staticStore{}
+		for _, key := range options.Keys {
+			hash, err := hex.DecodeString(key.Hash)
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid api key hash for %q", key.Identity)
+			}
+			key.Scopes = slices.Clone(key.Scopes)
+			static.keys = append(static.keys, key)
+			static.hashes = append(static.hashes, hash)
+		}
+		a.store = static
+	}
+	return a, nil
+}
+
+// Middleware creates and returns a middleware function that validates the X-API-Key header
+// against the passed keys. It is a shorthand for New with plain text keys, which are hashed
+// before use. If no keys are passed, every request is allowed.
 func Middleware(keys ...string) func(http.Handler) http.Handler {
-	// Convert keys to map for O(1) lookup instead of O(n) linear search
-	keyMap := make(map[string]struct{}, len(keys))
+	if len(keys) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	options := Options{Keys: make([]Key, 0, len(keys))}
 	for _, key := range keys {
-		keyMap[key] = struct{}{}
+		options.Keys = append(options.Keys, Key{Hash: HashKey(key)})
+	}
+	a, _ := New(options)
+	return a.Middleware()
+}
+
+// Middleware returns a middleware function that rejects requests without a valid key with a 401
+// response, and stores the matched Key in the request context.
+func (a *Authenticator) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := a.extract(r)
+			if presented == "" {
+				a.unauthorized(w, "")
+				return
+			}
+
+			key, err := a.store.Lookup(r.Context(), HashKey(presented))
+			if err != nil && !errors.Is(err, ErrKeyNotFound) {
+				slog.Error("failed to look up api key", "type", "auth", "error", err)
+				http.Error(
+					w,
+					http.StatusText(http.StatusInternalServerError),
+					http.StatusInternalServerError,
+				)
+				return
+			}
+			if err != nil || !key.valid(a.now()) {
+				a.unauthorized(w, "invalid_token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), key)))
+		})
+	}
+}
+
+// extract returns the key presented by the request, from the first configured source that has
+// one.
+func (a *Authenticator) extract(r *http.Request) string {
+	if a.options.Header != "" {
+		if key := strings.TrimSpace(r.Header.Get(a.options.Header)); key != "" {
+			return key
+		}
+	}
+	if a.options.Bearer {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			if token = strings.TrimSpace(token); token != "" {
+				return token
+			}
+		}
 	}
+	if a.options.QueryParam != "" {
+		return r.URL.Query().Get(a.options.QueryParam)
+	}
+	return ""
+}
+
+// unauthorized writes a 401 response with a WWW-Authenticate challenge, including the passed
+// error code if it is set.
+func (a *Authenticator) unauthorized(w http.ResponseWriter, code string) {
+	challenge := fmt.Sprintf("%s realm=%q", a.scheme, a.options.Realm)
+	if code != "" {
+		challenge += fmt.Sprintf(", error=%q", code)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
 
+// RequireScopes returns a middleware function that only allows requests whose key grants every
+// one of the passed scopes. It must run after the Authenticator's middleware. Requests without a
+// key get a 401 response, and requests that lack a scope get a 403 response, both with a
+// WWW-Authenticate challenge using the Authenticator's scheme and realm.
+func (a *Authenticator) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return requireScopes(a.scheme, a.options.Realm, scopes)
+}
+
+// RequireScopes creates and returns a middleware function that only allows requests whose key
+// grants every one of the passed scopes. It is the equivalent of Authenticator.RequireScopes for
+// keys read from a header, such as by Middleware, so its challenges use the APIKey scheme and
+// DefaultRealm.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return requireScopes("APIKey", DefaultRealm, scopes)
+}
+
+// requireScopes returns a middleware function that only allows requests whose key grants every
+// one of the passed scopes, challenging other requests with the passed scheme and realm.
+func requireScopes(scheme, realm string, scopes []string) func(http.Handler) http.Handler {
+	unauthorized := fmt.Sprintf("%s realm=%q", scheme, realm)
+	forbidden := fmt.Sprintf(
+		`%s realm=%q, error="insufficient_scope", scope=%q`,
+		scheme,
+		realm,
+		strings.Join(scopes, " "),
+	)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if len(keyMap) > 0 {
-				xApiKey := r.Header.Get("x-api-key")
-				if _, ok := keyMap[xApiKey]; !ok {
-					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			key, ok := FromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", unauthorized)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			for _, scope := range scopes {
+				if !key.HasScope(scope) {
+					w.Header().Set("WWW-Authenticate", forbidden)
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 					return
 				}
 			}
@@ -26,3 +350,18 @@ func Middleware(keys ...string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// ------------------------------------------------------------------------------------------------
+// CONTEXT
+// ------------------------------------------------------------------------------------------------
+
+// NewContext returns a copy of the passed context, holding the passed Key.
+func NewContext(ctx context.Context, key Key) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, &key)
+}
+
+// FromContext returns the Key that authenticated the request, and whether there was one.
+func FromContext(ctx context.Context) (*Key, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(*Key)
+	return key, ok
+}
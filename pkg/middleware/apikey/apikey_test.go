@@ -1,12 +1,17 @@
 package apikey
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // ------------------------------------------------------------------------------------------------
@@ -101,3 +106,263 @@ func Test_ApiKey(t *testing.T) {
 		})
 	}
 }
+
+// serveTestRequest runs the passed request through the passed handler, and returns the recorded
+// response.
+func serveTestRequest(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// Test_Authenticator_sources checks that keys are read from the configured header, Authorization
+// Bearer token or query parameter, and that 401 responses include a WWW-Authenticate challenge.
+func Test_Authenticator_sources(t *testing.T) {
+	keys := []Key{{Hash: HashKey("secret"), Identity: "billing"}}
+	identity := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := FromContext(r.Context())
+		if assert.True(t, ok) {
+			_, _ = w.Write([]byte(key.Identity))
+		}
+	})
+
+	tests := []struct {
+		name              string
+		options           Options
+		header            string
+		value             string
+		target            string
+		expectedCode      int
+		expectedChallenge string
+	}{
+		{
+			"the default header is used when no source is configured",
+			Options{},
+			"X-API-Key",
+			"secret",
+			"/",
+			http.StatusOK,
+			"",
+		},
+		{
+			"a custom header is used",
+			Options{Header: "X-Token"},
+			"X-Token",
+			"secret",
+			"/",
+			http.StatusOK,
+			"",
+		},
+		{
+			"the default header is not used when another source is configured",
+			Options{Bearer: true},
+			"X-API-Key",
+			"secret",
+			"/",
+			http.StatusUnauthorized,
+			`Bearer realm="api"`,
+		},
+		{
+			"a bearer token is used",
+			Options{Bearer: true, Realm: "billing"},
+			"Authorization",
+			"bearer secret",
+			"/",
+			http.StatusOK,
+			"",
+		},
+		{
+			"an invalid bearer token is rejected",
+			Options{Bearer: true, Realm: "billing"},
+			"Authorization",
+			"Bearer wrong",
+			"/",
+			http.StatusUnauthorized,
+			`Bearer realm="billing", error="invalid_token"`,
+		},
+		{
+			"other authorization schemes are ignored",
+			Options{Bearer: true},
+			"Authorization",
+			"Basic secret",
+			"/",
+			http.StatusUnauthorized,
+			`Bearer realm="api"`,
+		},
+		{
+			"a query parameter is used",
+			Options{QueryParam: "api_key"},
+			"",
+			"",
+			"/?api_key=secret",
+			http.StatusOK,
+			"",
+		},
+		{
+			"an invalid header key is rejected",
+			Options{},
+			"X-API-Key",
+			"wrong",
+			"/",
+			http.StatusUnauthorized,
+			`APIKey realm="api", error="invalid_token"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.options.Keys = keys
+			a, err := New(test.options)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+			w := serveTestRequest(a.Middleware()(identity), req)
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedChallenge, w.Header().Get("WWW-Authenticate"))
+			if test.expectedCode == http.StatusOK {
+				assert.Equal(t, "billing", w.Body.String())
+			}
+		})
+	}
+}
+
+// Test_Authenticator_store checks that keys in a KeyStore can be added, expired, revoked and
+// removed at runtime, and that store errors respond with a 500 error.
+func Test_Authenticator_store(t *testing.T) {
+	store := NewMemoryStore(Key{Hash: HashKey("one"), Identity: "one"})
+	a, err := New(Options{Store: store})
+	require.NoError(t, err)
+	h := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(DefaultHeader, key)
+		return serveTestRequest(h, req).Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("one"))
+	assert.Equal(t, http.StatusUnauthorized, request("two"))
+
+	store.Add(Key{Hash: strings.ToUpper(HashKey("two")), Identity: "two"})
+	assert.Equal(t, http.StatusOK, request("two"))
+
+	assert.True(t, store.Revoke(HashKey("two")))
+	assert.False(t, store.Revoke(HashKey("three")))
+	assert.Equal(t, http.StatusUnauthorized, request("two"))
+
+	store.Add(Key{Hash: HashKey("three"), ExpiresAt: time.Now().Add(time.Hour)})
+	assert.Equal(t, http.StatusOK, request("three"))
+	a.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.Equal(t, http.StatusUnauthorized, request("three"))
+
+	store.Remove(HashKey("one"))
+	assert.Equal(t, http.StatusUnauthorized, request("one"))
+
+	a, err = New(Options{Store: failingStore{}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(DefaultHeader, "one")
+	w := serveTestRequest(a.Middleware()(h), req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// failingStore is a KeyStore that always fails.
+type failingStore struct{}
+
+func (failingStore) Lookup(context.Context, string) (Key, error) {
+	return Key{}, errors.New("database unavailable")
+}
+
+// Test_New_errors checks that invalid options are rejected.
+func Test_New_errors(t *testing.T) {
+	_, err := New(Options{})
+	assert.Error(t, err)
+
+	_, err = New(Options{Keys: []Key{}, Store: NewMemoryStore()})
+	assert.Error(t, err)
+
+	_, err = New(Options{Keys: []Key{{Hash: "secret", Identity: "plain"}}})
+	assert.ErrorContains(t, err, `"plain"`)
+}
+
+// Test_RequireScopes checks that routes can require scopes, responding with a 403 error and an
+// insufficient_scope challenge using the authenticator's scheme when the key lacks one, and with
+// a 401 error and a challenge when there is no key.
+func Test_RequireScopes(t *testing.T) {
+	keys := []Key{
+		{Hash: HashKey("reader"), Scopes: []string{"invoices:read"}},
+		{Hash: HashKey("writer"), Scopes: []string{"invoices:read", "invoices:write"}},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		options Options
+		set     func(*http.Request, string)
+		scheme  string
+	}{
+		{
+			"header",
+			Options{Keys: keys, Realm: "billing"},
+			func(r *http.Request, key string) { r.Header.Set(DefaultHeader, key) },
+			"APIKey",
+		},
+		{
+			"bearer",
+			Options{Keys: keys, Realm: "billing", Bearer: true},
+			func(r *http.Request, key string) { r.Header.Set("Authorization", "Bearer "+key) },
+			"Bearer",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := New(test.options)
+			require.NoError(t, err)
+			guard := a.RequireScopes("invoices:read", "invoices:write")
+			h := a.Middleware()(guard(ok))
+
+			req := httptest.NewRequest(http.MethodPost, "/invoices", nil)
+			test.set(req, "writer")
+			assert.Equal(t, http.StatusOK, serveTestRequest(h, req).Code)
+
+			test.set(req, "reader")
+			w := serveTestRequest(h, req)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Equal(
+				t,
+				test.scheme+` realm="billing", error="insufficient_scope", `+
+					`scope="invoices:read invoices:write"`,
+				w.Header().Get("WWW-Authenticate"),
+			)
+
+			// Without the API key middleware, there is no key to check.
+			w = serveTestRequest(guard(ok), req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, test.scheme+` realm="billing"`, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+// Test_RequireScopes_default checks that the package level RequireScopes challenges with the
+// scheme and realm used by Middleware.
+func Test_RequireScopes_default(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := Middleware("key")(RequireScopes("invoices:read")(ok))
+
+	req := httptest.NewRequest(http.MethodGet, "/invoices", nil)
+	req.Header.Set(DefaultHeader, "key")
+	w := serveTestRequest(h, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(
+		t,
+		`APIKey realm="`+DefaultRealm+`", error="insufficient_scope", scope="invoices:read"`,
+		w.Header().Get("WWW-Authenticate"),
+	)
+
+	w = serveTestRequest(RequireScopes("invoices:read")(ok), req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `APIKey realm="`+DefaultRealm+`"`, w.Header().Get("WWW-Authenticate"))
+}
@@ -0,0 +1,241 @@
+// Package basicauth provides middleware that authenticates requests with HTTP Basic
+// authentication, against a static user map or an htpasswd file.
+//
+// Passwords are compared in constant time, and unknown users take as long to reject as known
+// ones. An htpasswd file is reloaded when it changes. Failed attempts are throttled by client IP
+// and by username, to slow down brute force attacks. The authenticated username is stored in the
+// request context, where handlers read it with FromContext, and recorded for httplogger.
+//
+//	auth, err := basicauth.New(basicauth.Options{File: "/etc/app/htpasswd", Realm: "Admin"})
+//	app.Group("/admin").Use(auth.Middleware())
+package basicauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/httplogger"
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/realip"
+)
+
+// ------------------------------------------------------------------------------------------------
+// BASIC AUTH
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the middleware.
+const (
+	DefaultRealm          = "Restricted"
+	DefaultReloadInterval = 5 * time.Second
+	DefaultMaxAttempts    = 10
+	DefaultThrottleWindow = 15 * time.Minute
+)
+
+// Options configures the basic auth middleware.
+type Options struct {
+	// Users maps usernames to plain text passwords. Either Users or File must be set.
+	Users map[string]string
+	// File is the path of an htpasswd file. Either Users or File must be set. bcrypt entries,
+	// created with htpasswd -B, are recommended. Legacy {SHA} entries are accepted, but logged as
+	// insecure, and entries in other formats are skipped.
+	File string
+	// ReloadInterval is how often the File is checked for changes. Defaults to
+	// DefaultReloadInterval.
+	ReloadInterval time.Duration
+	// Realm is reported in the WWW-Authenticate header. Defaults to DefaultRealm.
+	Realm string
+	// MaxAttempts is the number of failed attempts allowed from a client IP, or for a username,
+	// within the ThrottleWindow. Further attempts get a 429 response until the window ends.
+	// Defaults to DefaultMaxAttempts. Set to -1 to disable throttling.
+	MaxAttempts int
+	// ThrottleWindow is the period that failed attempts are counted over. Defaults to
+	// DefaultThrottleWindow.
+	ThrottleWindow time.Duration
+}
+
+// Authenticator authenticates requests with HTTP Basic authentication.
+type Authenticator struct {
+	options   Options
+	challenge string
+	users     atomic.Pointer[users]
+	throttle  *throttle
+	now       func() time.Time
+
+	// reload serialises reloads of the File, and guards the fields below.
+	reload  sync.Mutex
+	checked time.Time
+	modTime time.Time
+	size    int64
+}
+
+// userKey is the context key for the authenticated username.
+type userKey struct{}
+
+// New creates, initialises and returns a pointer to a new Authenticator. An error is returned if
+// neither or both of Users and File are set, or if the File can't be loaded.
+func New(options Options) (*Authenticator, error) {
+	if (options.Users == nil) == (options.File == "") {
+		return nil, errors.New("basic authenticator requires either users or a file")
+	}
+	if options.ReloadInterval <= 0 {
+		options.ReloadInterval = DefaultReloadInterval
+	}
+	if options.Realm == "" {
+		options.Realm = DefaultRealm
+	}
+	if options.MaxAttempts == 0 {
+		options.MaxAttempts = DefaultMaxAttempts
+	}
+	if options.ThrottleWindow <= 0 {
+		options.ThrottleWindow = DefaultThrottleWindow
+	}
+
+	a := &Authenticator{
+		options:   options,
+		challenge: fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, options.Realm),
+		now:       time.Now,
+	}
+	if options.MaxAttempts > 0 {
+		a.throttle = newThrottle(options.MaxAttempts, options.ThrottleWindow)
+	}
+	if options.File == "" {
+		a.users.Store(newStaticUsers(options.Users))
+		return a, nil
+	}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Middleware returns a middleware function that rejects requests without valid credentials with
+// a 401 response, and stores the username in the request context.
+func (a *Authenticator) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			a.maybeReload()
+
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				a.unauthorized(w)
+				return
+			}
+
+			now := a.now()
+			keys := []string{"ip:" + realip.ClientIP(r), "user:" + username}
+			if a.throttle != nil {
+				if wait, blocked := a.throttle.blocked(now, keys...); blocked {
+					seconds := int(math.Ceil(wait.Seconds()))
+					w.Header().Set("Retry-After", strconv.Itoa(seconds))
+					http.Error(
+						w,
+						http.StatusText(http.StatusTooManyRequests),
+						http.StatusTooManyRequests,
+					)
+					return
+				}
+			}
+
+			if !a.users.Load().verify(username, password) {
+				if a.throttle != nil && a.throttle.fail(now, keys...) {
+					slog.Warn(
+						"basic auth attempts throttled",
+						"type", "auth",
+						"ip", realip.ClientIP(r),
+						"user", username,
+					)
+				}
+				a.unauthorized(w)
+				return
+			}
+			if a.throttle != nil {
+				a.throttle.clear(keys[1])
+			}
+
+			ctx := httplogger.WithUser(NewContext(r.Context(), username), username)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// unauthorized writes a 401 response with a WWW-Authenticate challenge.
+func (a *Authenticator) unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", a.challenge)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// maybeReload reloads the File if the reload interval has passed and it has changed. If another
+// request is already checking, it carries on with the current users.
+func (a *Authenticator) maybeReload() {
+	if a.options.File == "" || !a.reload.TryLock() {
+		return
+	}
+	defer a.reload.Unlock()
+
+	now := a.now()
+	if now.Sub(a.checked) < a.options.ReloadInterval {
+		return
+	}
+	a.checked = now
+	info, err := os.Stat(a.options.File)
+	if err == nil && info.ModTime().Equal(a.modTime) && info.Size() == a.size {
+		return
+	}
+	if err == nil {
+		err = a.loadLocked()
+	}
+	if err != nil {
+		slog.Error("failed to reload htpasswd file", "type", "auth", "error", err)
+	}
+}
+
+// load loads the File.
+func (a *Authenticator) load() error {
+	a.reload.Lock()
+	defer a.reload.Unlock()
+	a.checked = a.now()
+	return a.loadLocked()
+}
+
+// loadLocked loads the File. It must be called with the reload lock held.
+func (a *Authenticator) loadLocked() error {
+	info, err := os.Stat(a.options.File)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(a.options.File)
+	if err != nil {
+		return err
+	}
+	u, err := parseHtpasswd(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", a.options.File, err)
+	}
+	a.users.Store(u)
+	a.modTime, a.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------
+// CONTEXT
+// ------------------------------------------------------------------------------------------------
+
+// NewContext returns a copy of the passed context, holding the passed username.
+func NewContext(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, userKey{}, username)
+}
+
+// FromContext returns the authenticated username held by the passed context, or an empty string
+// if there isn't one.
+func FromContext(ctx context.Context) string {
+	username, _ := ctx.Value(userKey{}).(string)
+	return username
+}
@@ -0,0 +1,153 @@
+package basicauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/httplogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// BASIC AUTH TESTS
+// ------------------------------------------------------------------------------------------------
+
+// usernameHandler writes the authenticated username.
+var usernameHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(FromContext(r.Context())))
+})
+
+// serveBasicAuth runs a request with the passed credentials through the passed handler.
+func serveBasicAuth(h http.Handler, username, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// Test_Authenticator checks that valid credentials are accepted, and that other requests get a
+// 401 response with a challenge for the realm.
+func Test_Authenticator(t *testing.T) {
+	a, err := New(Options{Users: map[string]string{"alice": "wonderland"}, Realm: "Admin"})
+	require.NoError(t, err)
+	h := a.Middleware()(usernameHandler)
+
+	w := serveBasicAuth(h, "alice", "wonderland")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	for _, credentials := range [][2]string{
+		{"alice", "wrong"},
+		{"bob", "wonderland"},
+		{"", ""},
+	} {
+		w := serveBasicAuth(h, credentials[0], credentials[1])
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Basic realm="Admin", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+// Test_Authenticator_file checks that an htpasswd file is reloaded when it changes, and that the
+// current users are kept if the new file is invalid.
+func Test_Authenticator_file(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "htpasswd")
+	require.NoError(t, os.WriteFile(file, []byte("alice:"+bcryptHash(t, "one")+"\n"), 0o600))
+	a, err := New(Options{File: file, ReloadInterval: time.Minute})
+	require.NoError(t, err)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	h := a.Middleware()(usernameHandler)
+
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "alice", "one").Code)
+
+	data := "alice:" + bcryptHash(t, "two") + "\nbob:" + sha1Hash("three") + "\n"
+	require.NoError(t, os.WriteFile(file, []byte(data), 0o600))
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "alice", "one").Code)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, serveBasicAuth(h, "alice", "one").Code)
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "alice", "two").Code)
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "bob", "three").Code)
+
+	require.NoError(t, os.WriteFile(file, []byte("invalid\n"), 0o600))
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "alice", "two").Code)
+}
+
+// Test_Authenticator_throttle checks that failed attempts are throttled by username and client
+// IP, and that the block ends with the window.
+func Test_Authenticator_throttle(t *testing.T) {
+	a, err := New(Options{
+		Users:          map[string]string{"alice": "wonderland", "bob": "builder"},
+		MaxAttempts:    3,
+		ThrottleWindow: time.Minute,
+	})
+	require.NoError(t, err)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	h := a.Middleware()(usernameHandler)
+
+	// A success clears the failures for the user.
+	serveBasicAuth(h, "alice", "wrong")
+	serveBasicAuth(h, "alice", "wrong")
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "alice", "wonderland").Code)
+	assert.NotContains(t, a.throttle.failures, "user:alice")
+
+	// The third failure from the IP blocks it, even for other users with valid credentials.
+	now = now.Add(30 * time.Second)
+	w := serveBasicAuth(h, "bob", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serveBasicAuth(h, "bob", "builder")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "bob", "builder").Code)
+
+	// Throttling can be disabled.
+	a, err = New(Options{Users: map[string]string{"alice": "wonderland"}, MaxAttempts: -1})
+	require.NoError(t, err)
+	h = a.Middleware()(usernameHandler)
+	for range DefaultMaxAttempts + 1 {
+		serveBasicAuth(h, "alice", "wrong")
+	}
+	assert.Equal(t, http.StatusOK, serveBasicAuth(h, "alice", "wonderland").Code)
+}
+
+// Test_Authenticator_httplogger checks that the username is recorded by an outer httplogger.
+func Test_Authenticator_httplogger(t *testing.T) {
+	a, err := New(Options{Users: map[string]string{"alice": "wonderland"}})
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	h := httplogger.Middleware(httplogger.Options{
+		Logger: slog.New(slog.NewJSONHandler(buf, nil)),
+	})(a.Middleware()(usernameHandler))
+
+	serveBasicAuth(h, "alice", "wonderland")
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "alice", record["user"])
+}
+
+// Test_New_errors checks that invalid options are rejected.
+func Test_New_errors(t *testing.T) {
+	_, err := New(Options{})
+	assert.Error(t, err)
+
+	_, err = New(Options{Users: map[string]string{}, File: "htpasswd"})
+	assert.Error(t, err)
+
+	_, err = New(Options{File: filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
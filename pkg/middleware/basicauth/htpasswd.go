@@ -0,0 +1,140 @@
+package basicauth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 - only used to verify legacy {SHA} htpasswd entries
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CREDENTIALS
+// ------------------------------------------------------------------------------------------------
+
+// credential verifies a user's password.
+type credential interface {
+	verify(password string) bool
+}
+
+// plainCredential is a password from the static user map, held as its SHA-256 hash so that it can
+// be compared in constant time without revealing its length.
+type plainCredential [sha256.Size]byte
+
+func (c plainCredential) verify(password string) bool {
+	sum := sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare(c[:], sum[:]) == 1
+}
+
+// bcryptCredential is a bcrypt htpasswd entry, created with htpasswd -B.
+type bcryptCredential []byte
+
+func (c bcryptCredential) verify(password string) bool {
+	return bcrypt.CompareHashAndPassword(c, []byte(password)) == nil
+}
+
+// sha1Credential is a legacy {SHA} htpasswd entry, created with htpasswd -s. Unsalted SHA-1 is
+// easily cracked, so these entries are logged as insecure when they are loaded.
+type sha1Credential []byte
+
+func (c sha1Credential) verify(password string) bool {
+	sum := sha1.Sum([]byte(password)) // #nosec G401
+	return subtle.ConstantTimeCompare(c, sum[:]) == 1
+}
+
+// randomCredential returns a credential for a random password, which is verified for unknown
+// users so that the time taken doesn't reveal which users exist. If cost is above zero, it is a
+// bcrypt hash with that cost, so that it takes as long to verify as the bcrypt entries.
+func randomCredential(cost int) (credential, error) {
+	password := []byte(rand.Text())
+	if cost <= 0 {
+		return plainCredential(sha256.Sum256(password)), nil
+	}
+	hash, err := bcrypt.GenerateFromPassword(password, cost)
+	return bcryptCredential(hash), err
+}
+
+// users holds the credentials of each user.
+type users struct {
+	credentials map[string]credential
+	// unknown is verified for unknown users, and takes as long as the slowest credential.
+	unknown credential
+}
+
+// verify reports whether the passed username and password are valid.
+func (u *users) verify(username, password string) bool {
+	c, ok := u.credentials[username]
+	if !ok {
+		u.unknown.verify(password)
+		return false
+	}
+	return c.verify(password)
+}
+
+// newStaticUsers returns the users for the passed map of usernames to plain text passwords.
+func newStaticUsers(passwords map[string]string) *users {
+	u := &users{credentials: make(map[string]credential, len(passwords))}
+	u.unknown, _ = randomCredential(0)
+	for username, password := range passwords {
+		u.credentials[username] = plainCredential(sha256.Sum256([]byte(password)))
+	}
+	return u
+}
+
+// parseHtpasswd parses the passed htpasswd file data. Blank lines and lines starting with # are
+// ignored. bcrypt entries are supported, as are legacy {SHA} entries, which are logged as
+// insecure. Entries in other formats, such as MD5 and crypt, are logged and skipped.
+func parseHtpasswd(data []byte) (*users, error) {
+	u := &users{credentials: map[string]credential{}}
+	maxCost := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(entry, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("htpasswd line %d: expected username:hash", line)
+		}
+
+		switch {
+		case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"),
+			strings.HasPrefix(hash, "$2y$"):
+			cost, err := bcrypt.Cost([]byte(hash))
+			if err != nil {
+				return nil, fmt.Errorf("htpasswd line %d: %w", line, err)
+			}
+			u.credentials[username] = bcryptCredential(hash)
+			maxCost = max(maxCost, cost)
+		case strings.HasPrefix(hash, "{SHA}"):
+			sum, err := base64.StdEncoding.DecodeString(hash[len("{SHA}"):])
+			if err != nil || len(sum) != sha1.Size {
+				return nil, fmt.Errorf("htpasswd line %d: invalid {SHA} hash", line)
+			}
+			slog.Warn(
+				"insecure htpasswd hash, use bcrypt instead",
+				"type", "auth",
+				"user", username,
+				"scheme", "SHA1",
+			)
+			u.credentials[username] = sha1Credential(sum)
+		default:
+			slog.Warn("unsupported htpasswd hash, skipping user", "type", "auth", "user", username)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	u.unknown, err = randomCredential(maxCost)
+	return u, err
+}
@@ -0,0 +1,69 @@
+package basicauth
+
+import (
+	"crypto/sha1" // #nosec G505
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ------------------------------------------------------------------------------------------------
+// HTPASSWD TESTS
+// ------------------------------------------------------------------------------------------------
+
+// bcryptHash returns a low cost bcrypt hash of the passed password, in htpasswd's $2y$ form.
+func bcryptHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return "$2y$" + string(hash[4:])
+}
+
+// sha1Hash returns a legacy {SHA} hash of the passed password.
+func sha1Hash(password string) string {
+	sum := sha1.Sum([]byte(password)) // #nosec G401
+	return "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Test_parseHtpasswd checks that bcrypt and {SHA} entries are parsed, and other entries skipped.
+func Test_parseHtpasswd(t *testing.T) {
+	u, err := parseHtpasswd([]byte(
+		"# admins\n" +
+			"alice:" + bcryptHash(t, "wonderland") + "\n" +
+			"\n" +
+			"  bob:" + sha1Hash("builder") + "  \n" +
+			"carol:$apr1$salt$hash\n",
+	))
+	require.NoError(t, err)
+	assert.Len(t, u.credentials, 2)
+	assert.IsType(t, bcryptCredential{}, u.unknown)
+
+	assert.True(t, u.verify("alice", "wonderland"))
+	assert.False(t, u.verify("alice", "Wonderland"))
+	assert.True(t, u.verify("bob", "builder"))
+	assert.False(t, u.verify("bob", "builder "))
+	assert.False(t, u.verify("carol", "hash"))
+	assert.False(t, u.verify("dave", ""))
+
+	for _, data := range []string{
+		"no separator\n",
+		":" + sha1Hash("x") + "\n",
+		"alice:$2y$99$invalid\n",
+		"bob:{SHA}not-base64\n",
+	} {
+		_, err := parseHtpasswd([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+// Test_newStaticUsers checks that plain text passwords are verified.
+func Test_newStaticUsers(t *testing.T) {
+	u := newStaticUsers(map[string]string{"alice": "wonderland", "empty": ""})
+	assert.True(t, u.verify("alice", "wonderland"))
+	assert.False(t, u.verify("alice", "wonderland2"))
+	assert.True(t, u.verify("empty", ""))
+	assert.False(t, u.verify("bob", ""))
+}
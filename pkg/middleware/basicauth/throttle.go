@@ -0,0 +1,86 @@
+package basicauth
+
+import (
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// THROTTLE
+// ------------------------------------------------------------------------------------------------
+
+// throttle counts failed attempts by key, such as by client IP and by username, and blocks a key
+// once it reaches the maximum number of failures within the window.
+type throttle struct {
+	max    int
+	window time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*failures
+	swept    time.Time
+}
+
+// failures is the number of failed attempts for a key, in the window that ends at reset.
+type failures struct {
+	count int
+	reset time.Time
+}
+
+// newThrottle creates, initialises and returns a pointer to a new throttle.
+func newThrottle(max int, window time.Duration) *throttle {
+	return &throttle{max: max, window: window, failures: map[string]*failures{}}
+}
+
+// blocked returns how long until the first of the passed keys that is blocked is allowed again,
+// and whether any of them are blocked.
+func (t *throttle) blocked(now time.Time, keys ...string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		if f, ok := t.failures[key]; ok && f.count >= t.max && now.Before(f.reset) {
+			return f.reset.Sub(now), true
+		}
+	}
+	return 0, false
+}
+
+// fail records a failed attempt for each of the passed keys, and reports whether any of them are
+// now blocked.
+func (t *throttle) fail(now time.Time, keys ...string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweep(now)
+
+	blocked := false
+	for _, key := range keys {
+		f, ok := t.failures[key]
+		if !ok || !now.Before(f.reset) {
+			f = &failures{reset: now.Add(t.window)}
+			t.failures[key] = f
+		}
+		f.count++
+		blocked = blocked || f.count >= t.max
+	}
+	return blocked
+}
+
+// clear removes the failed attempts for the passed key.
+func (t *throttle) clear(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, key)
+}
+
+// sweep removes expired keys, at most once per window, so that memory use is bounded by the
+// number of keys that fail within a window. It must be called with the lock held.
+func (t *throttle) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.window {
+		return
+	}
+	t.swept = now
+	for key, f := range t.failures {
+		if !now.Before(f.reset) {
+			delete(t.failures, key)
+		}
+	}
+}
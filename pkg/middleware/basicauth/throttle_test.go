@@ -0,0 +1,40 @@
+package basicauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// THROTTLE TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_throttle checks that keys are blocked after the maximum failures within the window, and
+// allowed again once it ends.
+func Test_throttle(t *testing.T) {
+	th := newThrottle(2, time.Minute)
+	now := time.Now()
+
+	assert.False(t, th.fail(now, "ip:a", "user:x"))
+	_, blocked := th.blocked(now, "ip:a", "user:x")
+	assert.False(t, blocked)
+
+	assert.True(t, th.fail(now.Add(10*time.Second), "ip:a", "user:y"))
+	wait, blocked := th.blocked(now.Add(20*time.Second), "ip:b", "ip:a")
+	assert.True(t, blocked)
+	assert.Equal(t, 40*time.Second, wait)
+
+	// Clearing one key leaves the others blocked.
+	th.clear("user:y")
+	_, blocked = th.blocked(now.Add(20*time.Second), "ip:a")
+	assert.True(t, blocked)
+
+	_, blocked = th.blocked(now.Add(time.Minute), "ip:a")
+	assert.False(t, blocked)
+
+	// Expired keys are swept.
+	th.fail(now.Add(2*time.Minute), "ip:c")
+	assert.Len(t, th.failures, 1)
+}
@@ -0,0 +1,307 @@
+// Package csrf provides middleware that protects forms and other state changing requests from
+// cross site request forgery.
+//
+// Unsafe requests, which are those not using GET, HEAD, OPTIONS or TRACE, must come from the same
+// origin, or a trusted origin, according to the Sec-Fetch-Site, Origin or Referer headers, and
+// must carry the CSRF token for the client in a header or form field. The token is held in a
+// cookie (the double submit pattern) or in the session (the synchronizer token pattern), and is
+// exposed to handlers and templates with Token and TemplateField.
+//
+//	protector, err := csrf.New(csrf.Options{
+//		ErrorHandler: app.ErrorHandler(http.StatusForbidden),
+//	})
+//	app.Use(protector.Middleware())
+//
+//	app.Get("/profile", func(w http.ResponseWriter, r *http.Request) {
+//		tmpl.Execute(w, map[string]any{"CSRFField": csrf.TemplateField(r.Context())})
+//	})
+package csrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/sessions"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CSRF
+// ------------------------------------------------------------------------------------------------
+
+// Mode selects where the token for a client is held.
+type Mode int
+
+const (
+	// DoubleSubmit holds the token in a cookie, which must match the token sent with the request.
+	// It needs no server side state.
+	DoubleSubmit Mode = iota
+	// Synchronizer holds the token in the session, so the sessions middleware must run first.
+	Synchronizer
+)
+
+// Defaults used by the middleware.
+const (
+	DefaultCookieName         = "__Host-csrf"
+	DefaultInsecureCookieName = "csrf"
+	DefaultHeader             = "X-CSRF-Token"
+	DefaultFormField          = "csrf_token"
+)
+
+// SessionKey is the session key that the token is held under in Synchronizer mode.
+const SessionKey = "csrf_token"
+
+// Errors reported by FailureReason when the token of a request is missing or wrong.
+var (
+	ErrMissingToken = errors.New("csrf token missing")
+	ErrInvalidToken = errors.New("csrf token invalid")
+)
+
+// Options configures the CSRF middleware.
+type Options struct {
+	// Mode selects where the token is held. Defaults to DoubleSubmit.
+	Mode Mode
+	// TrustedOrigins are other origins that may send unsafe requests, such as
+	// "https://admin.example.com". Requests from the host of the request are always trusted.
+	TrustedOrigins []string
+	// Exempt are route patterns that aren't checked, such as "/webhooks/{provider}" or
+	// "POST /webhooks/{provider}", compared with the pattern of the matched route.
+	Exempt []string
+	// ExemptFunc reports whether a request isn't checked, such as one authenticated with an API
+	// key rather than a cookie.
+	ExemptFunc func(*http.Request) bool
+	// Header is the request header that carries the token. Defaults to DefaultHeader.
+	Header string
+	// FormField is the form field that carries the token, if the header isn't set. Defaults to
+	// DefaultFormField.
+	FormField string
+	// CookieName is the name of the cookie that holds the token in DoubleSubmit mode. Defaults to
+	// DefaultCookieName, or DefaultInsecureCookieName if Insecure is set, as the __Host- prefix
+	// requires a secure cookie.
+	CookieName string
+	// Insecure allows the cookie to be sent over plain HTTP, such as for local development.
+	Insecure bool
+	// SameSite is the SameSite attribute of the cookie. Defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// ErrorHandler writes the response for a rejected request, where FailureReason reports why.
+	// Defaults to a plain 403 response. Pass app.ErrorHandler(http.StatusForbidden) to use the
+	// App's 403 handler.
+	ErrorHandler http.Handler
+}
+
+// Protector checks requests for CSRF tokens.
+type Protector struct {
+	options Options
+	trusted map[string]bool
+	exempt  map[string]bool
+}
+
+// state is the CSRF state of a request, held in its context.
+type state struct {
+	mu      sync.Mutex
+	options *Options
+	// token is the raw token. In Synchronizer mode it is nil until one is needed.
+	token []byte
+	// err is the reason the request was rejected.
+	err error
+}
+
+// stateKey is the context key for the CSRF state.
+type stateKey struct{}
+
+// New creates, initialises and returns a pointer to a new Protector. An error is returned if a
+// trusted origin isn't a valid origin.
+func New(options Options) (*Protector, error) {
+	if options.Header == "" {
+		options.Header = DefaultHeader
+	}
+	if options.FormField == "" {
+		options.FormField = DefaultFormField
+	}
+	if options.CookieName == "" {
+		options.CookieName = DefaultCookieName
+		if options.Insecure {
+			options.CookieName = DefaultInsecureCookieName
+		}
+	}
+	if options.SameSite == 0 {
+		options.SameSite = http.SameSiteLaxMode
+	}
+	if options.ErrorHandler == nil {
+		options.ErrorHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+
+	p := &Protector{options: options, trusted: map[string]bool{}, exempt: map[string]bool{}}
+	for _, origin := range options.TrustedOrigins {
+		o, err := parseOrigin(origin)
+		if err != nil {
+			return nil, err
+		}
+		p.trusted[o] = true
+	}
+	for _, pattern := range options.Exempt {
+		p.exempt[pattern] = true
+	}
+	return p, nil
+}
+
+// Middleware returns a middleware function that rejects unsafe requests from untrusted origins,
+// or without a valid token, and makes the token available to handlers through the request
+// context.
+func (p *Protector) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := &state{options: &p.options}
+			ctx := context.WithValue(r.Context(), stateKey{}, s)
+			r = r.WithContext(ctx)
+
+			var expected []byte
+			switch p.options.Mode {
+			case Synchronizer:
+				if sessions.FromContext(ctx) == nil {
+					slog.Error(
+						"csrf synchronizer mode requires the sessions middleware",
+						"type", "csrf",
+					)
+					http.Error(
+						w,
+						http.StatusText(http.StatusInternalServerError),
+						http.StatusInternalServerError,
+					)
+					return
+				}
+				value, _ := sessions.Get[string](ctx, SessionKey)
+				expected = decodeToken(value)
+				s.token = expected
+			default:
+				if cookie, err := r.Cookie(p.options.CookieName); err == nil {
+					expected = decodeToken(cookie.Value)
+				}
+				s.token = expected
+				if s.token == nil {
+					s.token = newToken()
+					http.SetCookie(w, p.cookie(s.token))
+				}
+			}
+
+			if !safeMethod(r.Method) && !p.isExempt(r) {
+				if err := p.check(r, expected); err != nil {
+					s.err = err
+					p.options.ErrorHandler.ServeHTTP(w, r)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check checks the origin and token of the passed unsafe request.
+func (p *Protector) check(r *http.Request, expected []byte) error {
+	if err := checkOrigin(r, p.trusted); err != nil {
+		return err
+	}
+	value := r.Header.Get(p.options.Header)
+	if value == "" {
+		value = r.PostFormValue(p.options.FormField)
+	}
+	if value == "" {
+		return ErrMissingToken
+	}
+	if !tokensEqual(unmaskToken(value), expected) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// isExempt reports whether the passed request isn't checked.
+func (p *Protector) isExempt(r *http.Request) bool {
+	if r.Pattern != "" {
+		_, path, _ := strings.Cut(r.Pattern, " ")
+		if p.exempt[r.Pattern] || p.exempt[path] {
+			return true
+		}
+	}
+	return p.options.ExemptFunc != nil && p.options.ExemptFunc(r)
+}
+
+// cookie returns the cookie that holds the passed token in DoubleSubmit mode.
+func (p *Protector) cookie(token []byte) *http.Cookie {
+	return &http.Cookie{
+		Name:     p.options.CookieName,
+		Value:    encodeToken(token),
+		Path:     "/",
+		Secure:   !p.options.Insecure,
+		HttpOnly: true,
+		SameSite: p.options.SameSite,
+	}
+}
+
+// safeMethod reports whether the passed method is safe, so the request doesn't need a token.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// ------------------------------------------------------------------------------------------------
+// CONTEXT
+// ------------------------------------------------------------------------------------------------
+
+// Token returns the token to send with unsafe requests, in the header or form field, or an empty
+// string if the CSRF middleware hasn't run. A different value is returned each time, and every
+// value is valid. In Synchronizer mode, a token is stored in the session the first time it is
+// needed, so Token must be called before the response is written.
+func Token(ctx context.Context) string {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == nil {
+		token := newToken()
+		if err := sessions.Set(ctx, SessionKey, encodeToken(token)); err != nil {
+			return ""
+		}
+		s.token = token
+	}
+	return maskToken(s.token)
+}
+
+// TemplateField returns a hidden form input holding the token, for use in HTML templates, or an
+// empty string if the CSRF middleware hasn't run.
+func TemplateField(ctx context.Context) template.HTML {
+	s, ok := ctx.Value(stateKey{}).(*state)
+	if !ok {
+		return ""
+	}
+	token := Token(ctx)
+	if token == "" {
+		return ""
+	}
+	// #nosec G203 - the name is escaped, and the token is base64.
+	return template.HTML(fmt.Sprintf(
+		`<input type="hidden" name="%s" value="%s">`,
+		template.HTMLEscapeString(s.options.FormField),
+		token,
+	))
+}
+
+// FailureReason returns the reason the request was rejected, for use in the ErrorHandler, or nil
+// if it wasn't.
+func FailureReason(ctx context.Context) error {
+	if s, ok := ctx.Value(stateKey{}).(*state); ok {
+		return s.err
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CSRF TESTS
+// ------------------------------------------------------------------------------------------------
+
+// tokenHandler writes the token for the request.
+var tokenHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(Token(r.Context())))
+})
+
+// serveCSRF runs the passed request, with the passed cookies, through the passed handler.
+func serveCSRF(
+	h http.Handler,
+	r *http.Request,
+	cookies ...*http.Cookie,
+) *httptest.ResponseRecorder {
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// fetchToken makes a GET request through the passed handler, and returns the token and cookie
+// that it set.
+func fetchToken(t *testing.T, h http.Handler) (string, *http.Cookie) {
+	t.Helper()
+	w := serveCSRF(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return w.Body.String(), cookies[0]
+}
+
+// Test_Middleware_DoubleSubmit checks that a token from a GET request is accepted in the header
+// or a form field of an unsafe request with the matching cookie, and that other unsafe requests
+// are rejected.
+func Test_Middleware_DoubleSubmit(t *testing.T) {
+	p, err := New(Options{})
+	require.NoError(t, err)
+	h := p.Middleware()(tokenHandler)
+
+	token, cookie := fetchToken(t, h)
+	assert.Equal(t, DefaultCookieName, cookie.Name)
+	assert.Equal(t, "/", cookie.Path)
+	assert.True(t, cookie.Secure)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	w := serveCSRF(h, httptest.NewRequest(http.MethodGet, "/", nil), cookie)
+	assert.Empty(t, w.Result().Cookies(), "an existing cookie should be kept")
+	assert.NotEqual(t, token, w.Body.String())
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(DefaultHeader, token)
+	assert.Equal(t, http.StatusOK, serveCSRF(h, r, cookie).Code)
+
+	form := url.Values{DefaultFormField: {token}}
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.Equal(t, http.StatusOK, serveCSRF(h, r, cookie).Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	assert.Equal(t, http.StatusForbidden, serveCSRF(h, r, cookie).Code)
+
+	r = httptest.NewRequest(http.MethodDelete, "/", nil)
+	r.Header.Set(DefaultHeader, token)
+	assert.Equal(t, http.StatusForbidden, serveCSRF(h, r).Code, "the cookie is required")
+
+	_, other := fetchToken(t, h)
+	r = httptest.NewRequest(http.MethodPut, "/", nil)
+	r.Header.Set(DefaultHeader, token)
+	assert.Equal(t, http.StatusForbidden, serveCSRF(h, r, other).Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(DefaultHeader, token)
+	r.Header.Set("Sec-Fetch-Site", "cross-site")
+	assert.Equal(t, http.StatusForbidden, serveCSRF(h, r, cookie).Code)
+}
+
+// Test_Middleware_ErrorHandler checks that the ErrorHandler responds to rejected requests, and
+// can read the reason.
+func Test_Middleware_ErrorHandler(t *testing.T) {
+	p, err := New(Options{
+		ErrorHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(FailureReason(r.Context()).Error()))
+		}),
+	})
+	require.NoError(t, err)
+	h := p.Middleware()(tokenHandler)
+	token, cookie := fetchToken(t, h)
+
+	tests := []struct {
+		name    string
+		token   string
+		headers map[string]string
+		err     error
+	}{
+		{"missing token", "", nil, ErrMissingToken},
+		{"invalid token", "invalid", nil, ErrInvalidToken},
+		{"unmasked token", cookie.Value, nil, ErrInvalidToken},
+		{"cross origin", token, map[string]string{"Origin": "https://evil.com"}, ErrBadOrigin},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.Header.Set(DefaultHeader, test.token)
+			for key, value := range test.headers {
+				r.Header.Set(key, value)
+			}
+			w := serveCSRF(h, r, cookie)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Equal(t, test.err.Error(), w.Body.String())
+		})
+	}
+
+	assert.NoError(t, FailureReason(context.Background()))
+}
+
+// Test_Middleware_Exempt checks that exempt route patterns and requests aren't checked.
+func Test_Middleware_Exempt(t *testing.T) {
+	p, err := New(Options{
+		Exempt: []string{"/webhooks/{provider}", "PUT /items/{id}"},
+		ExemptFunc: func(r *http.Request) bool {
+			return r.Header.Get("X-API-Key") == "secret"
+		},
+	})
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.Handle("POST /webhooks/{provider}", p.Middleware()(tokenHandler))
+	mux.Handle("/items/{id}", p.Middleware()(tokenHandler))
+
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/stripe", nil)
+	assert.Equal(t, http.StatusOK, serveCSRF(mux, r).Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/items/1", nil)
+	assert.Equal(t, http.StatusForbidden, serveCSRF(mux, r).Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/items/1", nil)
+	r.Header.Set("X-API-Key", "secret")
+	assert.Equal(t, http.StatusOK, serveCSRF(mux, r).Code)
+}
+
+// Test_Middleware_Synchronizer checks that the token is held in the session, and only stored
+// once it is needed.
+func Test_Middleware_Synchronizer(t *testing.T) {
+	manager, err := sessions.New(sessions.Options{Keys: [][]byte{bytes.Repeat([]byte{1}, 32)}})
+	require.NoError(t, err)
+	p, err := New(Options{Mode: Synchronizer})
+	require.NoError(t, err)
+	h := manager.Middleware()(p.Middleware()(tokenHandler))
+	silent := manager.Middleware()(p.Middleware()(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	)))
+
+	w := serveCSRF(silent, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, w.Result().Cookies(), "no session should be started without a token")
+
+	token, cookie := fetchToken(t, h)
+	assert.Equal(t, sessions.DefaultCookieName, cookie.Name)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(DefaultHeader, token)
+	assert.Equal(t, http.StatusOK, serveCSRF(h, r, cookie).Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(DefaultHeader, token)
+	assert.Equal(t, http.StatusForbidden, serveCSRF(h, r).Code, "the session is required")
+}
+
+// Test_Middleware_NoSessions checks that Synchronizer mode fails without the sessions
+// middleware.
+func Test_Middleware_NoSessions(t *testing.T) {
+	p, err := New(Options{Mode: Synchronizer})
+	require.NoError(t, err)
+	w := serveCSRF(p.Middleware()(tokenHandler), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// Test_TemplateField checks that the form field holds a valid token, and is empty without the
+// middleware.
+func Test_TemplateField(t *testing.T) {
+	assert.Empty(t, Token(context.Background()))
+	assert.Empty(t, TemplateField(context.Background()))
+
+	p, err := New(Options{FormField: "_csrf", Insecure: true})
+	require.NoError(t, err)
+	var field string
+	w := serveCSRF(
+		p.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			field = string(TemplateField(r.Context()))
+		})),
+		httptest.NewRequest(http.MethodGet, "/", nil),
+	)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, DefaultInsecureCookieName, cookies[0].Name)
+	assert.False(t, cookies[0].Secure)
+
+	prefix := `<input type="hidden" name="_csrf" value="`
+	require.True(t, strings.HasPrefix(field, prefix), field)
+	token := strings.TrimSuffix(strings.TrimPrefix(field, prefix), `">`)
+	assert.True(t, tokensEqual(unmaskToken(token), decodeToken(cookies[0].Value)))
+}
+
+// Test_New checks that invalid trusted origins are rejected.
+func Test_New(t *testing.T) {
+	_, err := New(Options{TrustedOrigins: []string{"https://admin.example.com"}})
+	assert.NoError(t, err)
+	_, err = New(Options{TrustedOrigins: []string{"admin.example.com"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package csrf
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ORIGINS
+// ------------------------------------------------------------------------------------------------
+
+// Errors reported by FailureReason when the origin of a request can't be trusted.
+var (
+	ErrCrossOrigin = errors.New("cross origin request")
+	ErrBadOrigin   = errors.New("origin not trusted")
+	ErrBadReferer  = errors.New("referer not trusted")
+)
+
+// parseOrigin returns the passed origin, such as "https://example.com:8443", in the lower case
+// form that browsers send in the Origin header.
+func parseOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", fmt.Errorf("invalid trusted origin %q: %w", origin, err)
+	}
+	if u.Scheme == "" || u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") ||
+		u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf(
+			"invalid trusted origin %q: must be a scheme and host, such as https://example.com",
+			origin,
+		)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// checkOrigin checks that the passed request came from the same origin, or a trusted origin. The
+// Sec-Fetch-Site header is used if the browser sent it, then the Origin header, then the Referer
+// header. Requests without any of them, such as from clients that aren't browsers, are allowed,
+// as they still need a valid token.
+func checkOrigin(r *http.Request, trusted map[string]bool) error {
+	origin := r.Header.Get("Origin")
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return nil
+	case "":
+	default:
+		if trusted[strings.ToLower(origin)] {
+			return nil
+		}
+		return ErrCrossOrigin
+	}
+
+	if origin != "" {
+		if sameOrigin(r, origin, trusted) {
+			return nil
+		}
+		return ErrBadOrigin
+	}
+	if referer := r.Header.Get("Referer"); referer != "" {
+		if sameOrigin(r, referer, trusted) {
+			return nil
+		}
+		return ErrBadReferer
+	}
+	return nil
+}
+
+// sameOrigin reports whether the passed URL is on the host of the request, or a trusted origin.
+func sameOrigin(r *http.Request, rawURL string, trusted map[string]bool) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || trusted[strings.ToLower(u.Scheme+"://"+u.Host)]
+}
@@ -0,0 +1,73 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ORIGIN TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_checkOrigin checks that same origin and trusted requests are allowed, and cross origin
+// requests are rejected, using whichever headers the client sent.
+func Test_checkOrigin(t *testing.T) {
+	trusted := map[string]bool{"https://admin.example.com": true}
+	tests := []struct {
+		name    string
+		headers map[string]string
+		err     error
+	}{
+		{"no headers", nil, nil},
+		{"same origin fetch", map[string]string{"Sec-Fetch-Site": "same-origin"}, nil},
+		{"user initiated fetch", map[string]string{"Sec-Fetch-Site": "none"}, nil},
+		{"cross site fetch", map[string]string{
+			"Sec-Fetch-Site": "cross-site",
+			"Origin":         "https://evil.com",
+		}, ErrCrossOrigin},
+		{"same site fetch", map[string]string{
+			"Sec-Fetch-Site": "same-site",
+			"Origin":         "https://other.example.com",
+		}, ErrCrossOrigin},
+		{"trusted fetch", map[string]string{
+			"Sec-Fetch-Site": "same-site",
+			"Origin":         "https://Admin.example.com",
+		}, nil},
+		{"same origin", map[string]string{"Origin": "https://example.com"}, nil},
+		{"trusted origin", map[string]string{"Origin": "https://admin.example.com"}, nil},
+		{"cross origin", map[string]string{"Origin": "https://evil.com"}, ErrBadOrigin},
+		{"null origin", map[string]string{"Origin": "null"}, ErrBadOrigin},
+		{"same referer", map[string]string{"Referer": "https://example.com/form"}, nil},
+		{"cross referer", map[string]string{"Referer": "https://evil.com/form"}, ErrBadReferer},
+		{"origin over referer", map[string]string{
+			"Origin":  "https://evil.com",
+			"Referer": "https://example.com/form",
+		}, ErrBadOrigin},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "https://example.com/", nil)
+			for key, value := range test.headers {
+				r.Header.Set(key, value)
+			}
+			assert.Equal(t, test.err, checkOrigin(r, trusted))
+		})
+	}
+}
+
+// Test_parseOrigin checks that origins are normalised, and values that aren't origins are
+// rejected.
+func Test_parseOrigin(t *testing.T) {
+	origin, err := parseOrigin("HTTPS://Example.com:8443")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com:8443", origin)
+
+	for _, value := range []string{"example.com", "https://example.com/path", "https://a@b.com"} {
+		_, err := parseOrigin(value)
+		assert.Error(t, err, value)
+	}
+}
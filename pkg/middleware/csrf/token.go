@@ -0,0 +1,64 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// ------------------------------------------------------------------------------------------------
+// TOKENS
+// ------------------------------------------------------------------------------------------------
+
+// tokenLength is the length of a raw token, in bytes.
+const tokenLength = 32
+
+// newToken returns a new random raw token.
+func newToken() []byte {
+	token := make([]byte, tokenLength)
+	_, _ = rand.Read(token)
+	return token
+}
+
+// encodeToken returns the passed raw token as a string, for a cookie or a session.
+func encodeToken(token []byte) string {
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// decodeToken returns the raw token encoded by encodeToken, or nil if the value isn't a token.
+func decodeToken(value string) []byte {
+	token, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(token) != tokenLength {
+		return nil
+	}
+	return token
+}
+
+// maskToken returns the passed raw token XORed with a random pad, prefixed with the pad. Each
+// call returns a different value for the same token, so that compression based attacks such as
+// BREACH can't recover the token from a response.
+func maskToken(token []byte) string {
+	masked := make([]byte, 2*tokenLength)
+	pad := masked[:tokenLength]
+	_, _ = rand.Read(pad)
+	subtle.XORBytes(masked[tokenLength:], token, pad)
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// unmaskToken returns the raw token from a value returned by maskToken, or nil if the value isn't
+// a masked token.
+func unmaskToken(value string) []byte {
+	masked, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(masked) != 2*tokenLength {
+		return nil
+	}
+	token := make([]byte, tokenLength)
+	subtle.XORBytes(token, masked[tokenLength:], masked[:tokenLength])
+	return token
+}
+
+// tokensEqual reports whether the passed raw tokens are equal, in constant time. Missing tokens
+// are never equal.
+func tokensEqual(a, b []byte) bool {
+	return len(a) == tokenLength && subtle.ConstantTimeCompare(a, b) == 1
+}
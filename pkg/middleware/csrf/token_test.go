@@ -0,0 +1,44 @@
+package csrf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// TOKEN TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_maskToken checks that masked tokens differ each time, and unmask to the raw token.
+func Test_maskToken(t *testing.T) {
+	token := newToken()
+	a, b := maskToken(token), maskToken(token)
+	assert.NotEqual(t, a, b)
+	assert.NotContains(t, a, encodeToken(token))
+	assert.True(t, tokensEqual(unmaskToken(a), token))
+	assert.True(t, tokensEqual(unmaskToken(b), token))
+	assert.False(t, tokensEqual(unmaskToken(maskToken(newToken())), token))
+}
+
+// Test_unmaskToken_Invalid checks that values that aren't masked tokens are rejected.
+func Test_unmaskToken_Invalid(t *testing.T) {
+	for _, value := range []string{"", "not base64!", encodeToken(newToken())} {
+		assert.Nil(t, unmaskToken(value), value)
+	}
+}
+
+// Test_decodeToken checks that encoded tokens are decoded, and other values are rejected.
+func Test_decodeToken(t *testing.T) {
+	token := newToken()
+	assert.Equal(t, token, decodeToken(encodeToken(token)))
+	assert.Nil(t, decodeToken("short"))
+	assert.Nil(t, decodeToken("not base64!"))
+}
+
+// Test_tokensEqual checks that missing tokens are never equal.
+func Test_tokensEqual(t *testing.T) {
+	assert.False(t, tokensEqual(nil, nil))
+	assert.False(t, tokensEqual([]byte{}, []byte{}))
+	assert.False(t, tokensEqual(newToken(), nil))
+}
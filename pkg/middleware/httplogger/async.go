@@ -0,0 +1,190 @@
+package httplogger
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ASYNC WRITER
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the AsyncWriter.
+const (
+	DefaultAsyncQueueSize     = 1024
+	DefaultAsyncBufferSize    = 64 * 1024
+	DefaultAsyncFlushInterval = time.Second
+)
+
+// AsyncOptions configures an AsyncWriter.
+type AsyncOptions struct {
+	// QueueSize is the number of writes that can be queued. Defaults to DefaultAsyncQueueSize.
+	QueueSize int
+	// BufferSize is the size in bytes of the buffer in front of the underlying writer. Defaults to
+	// DefaultAsyncBufferSize.
+	BufferSize int
+	// FlushInterval is how often the buffer is flushed while writes are arriving. The buffer is
+	// also flushed whenever the queue is empty. Defaults to DefaultAsyncFlushInterval.
+	FlushInterval time.Duration
+	// Block waits for space in the queue when it is full. By default, writes are dropped instead,
+	// so that a slow writer never holds up a request.
+	Block bool
+	// OnError is called with any error from the underlying writer. Defaults to logging the error
+	// with slog.
+	OnError func(err error)
+}
+
+// AsyncWriter is an io.WriteCloser that queues writes, and writes them to an underlying writer
+// through a buffer in the background, so that slow disks or pipes stay off the request path. It
+// is safe for concurrent use.
+type AsyncWriter struct {
+	options AsyncOptions
+	w       io.Writer
+	queue   chan asyncItem
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// asyncItem is a queued write, or a request to flush if flushed is set.
+type asyncItem struct {
+	p       []byte
+	flushed chan error
+}
+
+// NewAsyncWriter creates, initialises and returns a pointer to a new AsyncWriter, and starts its
+// background worker. Close must be called to flush the queue and stop the worker.
+func NewAsyncWriter(w io.Writer, options AsyncOptions) *AsyncWriter {
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultAsyncQueueSize
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultAsyncBufferSize
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultAsyncFlushInterval
+	}
+	if options.OnError == nil {
+		options.OnError = func(err error) {
+			slog.Error("failed to write access log", "type", "http", "error", err)
+		}
+	}
+
+	a := &AsyncWriter{
+		options: options,
+		w:       w,
+		queue:   make(chan asyncItem, options.QueueSize),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write queues a copy of p to be written. It never returns an error for a dropped write, as the
+// caller cannot do anything about it; use Dropped to monitor them. It returns os.ErrClosed if the
+// writer has been closed.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	item := asyncItem{p: append([]byte(nil), p...)}
+	if a.options.Block {
+		a.queue <- item
+		return len(p), nil
+	}
+	select {
+	case a.queue <- item:
+	default:
+		a.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of writes that have been dropped because the queue was full.
+func (a *AsyncWriter) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// Flush waits for the writes queued before it to be written and flushed to the underlying writer.
+func (a *AsyncWriter) Flush() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return os.ErrClosed
+	}
+	flushed := make(chan error, 1)
+	a.queue <- asyncItem{flushed: flushed}
+	return <-flushed
+}
+
+// Close flushes the queue, stops the background worker, and closes the underlying writer if it is
+// an io.Closer.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return os.ErrClosed
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	<-a.done
+	if closer, ok := a.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// run writes queued items to the buffer until the queue is closed.
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+
+	buf := bufio.NewWriterSize(a.w, a.options.BufferSize)
+	ticker := time.NewTicker(a.options.FlushInterval)
+	defer ticker.Stop()
+
+	flush := func() error {
+		err := buf.Flush()
+		if err != nil {
+			a.options.OnError(err)
+			// A failed bufio.Writer keeps returning the error, so start again with a new one.
+			buf = bufio.NewWriterSize(a.w, a.options.BufferSize)
+		}
+		return err
+	}
+
+	for {
+		select {
+		case item, ok := <-a.queue:
+			if !ok {
+				_ = flush()
+				return
+			}
+			if item.flushed != nil {
+				item.flushed <- flush()
+				continue
+			}
+			if _, err := buf.Write(item.p); err != nil {
+				a.options.OnError(err)
+				buf = bufio.NewWriterSize(a.w, a.options.BufferSize)
+			}
+			if len(a.queue) == 0 {
+				_ = flush()
+			}
+		case <-ticker.C:
+			_ = flush()
+		}
+	}
+}
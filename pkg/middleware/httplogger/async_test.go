@@ -0,0 +1,120 @@
+package httplogger
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ASYNC WRITER TESTS
+// ------------------------------------------------------------------------------------------------
+
+// blockingWriter is a writer that waits for release before each write, and records what it was
+// passed.
+type blockingWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+	closed  bool
+	err     error
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	if w.release != nil {
+		<-w.release
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// Test_AsyncWriter checks that writes are written in order, and that Close flushes the queue and
+// closes the underlying writer.
+func Test_AsyncWriter(t *testing.T) {
+	w := &blockingWriter{}
+	a := NewAsyncWriter(w, AsyncOptions{})
+
+	p := []byte("one\n")
+	_, err := a.Write(p)
+	require.NoError(t, err)
+	// The write is copied, so the caller can reuse its buffer.
+	copy(p, "xxx\n")
+	_, err = a.Write([]byte("two\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, a.Flush())
+	assert.Equal(t, "one\ntwo\n", w.String())
+
+	_, err = a.Write([]byte("three\n"))
+	require.NoError(t, err)
+	require.NoError(t, a.Close())
+	assert.Equal(t, "one\ntwo\nthree\n", w.String())
+	assert.True(t, w.closed)
+
+	_, err = a.Write([]byte("four\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.ErrorIs(t, a.Flush(), os.ErrClosed)
+	assert.ErrorIs(t, a.Close(), os.ErrClosed)
+}
+
+// Test_AsyncWriter_drop checks that writes are dropped rather than blocking when the queue is
+// full.
+func Test_AsyncWriter_drop(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	a := NewAsyncWriter(w, AsyncOptions{QueueSize: 1, BufferSize: 1})
+
+	// The first write is taken by the worker, which blocks, and the second fills the queue.
+	for range 10 {
+		_, err := a.Write([]byte("x"))
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, a.Dropped(), uint64(8))
+
+	close(w.release)
+	require.NoError(t, a.Close())
+	assert.Equal(t, 10-int(a.Dropped()), len(w.String()))
+}
+
+// Test_AsyncWriter_errors checks that errors from the underlying writer are reported.
+func Test_AsyncWriter_errors(t *testing.T) {
+	w := &blockingWriter{err: errors.New("disk full")}
+	var reported []error
+	var mu sync.Mutex
+	a := NewAsyncWriter(w, AsyncOptions{Block: true, OnError: func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}})
+
+	_, err := a.Write([]byte("lost\n"))
+	require.NoError(t, err)
+	_ = a.Flush()
+	require.NoError(t, a.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, reported)
+	assert.ErrorContains(t, reported[0], "disk full")
+}
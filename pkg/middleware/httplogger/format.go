@@ -0,0 +1,275 @@
+package httplogger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// FORMATS
+// ------------------------------------------------------------------------------------------------
+
+// Entry holds the details of a logged request, and is passed to a Formatter.
+type Entry struct {
+	// Time is the time the request started.
+	Time time.Time
+	// Level is the level the request is logged at.
+	Level  slog.Level
+	Status int
+	// IP is the client IP address, as returned by realip.ClientIP.
+	IP     string
+	Method string
+	// Host is the X-Forwarded-Host header if present, otherwise the request host.
+	Host string
+	// Path is the escaped request path, without the query.
+	Path      string
+	Query     string
+	Proto     string
+	Referer   string
+	UserAgent string
+	// Size is the number of bytes written to the response body.
+	Size      int64
+	Duration  time.Duration
+	RequestID string
+	// User is the authenticated user, as recorded with WithUser.
+	User string
+	// Route is the matched route pattern, or an empty string if no route matched.
+	Route          string
+	RequestHeader  http.Header
+	ResponseHeader http.Header
+	// Attrs are the fields as they are logged through slog, with the configured names, duration
+	// unit, extra fields and header groups.
+	Attrs []slog.Attr
+}
+
+// URI returns the escaped request path, with the query if there is one.
+func (e *Entry) URI() string {
+	if e.Query == "" {
+		return e.Path
+	}
+	return e.Path + "?" + e.Query
+}
+
+// Formatter appends a single formatted log line for the passed entry to b, without a trailing
+// newline, and returns the extended buffer.
+type Formatter func(b []byte, e *Entry) []byte
+
+// clfTime is the time layout used by the Common and Combined Log Formats.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// CommonLogFormat formats entries in the Apache Common Log Format.
+//
+//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326
+func CommonLogFormat(b []byte, e *Entry) []byte {
+	b = appendCLFValue(b, e.IP)
+	b = append(b, " - "...)
+	b = appendCLFValue(b, e.User)
+	b = append(b, " ["...)
+	b = e.Time.AppendFormat(b, clfTime)
+	b = append(b, "] \""...)
+	b = appendCLFString(b, e.Method)
+	b = append(b, ' ')
+	b = appendCLFString(b, e.URI())
+	b = append(b, ' ')
+	b = appendCLFString(b, e.Proto)
+	b = append(b, "\" "...)
+	b = strconv.AppendInt(b, int64(e.Status), 10)
+	b = append(b, ' ')
+	if e.Size > 0 {
+		return strconv.AppendInt(b, e.Size, 10)
+	}
+	return append(b, '-')
+}
+
+// CombinedLogFormat formats entries in the Apache Combined Log Format, which is the Common Log
+// Format followed by the quoted referer and user agent.
+func CombinedLogFormat(b []byte, e *Entry) []byte {
+	b = CommonLogFormat(b, e)
+	b = append(b, " \""...)
+	b = appendCLFValue(b, e.Referer)
+	b = append(b, "\" \""...)
+	b = appendCLFValue(b, e.UserAgent)
+	return append(b, '"')
+}
+
+// JSONFormat formats entries as JSON lines, with the same fields as slog.JSONHandler.
+func JSONFormat(b []byte, e *Entry) []byte {
+	return appendSlog(b, e, func(w io.Writer) slog.Handler { return slog.NewJSONHandler(w, nil) })
+}
+
+// LogfmtFormat formats entries as logfmt, with the same fields as slog.TextHandler.
+func LogfmtFormat(b []byte, e *Entry) []byte {
+	return appendSlog(b, e, func(w io.Writer) slog.Handler { return slog.NewTextHandler(w, nil) })
+}
+
+// appendSlog appends the entry as it would be written by the handler returned by newHandler.
+func appendSlog(b []byte, e *Entry, newHandler func(io.Writer) slog.Handler) []byte {
+	buf := bytes.NewBuffer(b)
+	record := slog.NewRecord(e.Time, e.Level, http.StatusText(e.Status), 0)
+	record.AddAttrs(e.Attrs...)
+	_ = newHandler(buf).Handle(context.Background(), record)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// ------------------------------------------------------------------------------------------------
+// TEMPLATES
+// ------------------------------------------------------------------------------------------------
+
+// templateFields are the values that can be used in a template, by name.
+var templateFields = map[string]func(b []byte, e *Entry) []byte{
+	"ip":     func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.IP) },
+	"method": func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.Method) },
+	"host":   func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.Host) },
+	"path":   func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.Path) },
+	"query":  func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.Query) },
+	"uri":    func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.URI()) },
+	"proto":  func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.Proto) },
+	"status": func(b []byte, e *Entry) []byte { return strconv.AppendInt(b, int64(e.Status), 10) },
+	"size":   func(b []byte, e *Entry) []byte { return strconv.AppendInt(b, e.Size, 10) },
+	"referer": func(b []byte, e *Entry) []byte {
+		return appendCLFValue(b, e.Referer)
+	},
+	"ua": func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.UserAgent) },
+	"request_id": func(b []byte, e *Entry) []byte {
+		return appendCLFValue(b, e.RequestID)
+	},
+	"user":  func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.User) },
+	"route": func(b []byte, e *Entry) []byte { return appendCLFValue(b, e.Route) },
+	"level": func(b []byte, e *Entry) []byte { return append(b, e.Level.String()...) },
+	"time":  func(b []byte, e *Entry) []byte { return e.Time.AppendFormat(b, clfTime) },
+	"time_rfc3339": func(b []byte, e *Entry) []byte {
+		return e.Time.AppendFormat(b, time.RFC3339Nano)
+	},
+	"date_utc": func(b []byte, e *Entry) []byte {
+		return e.Time.UTC().AppendFormat(b, time.DateOnly)
+	},
+	"time_utc": func(b []byte, e *Entry) []byte {
+		return e.Time.UTC().AppendFormat(b, time.TimeOnly)
+	},
+	"duration_ms": func(b []byte, e *Entry) []byte {
+		return strconv.AppendInt(b, e.Duration.Milliseconds(), 10)
+	},
+	"duration_us": func(b []byte, e *Entry) []byte {
+		return strconv.AppendInt(b, e.Duration.Microseconds(), 10)
+	},
+	"duration_s": func(b []byte, e *Entry) []byte {
+		return strconv.AppendFloat(b, e.Duration.Seconds(), 'f', 3, 64)
+	},
+}
+
+// Template returns a Formatter that writes entries using the passed template. Values are named
+// in braces, such as "{ip} {method} {uri} {status}", and empty values are written as "-". The
+// available values are ip, method, host, path, query, uri, proto, status, size, referer, ua,
+// request_id, user, route, level, time (in Common Log Format), time_rfc3339, date_utc, time_utc,
+// duration_ms, duration_us and duration_s. Request and response headers are written with
+// {req.Header-Name} and {res.Header-Name}. "{{" writes a literal brace. An error is returned if the
+// template uses an unknown value or has an unclosed brace.
+//
+// W3C extended log lines can be written with a template such as
+//
+//	"{date_utc} {time_utc} {ip} {method} {path} {query} {status} {size} {duration_s}"
+func Template(template string) (Formatter, error) {
+	var parts []func(b []byte, e *Entry) []byte
+	for template != "" {
+		i := strings.IndexByte(template, '{')
+		if i < 0 {
+			parts = append(parts, literal(template))
+			break
+		}
+		if i > 0 {
+			parts = append(parts, literal(template[:i]))
+		}
+		template = template[i+1:]
+		if strings.HasPrefix(template, "{") {
+			parts = append(parts, literal("{"))
+			template = template[1:]
+			continue
+		}
+
+		name, rest, ok := strings.Cut(template, "}")
+		if !ok {
+			return nil, fmt.Errorf("unclosed brace in template")
+		}
+		part, err := templateField(name)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+		template = rest
+	}
+
+	return func(b []byte, e *Entry) []byte {
+		for _, part := range parts {
+			b = part(b, e)
+		}
+		return b
+	}, nil
+}
+
+// MustTemplate is like Template, but panics if the template is invalid.
+func MustTemplate(template string) Formatter {
+	formatter, err := Template(template)
+	if err != nil {
+		panic(err)
+	}
+	return formatter
+}
+
+// literal returns a template part that writes the passed text.
+func literal(text string) func(b []byte, e *Entry) []byte {
+	return func(b []byte, _ *Entry) []byte { return append(b, text...) }
+}
+
+// templateField returns the template part for the passed value name.
+func templateField(name string) (func(b []byte, e *Entry) []byte, error) {
+	if header, ok := strings.CutPrefix(name, "req."); ok && header != "" {
+		return func(b []byte, e *Entry) []byte {
+			return appendCLFValue(b, strings.Join(e.RequestHeader.Values(header), ", "))
+		}, nil
+	}
+	if header, ok := strings.CutPrefix(name, "res."); ok && header != "" {
+		return func(b []byte, e *Entry) []byte {
+			return appendCLFValue(b, strings.Join(e.ResponseHeader.Values(header), ", "))
+		}, nil
+	}
+	if field, ok := templateFields[name]; ok {
+		return field, nil
+	}
+	return nil, fmt.Errorf("unknown template value %q", name)
+}
+
+// ------------------------------------------------------------------------------------------------
+// ESCAPING
+// ------------------------------------------------------------------------------------------------
+
+// appendCLFValue appends the passed value escaped as appendCLFString, or "-" if it is empty.
+func appendCLFValue(b []byte, s string) []byte {
+	if s == "" {
+		return append(b, '-')
+	}
+	return appendCLFString(b, s)
+}
+
+// appendCLFString appends the passed value, escaping quotes, backslashes and non-printable bytes
+// the same way as Apache, so that a request cannot inject fields or lines into the log.
+func appendCLFString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20 || c >= 0x7f:
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
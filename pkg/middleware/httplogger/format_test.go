@@ -0,0 +1,148 @@
+package httplogger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// FORMAT TESTS
+// ------------------------------------------------------------------------------------------------
+
+// newTestEntry returns an entry with every field set.
+func newTestEntry() *Entry {
+	return &Entry{
+		Time:           time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+		Level:          slog.LevelInfo,
+		Status:         http.StatusOK,
+		IP:             "127.0.0.1",
+		Method:         http.MethodGet,
+		Host:           "example.com",
+		Path:           "/apache_pb.gif",
+		Query:          "a=1",
+		Proto:          "HTTP/1.1",
+		Referer:        "http://example.com/start.html",
+		UserAgent:      "Mozilla/4.08",
+		Size:           2326,
+		Duration:       1500 * time.Microsecond,
+		RequestID:      "abc-123",
+		Route:          "/{file}",
+		RequestHeader:  http.Header{"Accept": {"text/html", "image/gif"}},
+		ResponseHeader: http.Header{"Content-Type": {"image/gif"}},
+		Attrs:          []slog.Attr{slog.Int("status", http.StatusOK), slog.String("ip", "127.0.0.1")},
+	}
+}
+
+// Test_CommonLogFormat checks the Common and Combined Log Formats.
+func Test_CommonLogFormat(t *testing.T) {
+	e := newTestEntry()
+	assert.Equal(
+		t,
+		`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.1" 200 2326`,
+		string(CommonLogFormat(nil, e)),
+	)
+	assert.Equal(
+		t,
+		`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.1" 200 2326 `+
+			`"http://example.com/start.html" "Mozilla/4.08"`,
+		string(CombinedLogFormat(nil, e)),
+	)
+
+	e.Size = 0
+	e.Referer = ""
+	e.User = "frank"
+	e.UserAgent = "evil\" \"agent\n127.0.0.1 - - fake"
+	assert.Equal(
+		t,
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif?a=1 HTTP/1.1" 200 - `+
+			`"-" "evil\" \"agent\x0a127.0.0.1 - - fake"`,
+		string(CombinedLogFormat(nil, e)),
+	)
+}
+
+// Test_JSONFormat checks that JSON lines hold the time, level, message and attributes.
+func Test_JSONFormat(t *testing.T) {
+	line := JSONFormat([]byte("prefix "), newTestEntry())
+	assert.NotContains(t, string(line), "\n")
+
+	line, ok := bytes.CutPrefix(line, []byte("prefix "))
+	require.True(t, ok)
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(line, &record))
+	assert.Equal(t, "2000-10-10T13:55:36-07:00", record["time"])
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "OK", record["msg"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, "127.0.0.1", record["ip"])
+}
+
+// Test_LogfmtFormat checks that logfmt lines hold the time, level, message and attributes.
+func Test_LogfmtFormat(t *testing.T) {
+	assert.Equal(
+		t,
+		`time=2000-10-10T13:55:36.000-07:00 level=INFO msg=OK status=200 ip=127.0.0.1`,
+		string(LogfmtFormat(nil, newTestEntry())),
+	)
+}
+
+// Test_Template checks that template values are substituted and escaped.
+func Test_Template(t *testing.T) {
+	format, err := Template(
+		"{date_utc} {time_utc} {ip} {method} {path} {query} {status} {size} {duration_s} " +
+			"{duration_ms} {duration_us} {{literal} {req.accept} {res.Content-Type} {req.X-Missing} " +
+			"{request_id} {route} {level} {host} {uri} {proto} {referer} {ua} [{time}] {time_rfc3339}",
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"2000-10-10 20:55:36 127.0.0.1 GET /apache_pb.gif a=1 200 2326 0.002 1 1500 {literal} "+
+			"text/html, image/gif image/gif - abc-123 /{file} INFO example.com /apache_pb.gif?a=1 "+
+			"HTTP/1.1 http://example.com/start.html Mozilla/4.08 [10/Oct/2000:13:55:36 -0700] "+
+			"2000-10-10T13:55:36-07:00",
+		string(format(nil, newTestEntry())),
+	)
+
+	e := newTestEntry()
+	e.Query = ""
+	e.Path = "/a\"b"
+	assert.Equal(t, `/a\"b -`, string(MustTemplate("{path} {query}")(nil, e)))
+
+	_, err = Template("{ip} {nope}")
+	assert.ErrorContains(t, err, `unknown template value "nope"`)
+	_, err = Template("{ip")
+	assert.ErrorContains(t, err, "unclosed brace")
+	_, err = Template("{req.}")
+	assert.Error(t, err)
+	assert.Panics(t, func() { MustTemplate("{nope}") })
+}
+
+// Test_Middleware_format checks that formatted lines are written to the configured writer.
+func Test_Middleware_format(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := Middleware(Options{Format: MustTemplate("{method} {route} {status} {size}"), Writer: buf})(
+		http.HandlerFunc(createTestHandlerFunc(http.StatusCreated, "created")),
+	)
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Pattern = "POST /users"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "POST /users 201 7\nGET - 201 7\n", buf.String())
+
+	// A writer without a format writes JSON lines.
+	buf.Reset()
+	Middleware(Options{Writer: buf})(
+		http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "ok")),
+	).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "http", record["type"])
+	assert.Equal(t, "/", record["path"])
+}
@@ -2,11 +2,16 @@ package httplogger
 
 import (
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/felixge/httpsnoop"
 	"github.com/grokify/mogo/log/sanitize"
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/realip"
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/requestid"
 )
 
 // SanitizedString wraps a string that has been sanitized to prevent log injection.
@@ -17,98 +22,151 @@ func (s SanitizedString) LogValue() slog.Value {
 	return slog.StringValue(string(s))
 }
 
-func Middleware() func(http.Handler) http.Handler {
+// Middleware creates and returns a middleware function that logs each request with slog, or
+// writes it as a formatted line if a Format is configured. It accepts an optional Options, and
+// without one logs every request through slog.Default().
+func Middleware(options ...Options) func(http.Handler) http.Handler {
+	var o Options
+	if len(options) > 0 {
+		o = options[0]
+	}
+	format := o.formatter()
+	writer := o.writer()
+	var mu sync.Mutex
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// NOTE: CaptureMetrics triggers next.ServeHTTP(w, r) for you, so do not run it manually as well.
+			start := time.Now()
+			r = withUserRecord(r)
+			r = r.WithContext(requestid.WithRecord(r.Context()))
 			m := httpsnoop.CaptureMetrics(next, w, r)
-			durationMs := m.Duration.Milliseconds()
 			code := m.Code
-			written := m.Written
 
-			ip := realIp(r)
-			host := r.Header.Get("X-Forwarded-Host")
-			if host == "" {
-				host = r.Host
+			if o.SampleRate > 0 && o.SampleRate < 1 && code < http.StatusBadRequest &&
+				rand.Float64() >= o.SampleRate {
+				return
 			}
 
-			url := r.URL
-			path := url.EscapedPath()
-			if url.RawQuery != "" {
-				path = path + "?" + url.RawQuery
+			ctx := r.Context()
+			logger := o.logger()
+			level := o.level(code)
+			if format == nil && !logger.Enabled(ctx, level) {
+				return
 			}
-			agent := r.UserAgent()
-			referer := r.Referer()
-			proto := r.Proto
-			logType := "http"
-
-			// #nosec G706 - values are sanitized using github.com/grokify/mogo/log/sanitize
-			sanitizedPath := SanitizedString(sanitize.String(path))
-			sanitizedReferer := SanitizedString(sanitize.String(referer))
-			sanitizedAgent := SanitizedString(sanitize.String(agent))
-			sanitizedHost := SanitizedString(sanitize.String(host))
-			sanitizedProto := SanitizedString(sanitize.String(proto))
-
-			if code >= http.StatusBadRequest {
+
+			entry := newEntry(r, w, m, start, level)
+			entry.Attrs = o.attrs(r, w, entry)
+			if format == nil {
 				// #nosec G706 - values are sanitized using github.com/grokify/mogo/log/sanitize
-				slog.Error(
-					http.StatusText(code),
-					"type", logType,
-					"status", code,
-					"ip", ip,
-					"method", r.Method,
-					"host", sanitizedHost,
-					"path", sanitizedPath,
-					"referer", sanitizedReferer,
-					"ua", sanitizedAgent,
-					"proto", sanitizedProto,
-					"size", written,
-					"duration", durationMs,
-				)
+				logger.LogAttrs(ctx, level, http.StatusText(code), entry.Attrs...)
 				return
 			}
 
-			// #nosec G706 - values are sanitized using github.com/grokify/mogo/log/sanitize
-			slog.Info(
-				http.StatusText(m.Code),
-				"type", logType,
-				"status", code,
-				"ip", ip,
-				"method", r.Method,
-				"host", sanitizedHost,
-				"path", sanitizedPath,
-				"referer", sanitizedReferer,
-				"ua", sanitizedAgent,
-				"proto", sanitizedProto,
-				"size", written,
-				"duration", durationMs,
-			)
+			line := append(format(make([]byte, 0, 256), entry), '\n')
+			mu.Lock()
+			_, _ = writer.Write(line)
+			mu.Unlock()
 		})
 	}
 }
 
-// Request.RemoteAddress contains the port, which is not desired.
-func removePort(ra string) string {
-	index := strings.LastIndex(ra, ":")
-	if index == -1 {
-		return ra
+// newEntry returns the entry for the passed request, response and metrics.
+func newEntry(
+	r *http.Request,
+	w http.ResponseWriter,
+	m httpsnoop.Metrics,
+	start time.Time,
+	level slog.Level,
+) *Entry {
+	host := r.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		host = r.Host
+	}
+
+	route := r.Pattern
+	if _, path, ok := strings.Cut(route, " "); ok {
+		route = path
+	}
+
+	return &Entry{
+		Time:           start,
+		Level:          level,
+		Status:         m.Code,
+		IP:             realip.ClientIP(r),
+		Method:         r.Method,
+		Host:           host,
+		Path:           r.URL.EscapedPath(),
+		Query:          r.URL.RawQuery,
+		Proto:          r.Proto,
+		Referer:        r.Referer(),
+		UserAgent:      r.UserAgent(),
+		Size:           m.Written,
+		Duration:       m.Duration,
+		RequestID:      requestid.FromContext(r.Context()),
+		User:           userFromContext(r.Context()),
+		Route:          route,
+		RequestHeader:  r.Header,
+		ResponseHeader: w.Header(),
 	}
-	return ra[:index]
 }
 
-// requestGetRemoteAddress returns the ip address of the client making the request, while taking
-// http proxies into account.
-func realIp(r *http.Request) string {
-	header := r.Header
-	xRealIP := header.Get("X-Real-Ip")
-	xForwardedFor := header.Get("X-Forwarded-For")
-	if xRealIP == "" && xForwardedFor == "" {
-		return removePort(r.RemoteAddr)
+// attrs returns the slog attributes for the passed entry, with the configured names, duration
+// unit, extra fields and header groups.
+func (o *Options) attrs(r *http.Request, w http.ResponseWriter, e *Entry) []slog.Attr {
+	// #nosec G706 - values are sanitized using github.com/grokify/mogo/log/sanitize
+	attrs := []slog.Attr{
+		slog.String(o.name("type"), "http"),
+		slog.Int(o.name("status"), e.Status),
+		slog.String(o.name("ip"), e.IP),
+		slog.String(o.name("method"), e.Method),
+		slog.Any(o.name("host"), SanitizedString(sanitize.String(e.Host))),
+		slog.Any(o.name("path"), SanitizedString(sanitize.String(e.URI()))),
+		slog.Any(o.name("referer"), SanitizedString(sanitize.String(e.Referer))),
+		slog.Any(o.name("ua"), SanitizedString(sanitize.String(e.UserAgent))),
+		slog.Any(o.name("proto"), SanitizedString(sanitize.String(e.Proto))),
+		slog.Int64(o.name("size"), e.Size),
+		slog.Any(o.name("duration"), o.duration(e.Duration)),
+	}
+	if e.RequestID != "" {
+		attrs = append(attrs,
+			slog.Any(o.name("request_id"), SanitizedString(sanitize.String(e.RequestID))))
+	}
+	if e.User != "" {
+		attrs = append(attrs, slog.Any(o.name("user"), SanitizedString(sanitize.String(e.User))))
 	}
-	if xForwardedFor != "" {
-		if first, _, ok := strings.Cut(xForwardedFor, ","); ok {
-			return strings.TrimSpace(first)
+
+	for _, field := range o.Fields {
+		if attr := field(r); attr.Key != "" {
+			attrs = append(attrs, attr)
 		}
 	}
-	return xRealIP
+	if group, ok := headerGroup("request_headers", r.Header, o.RequestHeaders); ok {
+		attrs = append(attrs, group)
+	}
+	if group, ok := headerGroup("response_headers", w.Header(), o.ResponseHeaders); ok {
+		attrs = append(attrs, group)
+	}
+	return attrs
+}
+
+// headerGroup returns the allowed headers that are present as a group attribute, with multiple
+// values joined by commas.
+func headerGroup(name string, header http.Header, allowed []string) (slog.Attr, bool) {
+	attrs := make([]any, 0, len(allowed))
+	for _, key := range allowed {
+		if values := header.Values(key); len(values) > 0 {
+			value := SanitizedString(sanitize.String(strings.Join(values, ", ")))
+			attrs = append(attrs, slog.Any(http.CanonicalHeaderKey(key), value))
+		}
+	}
+	if len(attrs) == 0 {
+		return slog.Attr{}, false
+	}
+	return slog.Group(name, attrs...), true
 }
@@ -7,9 +7,13 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/realip"
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/requestid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -98,3 +102,166 @@ func Test_HTTPLogger(t *testing.T) {
 		})
 	}
 }
+
+// Test_HTTPLogger_request_id checks that the request ID is included in the log record, whichever
+// order the middleware runs in, and whichever header the ID is read from.
+func Test_HTTPLogger_request_id(t *testing.T) {
+	handler := http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "ok"))
+	custom := requestid.Options{Header: "X-Correlation-ID"}
+	tests := []struct {
+		name    string
+		header  string
+		handler http.Handler
+	}{
+		{
+			"request ID middleware runs first",
+			requestid.DefaultHeader,
+			requestid.Middleware(requestid.Options{})(Middleware()(handler)),
+		},
+		{
+			"request ID middleware runs second",
+			requestid.DefaultHeader,
+			Middleware()(requestid.Middleware(requestid.Options{})(handler)),
+		},
+		{
+			"request ID middleware with a custom header runs first",
+			custom.Header,
+			requestid.Middleware(custom)(Middleware()(handler)),
+		},
+		{
+			"request ID middleware with a custom header runs second",
+			custom.Header,
+			Middleware()(requestid.Middleware(custom)(handler)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set(test.header, "abc-123")
+			test.handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			log := map[string]any{}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &log))
+			assert.Equal(t, "abc-123", log["request_id"])
+		})
+	}
+
+	out.Reset()
+	Middleware()(handler).ServeHTTP(
+		httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/test", nil),
+	)
+	log := map[string]any{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	assert.NotContains(t, log, "request_id")
+}
+
+// logRecord runs the passed handler with the middleware, configured with the passed options and
+// a JSON logger, and returns the decoded record, or nil if nothing was logged.
+func logRecord(
+	t *testing.T,
+	options Options,
+	handler http.HandlerFunc,
+	req *http.Request,
+) map[string]any {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	options.Logger = slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	Middleware(options)(handler).ServeHTTP(httptest.NewRecorder(), req)
+	if buf.Len() == 0 {
+		return nil
+	}
+	record := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	return record
+}
+
+// Test_HTTPLogger_options checks that the configured logger, levels, fields, headers and field
+// names are used.
+func Test_HTTPLogger_options(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNotFound)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/users/1?page=2", nil)
+	req.Pattern = "GET /users/{id}"
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+
+	record := logRecord(t, Options{
+		Levels: map[int]slog.Level{4: slog.LevelWarn},
+		Fields: []func(r *http.Request) slog.Attr{
+			RouteField,
+			func(r *http.Request) slog.Attr { return slog.String("user_id", "42") },
+			func(r *http.Request) slog.Attr { return slog.Attr{} },
+		},
+		RequestHeaders:  []string{"accept", "X-Missing"},
+		ResponseHeaders: []string{"Cache-Control"},
+		FieldNames:      map[string]string{"ua": "user_agent", "duration": "duration_us"},
+		DurationUnit:    time.Microsecond,
+	}, handler, req)
+
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "Not Found", record["msg"])
+	assert.Equal(t, float64(http.StatusNotFound), record["status"])
+	assert.Equal(t, "/users/1?page=2", record["path"])
+	assert.Equal(t, "test-agent", record["user_agent"])
+	assert.NotContains(t, record, "ua")
+	assert.Contains(t, record, "duration_us")
+	assert.Equal(t, "GET /users/{id}", record["route"])
+	assert.Equal(t, "42", record["user_id"])
+	assert.Equal(
+		t,
+		map[string]any{"Accept": "text/html, application/json"},
+		record["request_headers"],
+	)
+	assert.Equal(t, map[string]any{"Cache-Control": "no-store"}, record["response_headers"])
+	assert.NotContains(t, fmt.Sprint(record), "secret")
+}
+
+// Test_HTTPLogger_skip_and_sampling checks that skipped requests are not logged, and that only
+// successful responses are sampled.
+func Test_HTTPLogger_skip_and_sampling(t *testing.T) {
+	ok := http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "ok"))
+	failed := http.HandlerFunc(createTestHandlerFunc(http.StatusInternalServerError, "failed"))
+	newReq := func(path string) *http.Request {
+		return httptest.NewRequest(http.MethodGet, path, nil)
+	}
+
+	assert.Nil(t, logRecord(t, Options{SkipPaths: []string{"/healthz"}}, ok, newReq("/healthz")))
+	assert.NotNil(t, logRecord(t, Options{SkipPaths: []string{"/healthz"}}, ok, newReq("/users")))
+
+	sampled := Options{SampleRate: 0.000001}
+	logged := 0
+	for range 20 {
+		if logRecord(t, sampled, ok, newReq("/users")) != nil {
+			logged++
+		}
+	}
+	assert.Less(t, logged, 20)
+	assert.NotNil(t, logRecord(t, sampled, failed, newReq("/users")))
+
+	// Records below the logger's level are not built at all.
+	quiet := Options{Levels: map[int]slog.Level{2: slog.LevelDebug - 1}}
+	assert.Nil(t, logRecord(t, quiet, ok, newReq("/users")))
+}
+
+// Test_HTTPLogger_ip checks that proxy headers are only used for the logged IP address when the
+// realip middleware has resolved it.
+func Test_HTTPLogger_ip(t *testing.T) {
+	ok := http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "ok"))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "6.6.6.6")
+
+	record := logRecord(t, Options{}, ok, req)
+	assert.Equal(t, "10.0.0.1", record["ip"])
+
+	resolved := realip.NewContext(req.Context(), netip.MustParseAddr("198.51.100.7"))
+	record = logRecord(t, Options{}, ok, req.WithContext(resolved))
+	assert.Equal(t, "198.51.100.7", record["ip"])
+}
@@ -0,0 +1,138 @@
+package httplogger
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// OPTIONS
+// ------------------------------------------------------------------------------------------------
+
+// Options configures the HTTP logger. The zero value logs every request through slog.Default(),
+// at Info level for 1xx-3xx responses and Error level for 4xx and 5xx responses.
+type Options struct {
+	// Logger is the logger that records are written to. Defaults to slog.Default(), looked up for
+	// each request, so that later changes to the default logger are respected.
+	Logger *slog.Logger
+	// Levels maps a status class (1 for 1xx through to 5 for 5xx) to the level that responses in
+	// that class are logged at. Classes that are not set use the default levels.
+	Levels map[int]slog.Level
+	// SkipPaths lists request paths that are not logged, such as health checks. Each entry is
+	// matched with path.Match, so "/static/*" skips every file in /static.
+	SkipPaths []string
+	// Skip is called for each request, and the request is not logged if it returns true.
+	Skip func(r *http.Request) bool
+	// SampleRate is the fraction of successful (1xx-3xx) responses that are logged, between 0 and
+	// 1. Zero logs every response. 4xx and 5xx responses are always logged.
+	SampleRate float64
+	// Fields are called after each request to add extra fields to the record, such as the route
+	// pattern or a user ID. Attributes with an empty key are ignored.
+	Fields []func(r *http.Request) slog.Attr
+	// RequestHeaders lists the request headers that are logged, grouped under "request_headers".
+	RequestHeaders []string
+	// ResponseHeaders lists the response headers that are logged, grouped under
+	// "response_headers".
+	ResponseHeaders []string
+	// FieldNames renames the default fields, such as {"ua": "user_agent"}. The default fields are
+	// type, status, ip, method, host, path, referer, ua, proto, size, duration and request_id.
+	FieldNames map[string]string
+	// DurationUnit is the unit that the duration is logged in. Durations are logged as whole
+	// numbers of units smaller than a second, and as fractional seconds otherwise. Defaults to
+	// time.Millisecond.
+	DurationUnit time.Duration
+	// Format writes each record as a line to Writer, instead of logging it through Logger. Use
+	// CommonLogFormat, CombinedLogFormat, JSONFormat, LogfmtFormat or a Template.
+	Format Formatter
+	// Writer receives the formatted lines, one Write per line. Defaults to os.Stdout. Wrap it in an
+	// AsyncWriter to keep slow writes off the request path. If Writer is set without a Format,
+	// JSONFormat is used.
+	Writer io.Writer
+}
+
+// defaultLevels are the levels used for status classes without a configured level.
+var defaultLevels = map[int]slog.Level{
+	1: slog.LevelInfo,
+	2: slog.LevelInfo,
+	3: slog.LevelInfo,
+	4: slog.LevelError,
+	5: slog.LevelError,
+}
+
+// RouteField is a field function that adds the matched route pattern as "route".
+func RouteField(r *http.Request) slog.Attr {
+	if r.Pattern == "" {
+		return slog.Attr{}
+	}
+	return slog.Any("route", SanitizedString(r.Pattern))
+}
+
+// logger returns the configured logger, or the default logger.
+func (o *Options) logger() *slog.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return slog.Default()
+}
+
+// formatter returns the configured formatter, or nil if records should be logged through slog.
+func (o *Options) formatter() Formatter {
+	if o.Format == nil && o.Writer != nil {
+		return JSONFormat
+	}
+	return o.Format
+}
+
+// writer returns the configured writer, or os.Stdout.
+func (o *Options) writer() io.Writer {
+	if o.Writer != nil {
+		return o.Writer
+	}
+	return os.Stdout
+}
+
+// level returns the level for the passed status code.
+func (o *Options) level(code int) slog.Level {
+	class := code / 100
+	if level, ok := o.Levels[class]; ok {
+		return level
+	}
+	if level, ok := defaultLevels[class]; ok {
+		return level
+	}
+	return slog.LevelError
+}
+
+// skip reports whether the passed request should not be logged at all.
+func (o *Options) skip(r *http.Request) bool {
+	for _, pattern := range o.SkipPaths {
+		if matched, _ := path.Match(pattern, r.URL.Path); matched {
+			return true
+		}
+	}
+	return o.Skip != nil && o.Skip(r)
+}
+
+// name returns the configured name for the passed default field name.
+func (o *Options) name(field string) string {
+	if name, ok := o.FieldNames[field]; ok && name != "" {
+		return name
+	}
+	return field
+}
+
+// duration returns the passed duration in the configured unit.
+func (o *Options) duration(d time.Duration) any {
+	unit := o.DurationUnit
+	if unit <= 0 {
+		unit = time.Millisecond
+	}
+	if unit >= time.Second {
+		return d.Seconds() / unit.Seconds()
+	}
+	return int64(d / unit)
+}
@@ -0,0 +1,83 @@
+package httplogger
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// OPTIONS TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_Options_level checks that configured levels override the defaults per status class.
+func Test_Options_level(t *testing.T) {
+	o := Options{Levels: map[int]slog.Level{2: slog.LevelDebug, 4: slog.LevelWarn}}
+
+	assert.Equal(t, slog.LevelInfo, o.level(http.StatusSwitchingProtocols))
+	assert.Equal(t, slog.LevelDebug, o.level(http.StatusOK))
+	assert.Equal(t, slog.LevelInfo, o.level(http.StatusFound))
+	assert.Equal(t, slog.LevelWarn, o.level(http.StatusNotFound))
+	assert.Equal(t, slog.LevelError, o.level(http.StatusInternalServerError))
+	assert.Equal(t, slog.LevelError, o.level(999))
+}
+
+// Test_Options_skip checks that requests are skipped by path pattern or predicate.
+func Test_Options_skip(t *testing.T) {
+	o := Options{
+		SkipPaths: []string{"/healthz", "/static/*"},
+		Skip: func(r *http.Request) bool {
+			return r.Header.Get("X-Synthetic") != ""
+		},
+	}
+
+	tests := []struct {
+		path     string
+		header   string
+		expected bool
+	}{
+		{"/healthz", "", true},
+		{"/static/app.js", "", true},
+		{"/static/js/app.js", "", false},
+		{"/users", "", false},
+		{"/users", "probe", true},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.header != "" {
+			req.Header.Set("X-Synthetic", test.header)
+		}
+		assert.Equal(t, test.expected, o.skip(req), test.path)
+	}
+}
+
+// Test_Options_duration checks the conversion of durations to the configured unit.
+func Test_Options_duration(t *testing.T) {
+	d := 1500 * time.Millisecond
+	assert.Equal(t, int64(1500), (&Options{}).duration(d))
+	assert.Equal(t, int64(1500000), (&Options{DurationUnit: time.Microsecond}).duration(d))
+	assert.Equal(t, 1.5, (&Options{DurationUnit: time.Second}).duration(d))
+}
+
+// Test_Options_name checks that default fields can be renamed.
+func Test_Options_name(t *testing.T) {
+	o := Options{FieldNames: map[string]string{"ua": "user_agent", "ip": ""}}
+	assert.Equal(t, "user_agent", o.name("ua"))
+	assert.Equal(t, "ip", o.name("ip"))
+	assert.Equal(t, "status", o.name("status"))
+}
+
+// Test_RouteField checks that the route pattern is only added when there is one.
+func Test_RouteField(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	assert.Equal(t, "", RouteField(req).Key)
+
+	req.Pattern = "GET /users/{id}"
+	attr := RouteField(req)
+	assert.Equal(t, "route", attr.Key)
+	assert.Equal(t, "GET /users/{id}", attr.Value.Resolve().String())
+}
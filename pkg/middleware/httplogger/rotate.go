@@ -0,0 +1,321 @@
+package httplogger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ROTATING FILE
+// ------------------------------------------------------------------------------------------------
+
+// backupTime is the time layout used in the names of rotated files.
+const backupTime = "2006-01-02T15-04-05.000000000"
+
+// RotatingFileOptions configures a RotatingFile.
+type RotatingFileOptions struct {
+	// Filename is the path of the file that is written to. Rotated files are kept in the same
+	// directory, named with the UTC time they were rotated, such as
+	// access-2026-01-02T15-04-05.000000000.log for access.log.
+	Filename string
+	// MaxSize is the size in bytes that the file can grow to before it is rotated. Zero disables
+	// size based rotation.
+	MaxSize int64
+	// Interval rotates the file when the current interval ends, counted from the zero time in UTC,
+	// so 24 * time.Hour rotates at midnight UTC. Zero disables time based rotation.
+	Interval time.Duration
+	// MaxBackups is the number of rotated files that are kept. Zero keeps them all.
+	MaxBackups int
+	// MaxAge is how long rotated files are kept for. Zero keeps them forever.
+	MaxAge time.Duration
+	// Compress gzips rotated files in the background.
+	Compress bool
+	// FileMode is the mode that new files are created with. Defaults to 0640.
+	FileMode fs.FileMode
+	// OnError is called with any error that happens while compressing or removing rotated files.
+	// Defaults to logging the error with slog.
+	OnError func(err error)
+}
+
+// RotatingFile is an io.WriteCloser that writes to a file, and rotates it by size or time. It is
+// safe for concurrent use.
+type RotatingFile struct {
+	options RotatingFileOptions
+	now     func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+
+	// mill serialises the compression and removal of rotated files, which runs in the background.
+	mill sync.Mutex
+	wg   sync.WaitGroup
+}
+
+// NewRotatingFile creates, initialises and returns a pointer to a new RotatingFile. The file is
+// opened for appending, and created along with its directory if it does not exist.
+func NewRotatingFile(options RotatingFileOptions) (*RotatingFile, error) {
+	if options.Filename == "" {
+		return nil, errors.New("rotating file requires a filename")
+	}
+	options.Filename = filepath.Clean(options.Filename)
+	if options.FileMode == 0 {
+		options.FileMode = 0o640
+	}
+	if options.OnError == nil {
+		options.OnError = func(err error) {
+			slog.Error("failed to process rotated log file", "type", "http", "error", err)
+		}
+	}
+
+	f := &RotatingFile{options: options, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write writes p to the file, rotating it first if it is due.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it with the current time, and opens a new file.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes the file, and waits for any rotated files to be compressed and removed.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+	return err
+}
+
+// due reports whether the file should be rotated before n bytes are written to it.
+func (f *RotatingFile) due(n int64) bool {
+	if f.options.MaxSize > 0 && f.size > 0 && f.size+n > f.options.MaxSize {
+		return true
+	}
+	if f.options.Interval <= 0 {
+		return false
+	}
+	period := f.now().Truncate(f.options.Interval)
+	if !period.After(f.period) {
+		return false
+	}
+	// An empty file is carried over into the new interval, rather than rotated.
+	if f.size == 0 {
+		f.period = period
+		return false
+	}
+	return true
+}
+
+// open opens the file for appending, creating it and its directory if needed.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.options.Filename), 0o750); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(
+		f.options.Filename,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		f.options.FileMode,
+	)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	// An existing file belongs to the interval it was last written in, so that a file left over
+	// from an earlier interval is rotated on the first write.
+	f.period = f.now()
+	if f.size > 0 {
+		f.period = info.ModTime()
+	}
+	if f.options.Interval > 0 {
+		f.period = f.period.Truncate(f.options.Interval)
+	}
+	return nil
+}
+
+// rotate renames the current file to a backup, opens a new file, and starts processing the
+// backups in the background. It must be called with the lock held.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	backup := f.backupName(f.now())
+	err := os.Rename(f.options.Filename, backup)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// Keep writing to the current file, rather than losing every later record.
+		return errors.Join(err, f.open())
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Go(func() { f.millRun(backup) })
+	return nil
+}
+
+// backupName returns the name of a file rotated at the passed time.
+func (f *RotatingFile) backupName(t time.Time) string {
+	prefix, ext := f.backupParts()
+	return prefix + t.UTC().Format(backupTime) + ext
+}
+
+// backupParts returns the prefix and extension that rotated file names are made from.
+func (f *RotatingFile) backupParts() (string, string) {
+	ext := filepath.Ext(f.options.Filename)
+	return strings.TrimSuffix(f.options.Filename, ext) + "-", ext
+}
+
+// millRun compresses the passed backup if configured, and removes old backups.
+func (f *RotatingFile) millRun(backup string) {
+	f.mill.Lock()
+	defer f.mill.Unlock()
+
+	if f.options.Compress {
+		// The backup may already have been removed by a later rotation.
+		if err := compress(backup); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			f.options.OnError(err)
+		}
+	}
+	if err := f.removeOld(); err != nil {
+		f.options.OnError(err)
+	}
+}
+
+// removeOld removes the backups beyond MaxBackups, and those older than MaxAge.
+func (f *RotatingFile) removeOld() error {
+	if f.options.MaxBackups <= 0 && f.options.MaxAge <= 0 {
+		return nil
+	}
+
+	type backup struct {
+		path    string
+		rotated time.Time
+	}
+	prefix, ext := f.backupParts()
+	entries, err := os.ReadDir(filepath.Dir(f.options.Filename))
+	if err != nil {
+		return err
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		path := filepath.Join(filepath.Dir(f.options.Filename), entry.Name())
+		stamp, ok := strings.CutPrefix(path, prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stamp = strings.TrimSuffix(stamp, ".gz")
+		stamp, ok = strings.CutSuffix(stamp, ext)
+		if !ok {
+			continue
+		}
+		rotated, err := time.Parse(backupTime, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path, rotated})
+	}
+	slices.SortFunc(backups, func(a, b backup) int { return b.rotated.Compare(a.rotated) })
+
+	var errs []error
+	cutoff := f.now().Add(-f.options.MaxAge)
+	for i, b := range backups {
+		if (f.options.MaxBackups > 0 && i >= f.options.MaxBackups) ||
+			(f.options.MaxAge > 0 && b.rotated.Before(cutoff)) {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compress gzips the passed file to a file with the same name and a .gz extension, and removes
+// the original.
+func compress(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		_ = dst.Close()
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err = zw.Close(); err != nil {
+		_ = dst.Close()
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
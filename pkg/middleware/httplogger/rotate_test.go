@@ -0,0 +1,159 @@
+package httplogger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ROTATING FILE TESTS
+// ------------------------------------------------------------------------------------------------
+
+// testClock is a controllable clock for rotating files.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestRotatingFile returns a rotating file in a temporary directory, using the passed clock.
+func newTestRotatingFile(
+	t *testing.T,
+	options RotatingFileOptions,
+	clock *testClock,
+) (*RotatingFile, string) {
+	t.Helper()
+	dir := t.TempDir()
+	options.Filename = filepath.Join(dir, "logs", "access.log")
+	options.OnError = func(err error) { t.Errorf("unexpected error: %v", err) }
+	f, err := NewRotatingFile(options)
+	require.NoError(t, err)
+	f.now = clock.Now
+	f.period = clock.Now().Truncate(max(options.Interval, 1))
+	t.Cleanup(func() { _ = f.Close() })
+	return f, filepath.Join(dir, "logs")
+}
+
+// readDir returns the names of the files in the passed directory.
+func readDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Test_RotatingFile_size checks that the file is rotated when it would exceed the maximum size,
+// and that only the configured number of backups are kept.
+func Test_RotatingFile_size(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)}
+	f, dir := newTestRotatingFile(t, RotatingFileOptions{MaxSize: 10, MaxBackups: 2}, clock)
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+		clock.Add(time.Second)
+	}
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, []string{
+		"access-2026-01-02T15-04-07.000000000.log",
+		"access-2026-01-02T15-04-09.000000000.log",
+		"access.log",
+	}, readDir(t, dir))
+
+	content, err := os.ReadFile(filepath.Join(dir, "access-2026-01-02T15-04-09.000000000.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "cccc\ndddd\n", string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "access.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "eeee\nffff\n", string(content))
+
+	_, err = f.Write([]byte("closed"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+// Test_RotatingFile_interval checks that the file is rotated when the interval ends, that empty
+// files are not rotated, and that rotated files are compressed and removed by age.
+func Test_RotatingFile_interval(t *testing.T) {
+	clock := &testClock{now: time.Date(2026, 1, 2, 23, 0, 0, 0, time.UTC)}
+	f, dir := newTestRotatingFile(t, RotatingFileOptions{
+		Interval: 24 * time.Hour,
+		MaxAge:   36 * time.Hour,
+		Compress: true,
+	}, clock)
+
+	write := func(line string) {
+		t.Helper()
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	write("day one\n")
+	clock.Add(2 * time.Hour)
+	write("day two\n")
+	clock.Add(48 * time.Hour)
+	write("day four\n")
+	require.NoError(t, f.Close())
+
+	// The first backup is older than MaxAge by the time of the second rotation.
+	assert.Equal(t, []string{
+		"access-2026-01-05T01-00-00.000000000.log.gz",
+		"access.log",
+	}, readDir(t, dir))
+
+	file, err := os.Open(filepath.Join(dir, "access-2026-01-05T01-00-00.000000000.log.gz"))
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	zr, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "day two\n", string(content))
+}
+
+// Test_RotatingFile_existing checks that an existing file is appended to, and that Rotate can be
+// called directly.
+func Test_RotatingFile_existing(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(filename, []byte("old\n"), 0o600))
+
+	f, err := NewRotatingFile(RotatingFileOptions{Filename: filename, MaxSize: 100})
+	require.NoError(t, err)
+	_, err = f.Write([]byte("new\n"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "old\nnew\n", string(content))
+
+	require.NoError(t, f.Rotate())
+	require.NoError(t, f.Close())
+	assert.Len(t, readDir(t, filepath.Dir(filename)), 2)
+	assert.ErrorIs(t, f.Rotate(), os.ErrClosed)
+
+	_, err = NewRotatingFile(RotatingFileOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package httplogger
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// ------------------------------------------------------------------------------------------------
+// USER
+// ------------------------------------------------------------------------------------------------
+
+// userKey is the context key for the user record.
+type userKey struct{}
+
+// userRecord holds the authenticated user of a request. Authentication usually runs inside the
+// logger, so the logger adds a record to the context that authentication can fill in. It is
+// atomic, as the handler may still be running in another goroutine after a timeout.
+type userRecord struct {
+	user atomic.Pointer[string]
+}
+
+// WithUser records the passed authenticated user for the request with the passed context, so that
+// it is logged as the user field, and in the Common and Combined Log Formats. It is intended for
+// authentication middleware, and returns a context that holds the user, for when the logger runs
+// inside the authentication middleware.
+func WithUser(ctx context.Context, user string) context.Context {
+	if record, ok := ctx.Value(userKey{}).(*userRecord); ok {
+		record.user.Store(&user)
+		return ctx
+	}
+	record := &userRecord{}
+	record.user.Store(&user)
+	return context.WithValue(ctx, userKey{}, record)
+}
+
+// withUserRecord returns the passed request with a user record in its context, unless it already
+// has one.
+func withUserRecord(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(userKey{}).(*userRecord); ok {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), userKey{}, &userRecord{}))
+}
+
+// userFromContext returns the user recorded in the passed context, or an empty string.
+func userFromContext(ctx context.Context) string {
+	if record, ok := ctx.Value(userKey{}).(*userRecord); ok {
+		if user := record.user.Load(); user != nil {
+			return *user
+		}
+	}
+	return ""
+}
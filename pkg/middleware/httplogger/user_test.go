@@ -0,0 +1,38 @@
+package httplogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// USER TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_WithUser checks that a user recorded with WithUser is logged, whether the authentication
+// runs inside or outside the logger.
+func Test_WithUser(t *testing.T) {
+	authenticate := func(next http.HandlerFunc) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next(w, r.WithContext(WithUser(r.Context(), "frank")))
+		})
+	}
+	ok := createTestHandlerFunc(http.StatusOK, "ok")
+
+	// Authentication inside the logger.
+	inside := authenticate(ok).ServeHTTP
+	record := logRecord(t, Options{}, inside, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "frank", record["user"])
+
+	// Authentication outside the logger.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithUser(req.Context(), "frank"))
+	record = logRecord(t, Options{}, ok, req)
+	assert.Equal(t, "frank", record["user"])
+
+	record = logRecord(t, Options{}, ok, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NotContains(t, record, "user")
+}
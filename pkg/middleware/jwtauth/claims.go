@@ -0,0 +1,140 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CLAIMS
+// ------------------------------------------------------------------------------------------------
+
+// Claims holds the registered claims of a verified token, along with its scopes and roles. Other
+// claims can be read with Decode.
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+	// Scopes are read from the space separated scope claim, or the scp claim.
+	Scopes []string
+	// Roles are read from the claim named by Options.RolesClaim.
+	Roles []string
+
+	payload []byte
+}
+
+// HasScope reports whether the token grants the passed scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// HasRole reports whether the token grants the passed role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// Decode unmarshals the token's claims into v, which is usually a pointer to a struct with json
+// tags for the private claims that the application uses.
+func (c *Claims) Decode(v any) error {
+	return json.Unmarshal(c.payload, v)
+}
+
+// claimsKey is the context key for the verified claims.
+type claimsKey struct{}
+
+// NewContext returns a copy of the passed context, holding the passed claims.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the claims of the token that authenticated the request, and whether there
+// was one.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// parseClaims parses the passed token payload, reading the roles from the passed claim.
+func parseClaims(payload []byte, rolesClaim string) (*Claims, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+
+	scopeClaim := "scope"
+	if _, ok := raw[scopeClaim]; !ok {
+		scopeClaim = "scp"
+	}
+
+	c := &Claims{payload: payload}
+	errs := make([]error, 9)
+	c.Issuer, errs[0] = stringClaim(raw, "iss")
+	c.Subject, errs[1] = stringClaim(raw, "sub")
+	c.ID, errs[2] = stringClaim(raw, "jti")
+	c.Audience, errs[3] = listClaim(raw, "aud", false)
+	c.ExpiresAt, errs[4] = timeClaim(raw, "exp")
+	c.NotBefore, errs[5] = timeClaim(raw, "nbf")
+	c.IssuedAt, errs[6] = timeClaim(raw, "iat")
+	c.Scopes, errs[7] = listClaim(raw, scopeClaim, true)
+	c.Roles, errs[8] = listClaim(raw, rolesClaim, false)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// stringClaim returns the named string claim, or an empty string if it isn't set.
+func stringClaim(raw map[string]json.RawMessage, name string) (string, error) {
+	var s string
+	if v, ok := raw[name]; ok && string(v) != "null" {
+		if err := json.Unmarshal(v, &s); err != nil {
+			return "", fmt.Errorf("claim %q must be a string", name)
+		}
+	}
+	return s, nil
+}
+
+// listClaim returns the named claim, which can be a string or an array of strings. Strings are
+// split on spaces if split is true.
+func listClaim(raw map[string]json.RawMessage, name string, split bool) ([]string, error) {
+	v, ok := raw[name]
+	if !ok || string(v) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		if split {
+			return strings.Fields(s), nil
+		}
+		return []string{s}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(v, &list); err != nil {
+		return nil, fmt.Errorf("claim %q must be a string or an array of strings", name)
+	}
+	return list, nil
+}
+
+// timeClaim returns the named NumericDate claim, or the zero time if it isn't set.
+func timeClaim(raw map[string]json.RawMessage, name string) (time.Time, error) {
+	v, ok := raw[name]
+	if !ok || string(v) == "null" {
+		return time.Time{}, nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(v, &seconds); err != nil {
+		return time.Time{}, fmt.Errorf("claim %q must be a number", name)
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), nil
+}
@@ -0,0 +1,68 @@
+package jwtauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CLAIMS TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_parseClaims checks that the registered claims, scopes and roles are parsed from their
+// different JSON forms.
+func Test_parseClaims(t *testing.T) {
+	c, err := parseClaims([]byte(`{
+		"iss": "issuer",
+		"sub": "subject",
+		"jti": "id",
+		"aud": "single",
+		"exp": 1700000000.5,
+		"nbf": 1700000000,
+		"iat": null,
+		"scp": ["a", "b"],
+		"groups": "admin"
+	}`), "groups")
+	require.NoError(t, err)
+	assert.Equal(t, "issuer", c.Issuer)
+	assert.Equal(t, "subject", c.Subject)
+	assert.Equal(t, "id", c.ID)
+	assert.Equal(t, []string{"single"}, c.Audience)
+	assert.Equal(t, time.Unix(1700000000, 500_000_000), c.ExpiresAt)
+	assert.Equal(t, time.Unix(1700000000, 0), c.NotBefore)
+	assert.True(t, c.IssuedAt.IsZero())
+	assert.Equal(t, []string{"a", "b"}, c.Scopes)
+	assert.True(t, c.HasScope("b"))
+	assert.Equal(t, []string{"admin"}, c.Roles)
+	assert.True(t, c.HasRole("admin"))
+
+	c, err = parseClaims([]byte(`{"aud": ["one", "two"], "scope": "a  b", "scp": "c"}`), "roles")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, c.Audience)
+	assert.Equal(t, []string{"a", "b"}, c.Scopes)
+	assert.Nil(t, c.Roles)
+	assert.False(t, c.HasRole("admin"))
+
+	var custom struct {
+		Aud []string `json:"aud"`
+	}
+	require.NoError(t, c.Decode(&custom))
+	assert.Equal(t, []string{"one", "two"}, custom.Aud)
+}
+
+// Test_parseClaims_errors checks that claims with the wrong type are rejected.
+func Test_parseClaims_errors(t *testing.T) {
+	for _, payload := range []string{
+		`[]`,
+		`{"iss": 1}`,
+		`{"aud": [1]}`,
+		`{"exp": "tomorrow"}`,
+		`{"roles": {"admin": true}}`,
+	} {
+		_, err := parseClaims([]byte(payload), "roles")
+		assert.Error(t, err, payload)
+	}
+}
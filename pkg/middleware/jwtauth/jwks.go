@@ -0,0 +1,276 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// JWKS
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the JWKS.
+const (
+	DefaultRefreshInterval    = time.Hour
+	DefaultMinRefreshInterval = time.Minute
+	DefaultFetchTimeout       = 10 * time.Second
+	maxJWKSSize               = 1 << 20
+)
+
+// JWKSOptions configures a JWKS.
+type JWKSOptions struct {
+	// URL is the address of the JWKS document, such as an identity provider's jwks_uri. Either URL
+	// or File must be set.
+	URL string
+	// File is the path of the JWKS document. Either URL or File must be set.
+	File string
+	// RefreshInterval is how long the keys are cached before they are reloaded. Defaults to
+	// DefaultRefreshInterval.
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the minimum time between reloads. Tokens with an unknown kid trigger a
+	// reload, so that rotated keys are picked up straight away, and this limits how often clients
+	// can cause one. Defaults to DefaultMinRefreshInterval.
+	MinRefreshInterval time.Duration
+	// Client fetches the URL. Defaults to a client with a DefaultFetchTimeout timeout.
+	Client *http.Client
+}
+
+// JWKS is a cached JSON Web Key Set, loaded from a URL or file. The keys are loaded on first use,
+// and reloaded by the first request after the refresh interval has passed, while other requests
+// carry on with the cached keys. If a reload fails, the cached keys are used until the next
+// attempt. It is safe for concurrent use.
+type JWKS struct {
+	options JWKSOptions
+	now     func() time.Time
+
+	// load serialises reloads, and guards attempted.
+	load      sync.Mutex
+	attempted time.Time
+
+	mu      sync.RWMutex
+	keys    []Key
+	fetched time.Time
+	err     error
+}
+
+// NewJWKS creates, initialises and returns a pointer to a new JWKS. An error is returned if
+// neither or both of URL and File are set.
+func NewJWKS(options JWKSOptions) (*JWKS, error) {
+	if (options.URL == "") == (options.File == "") {
+		return nil, errors.New("jwks requires either a url or a file")
+	}
+	if options.RefreshInterval <= 0 {
+		options.RefreshInterval = DefaultRefreshInterval
+	}
+	if options.MinRefreshInterval <= 0 {
+		options.MinRefreshInterval = DefaultMinRefreshInterval
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: DefaultFetchTimeout}
+	}
+	return &JWKS{options: options, now: time.Now}, nil
+}
+
+// Keys returns the cached keys, loading them if they haven't been loaded yet, and reloading them
+// if the refresh interval has passed. An error is only returned if the keys have never loaded.
+func (j *JWKS) Keys(ctx context.Context) ([]Key, error) {
+	j.mu.RLock()
+	keys, fetched := j.keys, j.fetched
+	j.mu.RUnlock()
+
+	switch {
+	case fetched.IsZero():
+		return j.reload(ctx, true)
+	case j.now().Sub(fetched) >= j.options.RefreshInterval:
+		return j.reload(ctx, false)
+	}
+	return keys, nil
+}
+
+// Refresh reloads the keys straight away, such as to check that they can be loaded at startup.
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.load.Lock()
+	defer j.load.Unlock()
+	return j.fetchAndStore(ctx)
+}
+
+// reload reloads the keys, unless they were attempted within the minimum refresh interval, and
+// returns the current keys. If wait is false and another reload is in progress, the current keys
+// are returned without waiting for it.
+func (j *JWKS) reload(ctx context.Context, wait bool) ([]Key, error) {
+	if wait {
+		j.load.Lock()
+	} else if !j.load.TryLock() {
+		return j.current()
+	}
+	defer j.load.Unlock()
+
+	if j.attempted.IsZero() || j.now().Sub(j.attempted) >= j.options.MinRefreshInterval {
+		if err := j.fetchAndStore(ctx); err != nil {
+			slog.Error("failed to load jwks", "type", "auth", "error", err)
+		}
+	}
+	return j.current()
+}
+
+// current returns the current keys, or the last error if they have never loaded.
+func (j *JWKS) current() ([]Key, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.fetched.IsZero() {
+		return nil, j.err
+	}
+	return j.keys, nil
+}
+
+// fetchAndStore loads and stores the keys. It must be called with the load lock held.
+func (j *JWKS) fetchAndStore(ctx context.Context) error {
+	j.attempted = j.now()
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.err = err
+	if err == nil {
+		j.keys, j.fetched = keys, j.now()
+	}
+	return err
+}
+
+// fetch reads and parses the JWKS document.
+func (j *JWKS) fetch(ctx context.Context) ([]Key, error) {
+	if j.options.File != "" {
+		data, err := os.ReadFile(j.options.File)
+		if err != nil {
+			return nil, err
+		}
+		return ParseJWKS(data)
+	}
+
+	// The keys are shared by every request, so one client going away shouldn't cancel the load.
+	ctx = context.WithoutCancel(ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.options.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := j.options.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", j.options.URL, res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxJWKSSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// jwk is a JSON Web Key, as defined by RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses the keys in the passed JWKS document. Keys that aren't for signatures, that use
+// an unsupported key type, curve or algorithm, or that are too small, are skipped.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parsing jwks: %w", err)
+	}
+
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("parsing jwks key %q: %w", k.Kid, err)
+		}
+		if key.Algorithm == "" || (k.Alg != "" && k.Alg != key.Algorithm) || key.check() != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// key converts the JWK to a Key. The algorithm is left empty if the key type isn't supported.
+func (k *jwk) key() (Key, error) {
+	key := Key{ID: k.Kid}
+	var err error
+	switch {
+	case k.Kty == "RSA":
+		var n, e []byte
+		if n, err = decodeSegment(k.N); err == nil {
+			e, err = decodeSegment(k.E)
+		}
+		if err == nil && (len(e) == 0 || len(e) > 4) {
+			err = errors.New("invalid rsa exponent")
+		}
+		key.Algorithm = RS256
+		key.Key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	case k.Kty == "EC" && k.Crv == "P-256":
+		var x, y []byte
+		if x, err = decodeSegment(k.X); err == nil {
+			y, err = decodeSegment(k.Y)
+		}
+		if err == nil && (len(x) != 32 || len(y) != 32) {
+			err = errors.New("invalid P-256 coordinates")
+		}
+		if err == nil {
+			// The uncompressed point encoding, which ParseUncompressedPublicKey validates.
+			point := append([]byte{4}, append(x, y...)...)
+			key.Key, err = ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		}
+		key.Algorithm = ES256
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		var x []byte
+		x, err = decodeSegment(k.X)
+		key.Algorithm = EdDSA
+		key.Key = ed25519.PublicKey(x)
+	case k.Kty == "oct":
+		var secret []byte
+		secret, err = decodeSegment(k.K)
+		key.Algorithm = HS256
+		key.Key = secret
+	}
+	return key, err
+}
+
+// decodeSegment decodes unpadded base64url data, as used by JWTs and JWKs.
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
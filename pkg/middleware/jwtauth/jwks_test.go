@@ -0,0 +1,202 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// JWKS TESTS
+// ------------------------------------------------------------------------------------------------
+
+// toJWK returns the JWK for the passed public key.
+func toJWK(kid string, pub any) map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		e := big.NewInt(int64(key.E)).Bytes()
+		return map[string]string{"kty": "RSA", "kid": kid, "n": b64(key.N.Bytes()), "e": b64(e)}
+	case *ecdsa.PublicKey:
+		point, _ := key.Bytes()
+		return map[string]string{
+			"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(point[1:33]), "y": b64(point[33:]),
+		}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64(key)}
+	}
+	return nil
+}
+
+// testJWKS returns a JWKS document holding the passed JWKs.
+func testJWKS(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+// Test_ParseJWKS checks that supported signature keys are parsed, and other keys skipped.
+func Test_ParseJWKS(t *testing.T) {
+	s := signers()
+	enc := toJWK("enc", &s.rsa.PublicKey)
+	enc["use"] = "enc"
+	ps256 := toJWK("ps", &s.rsa.PublicKey)
+	ps256["alg"] = "PS256"
+
+	keys, err := ParseJWKS(testJWKS(t,
+		toJWK("rs", &s.rsa.PublicKey),
+		toJWK("es", &s.ec.PublicKey),
+		toJWK("ed", s.ed.Public()),
+		map[string]string{"kty": "oct", "kid": "hs", "k": "c2VjcmV0"}, // too short
+		map[string]string{"kty": "EC", "kid": "p384", "crv": "P-384"},
+		enc,
+		ps256,
+	))
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	assert.Equal(t, Key{ID: "rs", Algorithm: RS256, Key: &s.rsa.PublicKey}, keys[0])
+	assert.Equal(t, ES256, keys[1].Algorithm)
+	assert.True(t, s.ec.PublicKey.Equal(keys[1].Key))
+	assert.Equal(t, Key{ID: "ed", Algorithm: EdDSA, Key: s.ed.Public()}, keys[2])
+
+	_, err = ParseJWKS([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AA", "y": "AA"}]}`))
+	assert.Error(t, err)
+	_, err = ParseJWKS([]byte(`{"keys": [{"kty": "RSA", "n": "!", "e": "AQAB"}]}`))
+	assert.Error(t, err)
+	_, err = ParseJWKS([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+// testJWKSServer is a JWKS endpoint that serves a document that can be changed, and counts its
+// requests.
+type testJWKSServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	document []byte
+	status   int
+	requests atomic.Int32
+}
+
+func newTestJWKSServer(t *testing.T, document []byte) *testJWKSServer {
+	s := &testJWKSServer{document: document, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.WriteHeader(s.status)
+		_, _ = w.Write(s.document)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testJWKSServer) set(document []byte, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.document, s.status = document, status
+}
+
+// Test_JWKS_url checks that keys from a URL are cached, reloaded after the refresh interval, and
+// kept when a reload fails.
+func Test_JWKS_url(t *testing.T) {
+	s := signers()
+	server := newTestJWKSServer(t, testJWKS(t, toJWK("rs", &s.rsa.PublicKey)))
+	jwks, err := NewJWKS(JWKSOptions{URL: server.URL, RefreshInterval: time.Hour})
+	require.NoError(t, err)
+	now := time.Now()
+	jwks.now = func() time.Time { return now }
+
+	for range 3 {
+		keys, err := jwks.Keys(t.Context())
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+	}
+	assert.Equal(t, int32(1), server.requests.Load())
+
+	// A failed reload keeps the cached keys.
+	server.set([]byte("oops"), http.StatusInternalServerError)
+	now = now.Add(time.Hour)
+	keys, err := jwks.Keys(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, int32(2), server.requests.Load())
+
+	// And the next attempt waits for the minimum refresh interval.
+	_, _ = jwks.Keys(t.Context())
+	assert.Equal(t, int32(2), server.requests.Load())
+
+	server.set(testJWKS(t, toJWK("rs", &s.rsa.PublicKey), toJWK("ed", s.ed.Public())), 200)
+	now = now.Add(time.Minute)
+	keys, err = jwks.Keys(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, int32(3), server.requests.Load())
+}
+
+// Test_JWKS_rotation checks that a token with an unknown kid reloads the keys, at most once per
+// minimum refresh interval.
+func Test_JWKS_rotation(t *testing.T) {
+	s := signers()
+	server := newTestJWKSServer(t, testJWKS(t, toJWK("old", s.ed.Public())))
+	jwks, err := NewJWKS(JWKSOptions{URL: server.URL})
+	require.NoError(t, err)
+	now := time.Now()
+	jwks.now = func() time.Time { return now }
+	a, err := New(Options{JWKS: jwks})
+	require.NoError(t, err)
+
+	token := s.sign(t, map[string]any{"alg": EdDSA, "kid": "new"}, validClaims())
+	_, err = a.Verify(t.Context(), token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, int32(1), server.requests.Load())
+
+	server.set(testJWKS(t, toJWK("new", s.ed.Public())), http.StatusOK)
+	_, err = a.Verify(t.Context(), token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, int32(1), server.requests.Load())
+
+	now = now.Add(DefaultMinRefreshInterval)
+	claims, err := a.Verify(t.Context(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, int32(2), server.requests.Load())
+}
+
+// Test_JWKS_file checks that keys are loaded from a file, and that Refresh reports errors.
+func Test_JWKS_file(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	jwks, err := NewJWKS(JWKSOptions{File: file})
+	require.NoError(t, err)
+
+	_, err = jwks.Keys(t.Context())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorIs(t, jwks.Refresh(t.Context()), os.ErrNotExist)
+
+	require.NoError(t, os.WriteFile(file, testJWKS(t, toJWK("es", &signers().ec.PublicKey)), 0o600))
+	require.NoError(t, jwks.Refresh(t.Context()))
+	keys, err := jwks.Keys(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+// Test_NewJWKS_errors checks that exactly one of URL and File must be set.
+func Test_NewJWKS_errors(t *testing.T) {
+	_, err := NewJWKS(JWKSOptions{})
+	assert.Error(t, err)
+	_, err = NewJWKS(JWKSOptions{URL: "https://example.com", File: "jwks.json"})
+	assert.Error(t, err)
+}
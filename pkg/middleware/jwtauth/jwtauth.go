@@ -0,0 +1,341 @@
+// Package jwtauth provides middleware that authenticates requests with JWT bearer tokens.
+//
+// Tokens are verified with HS256, RS256, ES256 or EdDSA keys, listed in the Options or loaded
+// from a JWKS document. The exp, nbf and iat claims are validated with an allowed clock skew, and
+// the issuer and audience are checked if they are configured. The verified claims are stored in
+// the request context, where handlers read them with FromContext, and where RequireScopes and
+// RequireRoles check them.
+//
+//	jwks, _ := jwtauth.NewJWKS(jwtauth.JWKSOptions{URL: "https://id.example.com/jwks.json"})
+//	auth, err := jwtauth.New(jwtauth.Options{
+//		JWKS:     jwks,
+//		Issuer:   "https://id.example.com/",
+//		Audience: "orders-api",
+//	})
+//	app.Use(auth.Middleware())
+//	app.Group("/admin").Use(jwtauth.RequireRoles("admin"))
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// JWT AUTH
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the middleware.
+const (
+	DefaultRealm      = "api"
+	DefaultRolesClaim = "roles"
+)
+
+// Errors returned by Verify.
+var (
+	ErrMalformedToken    = errors.New("malformed token")
+	ErrInvalidAlgorithm  = errors.New("invalid token algorithm")
+	ErrUnknownKey        = errors.New("unknown token key")
+	ErrInvalidSignature  = errors.New("invalid token signature")
+	ErrTokenExpired      = errors.New("token has expired")
+	ErrTokenNotValidYet  = errors.New("token is not valid yet")
+	ErrTokenIssuedLater  = errors.New("token was issued in the future")
+	ErrInvalidIssuer     = errors.New("invalid token issuer")
+	ErrInvalidAudience   = errors.New("invalid token audience")
+	errKeysNotAvailable  = errors.New("token keys are not available")
+	errMissingExpiration = fmt.Errorf("%w: missing exp claim", ErrMalformedToken)
+)
+
+// supportedAlgorithms are the algorithms that tokens can be signed with.
+var supportedAlgorithms = []string{HS256, RS256, ES256, EdDSA}
+
+// Options configures the JWT middleware.
+type Options struct {
+	// Keys are the keys that verify tokens. At least one of Keys or JWKS must be set.
+	Keys []Key
+	// JWKS provides keys that verify tokens, alongside any Keys.
+	JWKS *JWKS
+	// Issuer is the required iss claim. It isn't checked if it is empty.
+	Issuer string
+	// Audience must be one of the aud claim values. It isn't checked if it is empty.
+	Audience string
+	// ClockSkew is the leeway allowed when checking the exp, nbf and iat claims, to allow for
+	// differences between the clocks of the issuer and this server.
+	ClockSkew time.Duration
+	// RolesClaim is the claim that roles are read from. Defaults to DefaultRolesClaim.
+	RolesClaim string
+	// Cookie is the name of a cookie that tokens are read from, if the request has no
+	// Authorization header.
+	Cookie string
+	// Realm is reported in the WWW-Authenticate header. Defaults to DefaultRealm.
+	Realm string
+}
+
+// Authenticator verifies JWTs and authenticates requests with them.
+type Authenticator struct {
+	options Options
+	now     func() time.Time
+}
+
+// header is the JOSE header of a token.
+type header struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+// New creates, initialises and returns a pointer to a new Authenticator. An error is returned if
+// there are no keys, or if a key doesn't suit its algorithm.
+func New(options Options) (*Authenticator, error) {
+	if len(options.Keys) == 0 && options.JWKS == nil {
+		return nil, errors.New("jwt authenticator requires keys or a jwks")
+	}
+	for i := range options.Keys {
+		if err := options.Keys[i].check(); err != nil {
+			return nil, err
+		}
+	}
+	options.Keys = slices.Clone(options.Keys)
+	if options.RolesClaim == "" {
+		options.RolesClaim = DefaultRolesClaim
+	}
+	if options.Realm == "" {
+		options.Realm = DefaultRealm
+	}
+	return &Authenticator{options: options, now: time.Now}, nil
+}
+
+// Verify verifies the passed compact serialised token, and returns its claims.
+//
+// The token's alg header must be one of the supported algorithms, so none is always rejected,
+// and it must match the algorithm of the key that verifies it. Tokens must have an exp claim.
+func (a *Authenticator) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return nil, err
+	}
+	if len(h.Crit) > 0 {
+		return nil, fmt.Errorf("%w: unsupported critical headers %v", ErrMalformedToken, h.Crit)
+	}
+	if !slices.Contains(supportedAlgorithms, h.Alg) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAlgorithm, h.Alg)
+	}
+	sig, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	keys, err := a.keys(ctx, h, false)
+	if err == nil && len(keys) == 0 && a.options.JWKS != nil && h.Kid != "" {
+		// The issuer may have rotated its keys since they were loaded.
+		keys, err = a.keys(ctx, h, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+
+	input := []byte(token[:len(parts[0])+1+len(parts[1])])
+	verified := slices.ContainsFunc(keys, func(k Key) bool { return k.verify(input, sig) })
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	payload, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	claims, err := parseClaims(payload, a.options.RolesClaim)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+	if err := a.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// keys returns the keys that can verify a token with the passed header. If reload is true, the
+// JWKS is reloaded first.
+func (a *Authenticator) keys(ctx context.Context, h header, reload bool) ([]Key, error) {
+	keys := a.options.Keys
+	if a.options.JWKS != nil {
+		var set []Key
+		var err error
+		if reload {
+			set, err = a.options.JWKS.reload(ctx, true)
+		} else {
+			set, err = a.options.JWKS.Keys(ctx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errKeysNotAvailable, err)
+		}
+		keys = slices.Concat(keys, set)
+	}
+
+	matches := make([]Key, 0, len(keys))
+	for _, k := range keys {
+		if k.Algorithm == h.Alg && (k.ID == "" || h.Kid == "" || k.ID == h.Kid) {
+			matches = append(matches, k)
+		}
+	}
+	return matches, nil
+}
+
+// validate checks the time based claims, issuer and audience of the passed claims.
+func (a *Authenticator) validate(c *Claims) error {
+	now := a.now()
+	skew := a.options.ClockSkew
+	switch {
+	case c.ExpiresAt.IsZero():
+		return errMissingExpiration
+	case !now.Before(c.ExpiresAt.Add(skew)):
+		return ErrTokenExpired
+	case !c.NotBefore.IsZero() && now.Before(c.NotBefore.Add(-skew)):
+		return ErrTokenNotValidYet
+	case !c.IssuedAt.IsZero() && now.Before(c.IssuedAt.Add(-skew)):
+		return ErrTokenIssuedLater
+	case a.options.Issuer != "" && c.Issuer != a.options.Issuer:
+		return ErrInvalidIssuer
+	case a.options.Audience != "" && !slices.Contains(c.Audience, a.options.Audience):
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// decodeJSON decodes a base64url encoded JSON token segment into v.
+func decodeJSON(segment string, v any) error {
+	data, err := decodeSegment(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+	return nil
+}
+
+// Middleware returns a middleware function that rejects requests without a valid bearer token
+// with a 401 response, and stores the token's claims in the request context.
+func (a *Authenticator) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := a.extract(r)
+			if token == "" {
+				a.unauthorized(w, "")
+				return
+			}
+
+			claims, err := a.Verify(r.Context(), token)
+			if errors.Is(err, errKeysNotAvailable) {
+				slog.Error("failed to verify jwt", "type", "auth", "error", err)
+				http.Error(
+					w,
+					http.StatusText(http.StatusInternalServerError),
+					http.StatusInternalServerError,
+				)
+				return
+			}
+			if err != nil {
+				a.unauthorized(w, "invalid_token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+		})
+	}
+}
+
+// extract returns the token from the Authorization header, or from the configured cookie.
+func (a *Authenticator) extract(r *http.Request) string {
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		scheme, token, ok := strings.Cut(authorization, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+	if a.options.Cookie != "" {
+		if cookie, err := r.Cookie(a.options.Cookie); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// unauthorized writes a 401 response with a WWW-Authenticate challenge, including the passed
+// error code if it is set.
+func (a *Authenticator) unauthorized(w http.ResponseWriter, code string) {
+	challenge := fmt.Sprintf("Bearer realm=%q", a.options.Realm)
+	if code != "" {
+		challenge += fmt.Sprintf(", error=%q", code)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// ------------------------------------------------------------------------------------------------
+// GUARDS
+// ------------------------------------------------------------------------------------------------
+
+// RequireScopes creates and returns a middleware function that only allows requests whose token
+// grants every one of the passed scopes. It must run after the JWT middleware. Requests without
+// claims get a 401 response, and requests that lack a scope get a 403 response.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	challenge := fmt.Sprintf(
+		`Bearer error="insufficient_scope", scope=%q`,
+		strings.Join(scopes, " "),
+	)
+	return guard(func(c *Claims) bool {
+		for _, scope := range scopes {
+			if !c.HasScope(scope) {
+				return false
+			}
+		}
+		return true
+	}, challenge)
+}
+
+// RequireRoles creates and returns a middleware function that only allows requests whose token
+// grants at least one of the passed roles. It must run after the JWT middleware. Requests without
+// claims get a 401 response, and requests without a role get a 403 response.
+func RequireRoles(roles ...string) func(http.Handler) http.Handler {
+	return guard(func(c *Claims) bool {
+		return slices.ContainsFunc(roles, c.HasRole)
+	}, "")
+}
+
+// guard creates and returns a middleware function that only allows requests whose claims pass
+// the passed check, setting the passed WWW-Authenticate challenge on 403 responses.
+func guard(check func(*Claims) bool, challenge string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := FromContext(r.Context())
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !check(claims) {
+				if challenge != "" {
+					w.Header().Set("WWW-Authenticate", challenge)
+				}
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// JWT AUTH TESTS
+// ------------------------------------------------------------------------------------------------
+
+// testSigners holds locally generated private keys, keyed by algorithm.
+type testSigners struct {
+	secret []byte
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	ed     ed25519.PrivateKey
+}
+
+// signers returns the test private keys, which are generated once.
+var signers = sync.OnceValue(func() *testSigners {
+	s := &testSigners{secret: []byte(strings.Repeat("s", 32))}
+	var err error
+	if s.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if s.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+	if _, s.ed, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		panic(err)
+	}
+	return s
+})
+
+// keys returns the verification keys for the test private keys, with the algorithm as the ID.
+func (s *testSigners) keys() []Key {
+	return []Key{
+		{ID: HS256, Algorithm: HS256, Key: s.secret},
+		{ID: RS256, Algorithm: RS256, Key: &s.rsa.PublicKey},
+		{ID: ES256, Algorithm: ES256, Key: &s.ec.PublicKey},
+		{ID: EdDSA, Algorithm: EdDSA, Key: s.ed.Public()},
+	}
+}
+
+// sign returns a token with the passed header and claims, signed for the alg header with the
+// matching test private key. An alg of none creates an unsigned token.
+func (s *testSigners) sign(t *testing.T, h map[string]any, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(h) + "." + encode(claims)
+	sum := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	var err error
+	switch h["alg"] {
+	case HS256:
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case RS256:
+		sig, err = rsa.SignPKCS1v15(nil, s.rsa, crypto.SHA256, sum[:])
+	case ES256:
+		var r, ss *big.Int
+		r, ss, err = ecdsa.Sign(rand.Reader, s.ec, sum[:])
+		if err == nil {
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			ss.FillBytes(sig[32:])
+		}
+	case EdDSA:
+		sig = ed25519.Sign(s.ed, []byte(input))
+	}
+	require.NoError(t, err)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// validClaims returns claims that pass validation against newTestAuthenticator.
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   "https://id.example.com/",
+		"sub":   "user-1",
+		"aud":   []string{"orders-api", "billing-api"},
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Add(-time.Minute).Unix(),
+		"iat":   now.Add(-time.Minute).Unix(),
+		"scope": "orders:read orders:write",
+		"roles": []string{"editor"},
+		"org":   "acme",
+	}
+}
+
+// newTestAuthenticator returns an Authenticator with the test keys.
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	a, err := New(Options{
+		Keys:      signers().keys(),
+		Issuer:    "https://id.example.com/",
+		Audience:  "orders-api",
+		ClockSkew: 30 * time.Second,
+	})
+	require.NoError(t, err)
+	return a
+}
+
+// Test_Verify checks that tokens signed with each supported algorithm are verified, and their
+// claims returned.
+func Test_Verify(t *testing.T) {
+	a := newTestAuthenticator(t)
+	for _, alg := range supportedAlgorithms {
+		t.Run(alg, func(t *testing.T) {
+			token := signers().sign(t, map[string]any{"alg": alg, "kid": alg}, validClaims())
+			claims, err := a.Verify(t.Context(), token)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims.Subject)
+			assert.Equal(t, []string{"orders:read", "orders:write"}, claims.Scopes)
+
+			// A key without an ID is tried for tokens with any kid, or none.
+			keys := signers().keys()
+			for i := range keys {
+				keys[i].ID = ""
+			}
+			b, err := New(Options{Keys: keys})
+			require.NoError(t, err)
+			_, err = b.Verify(t.Context(), token)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// Test_Verify_rejects checks that invalid tokens are rejected, including unsigned tokens and
+// tokens that try to have a key used with a different algorithm.
+func Test_Verify_rejects(t *testing.T) {
+	a := newTestAuthenticator(t)
+	s := signers()
+	sign := func(h map[string]any, change func(map[string]any)) string {
+		claims := validClaims()
+		if change != nil {
+			change(claims)
+		}
+		return s.sign(t, h, claims)
+	}
+	rs := map[string]any{"alg": RS256, "kid": RS256}
+	now := time.Now()
+
+	// The classic algorithm confusion attack, signing with the RSA public key as an HMAC secret.
+	pub, err := x509.MarshalPKIXPublicKey(&s.rsa.PublicKey)
+	require.NoError(t, err)
+	confused := sign(map[string]any{"alg": HS256, "kid": RS256}, nil)
+	input := confused[:strings.LastIndex(confused, ".")]
+	mac := hmac.New(sha256.New, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	mac.Write([]byte(input))
+	confused = input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	valid := sign(rs, nil)
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + strings.Split(sign(rs, func(c map[string]any) {
+		c["sub"] = "admin"
+	}), ".")[1] + "." + parts[2]
+
+	tests := []struct {
+		name     string
+		token    string
+		expected error
+	}{
+		{"alg none", sign(map[string]any{"alg": "none"}, nil), ErrInvalidAlgorithm},
+		{"alg None", sign(map[string]any{"alg": "None"}, nil), ErrInvalidAlgorithm},
+		{"unsupported alg", sign(map[string]any{"alg": "HS512"}, nil), ErrInvalidAlgorithm},
+		{"algorithm confusion", confused, ErrUnknownKey},
+		{"unknown kid", sign(map[string]any{"alg": RS256, "kid": "other"}, nil), ErrUnknownKey},
+		{"tampered payload", tampered, ErrInvalidSignature},
+		{"stripped signature", parts[0] + "." + parts[1] + ".", ErrInvalidSignature},
+		{"two segments", parts[0] + "." + parts[1], ErrMalformedToken},
+		{"invalid header", "e30." + parts[1] + "." + parts[2], ErrInvalidAlgorithm},
+		{"non base64 header", "!." + parts[1] + "." + parts[2], ErrMalformedToken},
+		{
+			"critical header",
+			sign(map[string]any{"alg": RS256, "kid": RS256, "crit": []string{"b64"}}, nil),
+			ErrMalformedToken,
+		},
+		{
+			"missing exp",
+			sign(rs, func(c map[string]any) { delete(c, "exp") }),
+			ErrMalformedToken,
+		},
+		{
+			"expired",
+			sign(rs, func(c map[string]any) { c["exp"] = now.Add(-time.Minute).Unix() }),
+			ErrTokenExpired,
+		},
+		{
+			"not valid yet",
+			sign(rs, func(c map[string]any) { c["nbf"] = now.Add(time.Minute).Unix() }),
+			ErrTokenNotValidYet,
+		},
+		{
+			"issued in the future",
+			sign(rs, func(c map[string]any) { c["iat"] = now.Add(time.Minute).Unix() }),
+			ErrTokenIssuedLater,
+		},
+		{
+			"wrong issuer",
+			sign(rs, func(c map[string]any) { c["iss"] = "https://evil.example.com/" }),
+			ErrInvalidIssuer,
+		},
+		{
+			"wrong audience",
+			sign(rs, func(c map[string]any) { c["aud"] = "billing-api" }),
+			ErrInvalidAudience,
+		},
+		{
+			"invalid claim type",
+			sign(rs, func(c map[string]any) { c["sub"] = 42 }),
+			ErrMalformedToken,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := a.Verify(t.Context(), test.token)
+			assert.ErrorIs(t, err, test.expected)
+			assert.Nil(t, claims)
+		})
+	}
+}
+
+// Test_Verify_clockSkew checks that the time based claims allow for the configured clock skew.
+func Test_Verify_clockSkew(t *testing.T) {
+	a := newTestAuthenticator(t)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	h := map[string]any{"alg": EdDSA}
+
+	claims := validClaims()
+	claims["exp"] = now.Add(-20 * time.Second).Unix()
+	claims["nbf"] = now.Add(20 * time.Second).Unix()
+	claims["iat"] = now.Add(20 * time.Second).Unix()
+	_, err := a.Verify(t.Context(), signers().sign(t, h, claims))
+	assert.NoError(t, err)
+
+	claims["exp"] = now.Add(-40 * time.Second).Unix()
+	_, err = a.Verify(t.Context(), signers().sign(t, h, claims))
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
+
+// Test_Middleware checks that requests are authenticated with bearer tokens or a cookie, that the
+// claims are available to handlers, and that 401 responses include a WWW-Authenticate challenge.
+func Test_Middleware(t *testing.T) {
+	a, err := New(Options{Keys: signers().keys(), Cookie: "session", Realm: "orders"})
+	require.NoError(t, err)
+	h := a.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := FromContext(r.Context())
+		require.True(t, ok)
+		var custom struct {
+			Org string `json:"org"`
+		}
+		require.NoError(t, claims.Decode(&custom))
+		_, _ = w.Write([]byte(claims.Subject + "@" + custom.Org))
+	}))
+	token := signers().sign(t, map[string]any{"alg": ES256, "kid": ES256}, validClaims())
+
+	tests := []struct {
+		name              string
+		header            string
+		cookie            string
+		expectedCode      int
+		expectedChallenge string
+	}{
+		{"bearer token", "Bearer " + token, "", http.StatusOK, ""},
+		{"lower case scheme", "bearer " + token, "", http.StatusOK, ""},
+		{"cookie", "", token, http.StatusOK, ""},
+		{"no token", "", "", http.StatusUnauthorized, `Bearer realm="orders"`},
+		{"other scheme", "Basic " + token, token, http.StatusUnauthorized, `Bearer realm="orders"`},
+		{
+			"invalid token",
+			"Bearer " + token + "x",
+			"",
+			http.StatusUnauthorized,
+			`Bearer realm="orders", error="invalid_token"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session", Value: test.cookie})
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedChallenge, w.Header().Get("WWW-Authenticate"))
+			if test.expectedCode == http.StatusOK {
+				assert.Equal(t, "user-1@acme", w.Body.String())
+			}
+		})
+	}
+}
+
+// Test_Middleware_keysNotAvailable checks that a 500 error is returned when the JWKS can't be
+// loaded, rather than blaming the client's token.
+func Test_Middleware_keysNotAvailable(t *testing.T) {
+	jwks, err := NewJWKS(JWKSOptions{File: t.TempDir() + "/missing.json"})
+	require.NoError(t, err)
+	a, err := New(Options{JWKS: jwks})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	token := signers().sign(t, map[string]any{"alg": RS256, "kid": RS256}, validClaims())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	a.Middleware()(http.NotFoundHandler()).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// Test_Guards checks that RequireScopes requires every scope, and RequireRoles any role.
+func Test_Guards(t *testing.T) {
+	a := newTestAuthenticator(t)
+	token := signers().sign(t, map[string]any{"alg": HS256}, validClaims())
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name              string
+		guard             func(http.Handler) http.Handler
+		expectedCode      int
+		expectedChallenge string
+	}{
+		{"all scopes", RequireScopes("orders:read", "orders:write"), http.StatusOK, ""},
+		{
+			"missing scope",
+			RequireScopes("orders:read", "orders:delete"),
+			http.StatusForbidden,
+			`Bearer error="insufficient_scope", scope="orders:read orders:delete"`,
+		},
+		{"any role", RequireRoles("admin", "editor"), http.StatusOK, ""},
+		{"missing role", RequireRoles("admin"), http.StatusForbidden, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			a.Middleware()(test.guard(ok)).ServeHTTP(w, req)
+			assert.Equal(t, test.expectedCode, w.Code)
+			assert.Equal(t, test.expectedChallenge, w.Header().Get("WWW-Authenticate"))
+		})
+	}
+
+	// Without the JWT middleware, there are no claims to check.
+	w := httptest.NewRecorder()
+	RequireRoles("admin")(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// Test_New_errors checks that an Authenticator can't be created without keys, or with keys that
+// don't suit their algorithm.
+func Test_New_errors(t *testing.T) {
+	_, err := New(Options{})
+	assert.Error(t, err)
+
+	_, err = New(Options{Keys: []Key{{Algorithm: HS256, Key: &signers().rsa.PublicKey}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ------------------------------------------------------------------------------------------------
+// KEYS
+// ------------------------------------------------------------------------------------------------
+
+// The supported signing algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// Minimum key sizes, following RFC 7518.
+const (
+	minHMACKeySize = sha256.Size
+	minRSAKeyBits  = 2048
+)
+
+// Key is a key that verifies token signatures. Each key is bound to a single algorithm, so that
+// a token can't choose to have a key used with a different algorithm, such as an RSA public key
+// being used as an HMAC secret.
+type Key struct {
+	// ID matches the kid header of tokens. A key without an ID matches any kid, and a token
+	// without a kid is tried with every key for its algorithm.
+	ID string
+	// Algorithm is the algorithm the key verifies, one of HS256, RS256, ES256 or EdDSA.
+	Algorithm string
+	// Key is a []byte secret for HS256, an *rsa.PublicKey for RS256, an *ecdsa.PublicKey on the
+	// P-256 curve for ES256, or an ed25519.PublicKey for EdDSA.
+	Key any
+}
+
+// check returns an error if the key doesn't suit its algorithm.
+func (k *Key) check() error {
+	ok := false
+	switch k.Algorithm {
+	case HS256:
+		secret, isSecret := k.Key.([]byte)
+		if isSecret && len(secret) < minHMACKeySize {
+			return fmt.Errorf("HS256 key %q must be at least %d bytes", k.ID, minHMACKeySize)
+		}
+		ok = isSecret
+	case RS256:
+		pub, isRSA := k.Key.(*rsa.PublicKey)
+		if isRSA && pub.N.BitLen() < minRSAKeyBits {
+			return fmt.Errorf("RS256 key %q must be at least %d bits", k.ID, minRSAKeyBits)
+		}
+		ok = isRSA
+	case ES256:
+		pub, isECDSA := k.Key.(*ecdsa.PublicKey)
+		ok = isECDSA && pub.Curve == elliptic.P256()
+	case EdDSA:
+		pub, isEd25519 := k.Key.(ed25519.PublicKey)
+		ok = isEd25519 && len(pub) == ed25519.PublicKeySize
+	default:
+		return fmt.Errorf("unsupported algorithm %q for key %q", k.Algorithm, k.ID)
+	}
+	if !ok {
+		return fmt.Errorf("%T is not a valid %s key for key %q", k.Key, k.Algorithm, k.ID)
+	}
+	return nil
+}
+
+// verify reports whether sig is a valid signature of input.
+func (k *Key) verify(input, sig []byte) bool {
+	switch key := k.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), sig)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	case *ecdsa.PublicKey:
+		// JWS uses the fixed size r || s encoding, rather than ASN.1.
+		if len(sig) != 64 {
+			return false
+		}
+		sum := sha256.Sum256(input)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(key, sum[:], r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, input, sig)
+	}
+	return false
+}
+
+// ParsePublicKeyPEM returns a Key with the passed ID for the public key or certificate in the
+// passed PEM data. The algorithm is RS256, ES256 or EdDSA, depending on the type of key.
+func ParsePublicKeyPEM(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+
+	var pub any
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			pub = cert.PublicKey
+		}
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	key := Key{ID: id, Key: pub}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm = RS256
+	case *ecdsa.PublicKey:
+		key.Algorithm = ES256
+	case ed25519.PublicKey:
+		key.Algorithm = EdDSA
+	default:
+		return Key{}, fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return key, key.check()
+}
@@ -0,0 +1,78 @@
+package jwtauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// KEY TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_Key_check checks that keys must suit their algorithm, and be large enough.
+func Test_Key_check(t *testing.T) {
+	s := signers()
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	for _, key := range s.keys() {
+		assert.NoError(t, key.check(), key.Algorithm)
+	}
+	for _, key := range []Key{
+		{Algorithm: HS256, Key: []byte("short")},
+		{Algorithm: HS256, Key: &s.rsa.PublicKey},
+		{Algorithm: RS256, Key: s.secret},
+		{Algorithm: RS256, Key: &small.PublicKey},
+		{Algorithm: ES256, Key: &p384.PublicKey},
+		{Algorithm: ES256, Key: s.ed.Public()},
+		{Algorithm: EdDSA, Key: &s.ec.PublicKey},
+		{Algorithm: "none", Key: s.secret},
+	} {
+		assert.Error(t, key.check(), key.Algorithm)
+	}
+}
+
+// Test_ParsePublicKeyPEM checks that PEM encoded public keys are parsed, and given the algorithm
+// for their type.
+func Test_ParsePublicKeyPEM(t *testing.T) {
+	s := signers()
+	encode := func(typ string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	}
+	pkix := func(pub any) []byte {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		require.NoError(t, err)
+		return encode("PUBLIC KEY", der)
+	}
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{pkix(&s.rsa.PublicKey), RS256},
+		{encode("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&s.rsa.PublicKey)), RS256},
+		{pkix(&s.ec.PublicKey), ES256},
+		{pkix(s.ed.Public()), EdDSA},
+	}
+	for _, test := range tests {
+		key, err := ParsePublicKeyPEM("kid", test.data)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, key.Algorithm)
+		assert.Equal(t, "kid", key.ID)
+	}
+
+	_, err := ParsePublicKeyPEM("kid", []byte("not pem"))
+	assert.Error(t, err)
+	_, err = ParsePublicKeyPEM("kid", encode("PUBLIC KEY", []byte("garbage")))
+	assert.Error(t, err)
+}
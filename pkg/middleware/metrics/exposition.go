@@ -0,0 +1,221 @@
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------------------------
+// EXPOSITION
+// ------------------------------------------------------------------------------------------------
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelEscaper escapes label values, as required by the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Handler returns an http.Handler that serves the recorded metrics in the Prometheus text
+// exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = m.WriteTo(w)
+	})
+}
+
+// WriteTo writes the recorded metrics to the passed io.Writer in the Prometheus text exposition
+// format. Series are sorted by their labels, so the output is stable.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	routes, statuses := m.snapshot()
+	ew := &expositionWriter{w: bufio.NewWriter(w)}
+
+	ew.header(m.name("http_requests_total"), "counter", "Total number of HTTP requests.")
+	for _, s := range statuses {
+		value := float64(s.series.requests.Load())
+		ew.sample(m.name("http_requests_total"), s.key.labels(), "", value)
+	}
+
+	ew.header(
+		m.name("http_request_duration_seconds"),
+		"histogram",
+		"Duration of HTTP requests in seconds.",
+	)
+	for _, s := range statuses {
+		ew.histogram(m.name("http_request_duration_seconds"), s.key.labels(), s.series.duration)
+	}
+
+	ew.header(
+		m.name("http_response_size_bytes"),
+		"histogram",
+		"Size of HTTP response bodies in bytes.",
+	)
+	for _, s := range statuses {
+		ew.histogram(m.name("http_response_size_bytes"), s.key.labels(), s.series.size)
+	}
+
+	ew.header(
+		m.name("http_requests_in_flight"),
+		"gauge",
+		"Number of HTTP requests currently being handled.",
+	)
+	for _, r := range routes {
+		value := float64(r.series.inFlight.Load())
+		ew.sample(m.name("http_requests_in_flight"), r.key.labels(), "", value)
+	}
+
+	ew.header(
+		m.name("http_request_timeouts_total"),
+		"counter",
+		"Total number of HTTP requests that timed out.",
+	)
+	for _, r := range routes {
+		value := float64(r.series.timeouts.Load())
+		ew.sample(m.name("http_request_timeouts_total"), r.key.labels(), "", value)
+	}
+
+	ew.header(
+		m.name("http_request_panics_total"),
+		"counter",
+		"Total number of HTTP requests that panicked.",
+	)
+	for _, r := range routes {
+		value := float64(r.series.panics.Load())
+		ew.sample(m.name("http_request_panics_total"), r.key.labels(), "", value)
+	}
+
+	if ew.err == nil {
+		ew.err = ew.w.Flush()
+	}
+	return ew.n, ew.err
+}
+
+// name returns the passed metric name, prefixed with the namespace if there is one.
+func (m *Metrics) name(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return m.namespace + "_" + name
+}
+
+// routeEntry and statusEntry pair a series with its key, so that they can be sorted.
+type routeEntry struct {
+	key    routeKey
+	series *routeSeries
+}
+
+type statusEntry struct {
+	key    statusKey
+	series *statusSeries
+}
+
+// snapshot returns the current series, sorted by their labels.
+func (m *Metrics) snapshot() ([]routeEntry, []statusEntry) {
+	m.mu.RLock()
+	routes := make([]routeEntry, 0, len(m.routes))
+	for key, series := range m.routes {
+		routes = append(routes, routeEntry{key, series})
+	}
+	statuses := make([]statusEntry, 0, len(m.statuses))
+	for key, series := range m.statuses {
+		statuses = append(statuses, statusEntry{key, series})
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(routes, func(a, b routeEntry) int {
+		return a.key.compare(b.key)
+	})
+	slices.SortFunc(statuses, func(a, b statusEntry) int {
+		return cmp.Or(a.key.compare(b.key.routeKey), cmp.Compare(a.key.status, b.key.status))
+	})
+	return routes, statuses
+}
+
+// compare orders routeKeys by route, and then method.
+func (k routeKey) compare(other routeKey) int {
+	return cmp.Or(cmp.Compare(k.route, other.route), cmp.Compare(k.method, other.method))
+}
+
+// labels returns the formatted labels for the routeKey.
+func (k routeKey) labels() string {
+	return `method="` + labelEscaper.Replace(k.method) + `",route="` +
+		labelEscaper.Replace(k.route) + `"`
+}
+
+// labels returns the formatted labels for the statusKey.
+func (k statusKey) labels() string {
+	return k.routeKey.labels() + `,status="` + k.status + `"`
+}
+
+// ------------------------------------------------------------------------------------------------
+// EXPOSITION WRITER
+// ------------------------------------------------------------------------------------------------
+
+// expositionWriter writes lines in the text exposition format, keeping track of the number of
+// bytes written and the first error.
+type expositionWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// write writes the passed strings, unless an error has already occurred.
+func (ew *expositionWriter) write(parts ...string) {
+	for _, part := range parts {
+		if ew.err != nil {
+			return
+		}
+		n, err := ew.w.WriteString(part)
+		ew.n += int64(n)
+		ew.err = err
+	}
+}
+
+// header writes the HELP and TYPE lines for a metric.
+func (ew *expositionWriter) header(name, kind, help string) {
+	ew.write("# HELP ", name, " ", help, "\n", "# TYPE ", name, " ", kind, "\n")
+}
+
+// sample writes a single sample line. The extra labels are appended to the passed labels.
+func (ew *expositionWriter) sample(name, labels, extra string, value float64) {
+	if extra != "" {
+		labels += "," + extra
+	}
+	ew.write(name, "{", labels, "} ", formatFloat(value), "\n")
+}
+
+// histogram writes the cumulative buckets, sum and count of a histogram.
+func (ew *expositionWriter) histogram(name, labels string, h *histogram) {
+	// Read the count first, so that the +Inf bucket is never lower than a finite bucket, even if
+	// observations are made while writing.
+	count := h.count.Load()
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i].Load()
+		le := `le="` + formatFloat(bound) + `"`
+		ew.sample(name+"_bucket", labels, le, float64(min(cumulative, count)))
+	}
+	ew.sample(name+"_bucket", labels, `le="+Inf"`, float64(count))
+	ew.sample(name+"_sum", labels, "", math.Float64frombits(h.sum.Load()))
+	ew.sample(name+"_count", labels, "", float64(count))
+}
+
+// formatFloat formats a value as required by the text exposition format.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// EXPOSITION TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_WriteTo checks the complete text exposition output for a single series.
+func Test_WriteTo(t *testing.T) {
+	m := New(Options{
+		Namespace:       "app",
+		DurationBuckets: []float64{1, 0.1, math.Inf(1), 0.1},
+		SizeBuckets:     []float64{10},
+	})
+	key := routeKey{method: "GET", route: `/a"b\c`}
+	m.route(key).timeouts.Add(1)
+	m.observe(key, http.StatusOK, 50*time.Millisecond, 5)
+	m.observe(key, http.StatusOK, 2*time.Second, 20)
+
+	expected := `# HELP app_http_requests_total Total number of HTTP requests.
+# TYPE app_http_requests_total counter
+app_http_requests_total{method="GET",route="/a\"b\\c",status="2xx"} 2
+# HELP app_http_request_duration_seconds Duration of HTTP requests in seconds.
+# TYPE app_http_request_duration_seconds histogram
+app_http_request_duration_seconds_bucket{method="GET",route="/a\"b\\c",status="2xx",le="0.1"} 1
+app_http_request_duration_seconds_bucket{method="GET",route="/a\"b\\c",status="2xx",le="1"} 1
+app_http_request_duration_seconds_bucket{method="GET",route="/a\"b\\c",status="2xx",le="+Inf"} 2
+app_http_request_duration_seconds_sum{method="GET",route="/a\"b\\c",status="2xx"} 2.05
+app_http_request_duration_seconds_count{method="GET",route="/a\"b\\c",status="2xx"} 2
+# HELP app_http_response_size_bytes Size of HTTP response bodies in bytes.
+# TYPE app_http_response_size_bytes histogram
+app_http_response_size_bytes_bucket{method="GET",route="/a\"b\\c",status="2xx",le="10"} 1
+app_http_response_size_bytes_bucket{method="GET",route="/a\"b\\c",status="2xx",le="+Inf"} 2
+app_http_response_size_bytes_sum{method="GET",route="/a\"b\\c",status="2xx"} 25
+app_http_response_size_bytes_count{method="GET",route="/a\"b\\c",status="2xx"} 2
+# HELP app_http_requests_in_flight Number of HTTP requests currently being handled.
+# TYPE app_http_requests_in_flight gauge
+app_http_requests_in_flight{method="GET",route="/a\"b\\c"} 0
+# HELP app_http_request_timeouts_total Total number of HTTP requests that timed out.
+# TYPE app_http_request_timeouts_total counter
+app_http_request_timeouts_total{method="GET",route="/a\"b\\c"} 1
+# HELP app_http_request_panics_total Total number of HTTP requests that panicked.
+# TYPE app_http_request_panics_total counter
+app_http_request_panics_total{method="GET",route="/a\"b\\c"} 0
+`
+	assert.Equal(t, expected, exposition(t, m))
+}
+
+// Test_WriteTo_sorted checks that series are written in a stable order.
+func Test_WriteTo_sorted(t *testing.T) {
+	m := New(Options{})
+	for _, key := range []routeKey{{"POST", "/b"}, {"GET", "/b"}, {"GET", "/a"}} {
+		m.route(key)
+	}
+
+	out := exposition(t, m)
+	a := `http_requests_in_flight{method="GET",route="/a"}`
+	getB := `http_requests_in_flight{method="GET",route="/b"}`
+	postB := `http_requests_in_flight{method="POST",route="/b"}`
+	assert.Less(t, strings.Index(out, a), strings.Index(out, getB))
+	assert.Less(t, strings.Index(out, getB), strings.Index(out, postB))
+}
+
+// Test_Handler checks that the handler serves the exposition format.
+func Test_Handler(t *testing.T) {
+	m := New(Options{})
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "# TYPE http_requests_total counter\n")
+}
+
+// Test_formatFloat checks the formatting of special values.
+func Test_formatFloat(t *testing.T) {
+	assert.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	assert.Equal(t, "-Inf", formatFloat(math.Inf(-1)))
+	assert.Equal(t, "NaN", formatFloat(math.NaN()))
+	assert.Equal(t, "0.25", formatFloat(0.25))
+	assert.Equal(t, "1e+07", formatFloat(10_000_000))
+}
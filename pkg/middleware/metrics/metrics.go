@@ -0,0 +1,317 @@
+// Package metrics provides middleware that records Prometheus style HTTP metrics for an rmhttp
+// App, along with a handler that serves them in the Prometheus text exposition format, without
+// depending on the official client library.
+//
+// Requests are labelled by method, the matched route pattern (r.Pattern, rather than the raw path,
+// so that path parameters do not cause a cardinality explosion) and status class. The following
+// metrics are recorded:
+//
+//   - http_requests_total: a counter of completed requests.
+//   - http_request_duration_seconds: a histogram of request durations.
+//   - http_response_size_bytes: a histogram of response body sizes.
+//   - http_requests_in_flight: a gauge of requests currently being handled.
+//   - http_request_timeouts_total: a counter of requests that hit a Route timeout.
+//   - http_request_panics_total: a counter of requests that panicked.
+//
+// Typical usage adds the middleware globally, straight after the recoverer, so that it measures
+// every other middleware and sees panics before they are recovered. The metrics are then served
+// from the admin server.
+//
+//	m := metrics.New(metrics.Options{})
+//	app.Use(recoverer.Middleware(), m.Middleware())
+//	app.Admin.Handle("GET /metrics", m.Handler())
+package metrics
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/rmhubbert/rmhttp/v5"
+)
+
+// ------------------------------------------------------------------------------------------------
+// METRICS
+// ------------------------------------------------------------------------------------------------
+
+// UnmatchedRoute is the route label used for requests that did not match a route pattern, such as
+// 404 responses, so that unknown paths share a single series.
+const UnmatchedRoute = "unmatched"
+
+// OtherMethod is the method label used for non standard request methods, so that arbitrary methods
+// share a single series.
+const OtherMethod = "OTHER"
+
+// DefaultDurationBuckets are the default upper bounds, in seconds, of the request duration
+// histogram buckets.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the default upper bounds, in bytes, of the response size histogram
+// buckets.
+var DefaultSizeBuckets = []float64{100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+// Options configures the Metrics.
+type Options struct {
+	// Namespace is prepended to every metric name, separated by an underscore.
+	Namespace string
+	// DurationBuckets overrides DefaultDurationBuckets.
+	DurationBuckets []float64
+	// SizeBuckets overrides DefaultSizeBuckets.
+	SizeBuckets []float64
+}
+
+// routeKey identifies the series shared by every response from a route.
+type routeKey struct {
+	method string
+	route  string
+}
+
+// statusKey identifies the series for a single status class of a route.
+type statusKey struct {
+	routeKey
+	status string
+}
+
+// routeSeries holds the per route metrics.
+type routeSeries struct {
+	inFlight atomic.Int64
+	timeouts atomic.Uint64
+	panics   atomic.Uint64
+}
+
+// statusSeries holds the per status class metrics.
+type statusSeries struct {
+	requests atomic.Uint64
+	duration *histogram
+	size     *histogram
+}
+
+// Metrics records HTTP metrics, and serves them in the Prometheus text exposition format. It is
+// safe for concurrent use.
+type Metrics struct {
+	namespace       string
+	durationBuckets []float64
+	sizeBuckets     []float64
+
+	mu       sync.RWMutex
+	routes   map[routeKey]*routeSeries
+	statuses map[statusKey]*statusSeries
+}
+
+// New creates, initialises and returns a pointer to a new Metrics.
+func New(options Options) *Metrics {
+	return &Metrics{
+		namespace:       options.Namespace,
+		durationBuckets: sortedBuckets(options.DurationBuckets, DefaultDurationBuckets),
+		sizeBuckets:     sortedBuckets(options.SizeBuckets, DefaultSizeBuckets),
+		routes:          map[routeKey]*routeSeries{},
+		statuses:        map[statusKey]*statusSeries{},
+	}
+}
+
+// Middleware returns a middleware function that records the metrics for each request.
+//
+// Panics are recorded and then re-panicked, so that a recoverer registered before this middleware
+// can still handle them. A recoverer registered after it will handle panics first, so they will
+// only be recorded as 5xx responses. Timeouts are recorded via rmhttp.OnTimeout, so this
+// middleware must run before the Route timeout, which it will do when added with App.Use or
+// Group.WithMiddleware.
+func (m *Metrics) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := routeKey{method: methodLabel(r.Method), route: routeLabel(r.Pattern)}
+			rs := m.route(key)
+			rs.inFlight.Add(1)
+
+			start := time.Now()
+			code := 0
+			var written int64
+			hooked := httpsnoop.Wrap(w, httpsnoop.Hooks{
+				WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+					return func(c int) {
+						if code == 0 && c >= http.StatusOK {
+							code = c
+						}
+						next(c)
+					}
+				},
+				Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+					return func(b []byte) (int, error) {
+						if code == 0 {
+							code = http.StatusOK
+						}
+						n, err := next(b)
+						written += int64(n)
+						return n, err
+					}
+				},
+				ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+					return func(src io.Reader) (int64, error) {
+						if code == 0 {
+							code = http.StatusOK
+						}
+						n, err := next(src)
+						written += n
+						return n, err
+					}
+				},
+			})
+
+			defer func() {
+				rs.inFlight.Add(-1)
+				if p := recover(); p != nil {
+					rs.panics.Add(1)
+					// An aborted handler does not get a response, so don't count it as a server
+					// error.
+					if p != http.ErrAbortHandler && code == 0 {
+						code = http.StatusInternalServerError
+					}
+					m.observe(key, code, time.Since(start), written)
+					panic(p)
+				}
+				if code == 0 {
+					code = http.StatusOK
+				}
+				m.observe(key, code, time.Since(start), written)
+			}()
+
+			ctx := rmhttp.OnTimeout(r.Context(), func(rmhttp.Timeout) {
+				rs.timeouts.Add(1)
+			})
+			next.ServeHTTP(hooked, r.WithContext(ctx))
+		})
+	}
+}
+
+// observe records a completed request.
+func (m *Metrics) observe(key routeKey, code int, duration time.Duration, size int64) {
+	ss := m.status(statusKey{routeKey: key, status: statusLabel(code)})
+	ss.requests.Add(1)
+	ss.duration.observe(duration.Seconds())
+	ss.size.observe(float64(size))
+}
+
+// route returns the routeSeries for the passed key, creating it if it does not exist.
+func (m *Metrics) route(key routeKey) *routeSeries {
+	m.mu.RLock()
+	rs, ok := m.routes[key]
+	m.mu.RUnlock()
+	if ok {
+		return rs
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rs, ok = m.routes[key]; !ok {
+		rs = &routeSeries{}
+		m.routes[key] = rs
+	}
+	return rs
+}
+
+// status returns the statusSeries for the passed key, creating it if it does not exist.
+func (m *Metrics) status(key statusKey) *statusSeries {
+	m.mu.RLock()
+	ss, ok := m.statuses[key]
+	m.mu.RUnlock()
+	if ok {
+		return ss
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ss, ok = m.statuses[key]; !ok {
+		ss = &statusSeries{
+			duration: newHistogram(m.durationBuckets),
+			size:     newHistogram(m.sizeBuckets),
+		}
+		m.statuses[key] = ss
+	}
+	return ss
+}
+
+// ------------------------------------------------------------------------------------------------
+// LABELS
+// ------------------------------------------------------------------------------------------------
+
+// methodLabel returns the method label for the passed request method.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return OtherMethod
+}
+
+// routeLabel returns the route label for the passed route pattern. The method is removed from the
+// pattern, as it is already a label of its own.
+func routeLabel(pattern string) string {
+	if pattern == "" {
+		return UnmatchedRoute
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return strings.TrimSpace(path)
+	}
+	return pattern
+}
+
+// statusLabel returns the status class label, such as 2xx, for the passed status code.
+func statusLabel(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return string(rune('0'+code/100)) + "xx"
+}
+
+// ------------------------------------------------------------------------------------------------
+// HISTOGRAM
+// ------------------------------------------------------------------------------------------------
+
+// histogram is a lock free, fixed bucket histogram.
+type histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomic.Uint64
+}
+
+// newHistogram creates, initialises and returns a pointer to a histogram with the passed bucket
+// upper bounds, which must be sorted.
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]atomic.Uint64, len(buckets))}
+}
+
+// observe adds the passed value to the histogram. Bucket counts are stored non cumulatively, and
+// accumulated when the histogram is written.
+func (h *histogram) observe(v float64) {
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// sortedBuckets returns a sorted, de-duplicated copy of the passed buckets, or the defaults if
+// none were passed. The +Inf bucket is always written, so it is removed, along with any NaN.
+func sortedBuckets(buckets []float64, defaults []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = defaults
+	}
+	sorted := slices.DeleteFunc(slices.Clone(buckets), func(b float64) bool {
+		return math.IsNaN(b) || math.IsInf(b, 1)
+	})
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
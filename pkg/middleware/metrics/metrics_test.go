@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmhubbert/rmhttp/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// METRICS TESTS
+// ------------------------------------------------------------------------------------------------
+
+// serve sends a request with the passed method and path to the App, and returns the recorder.
+func serve(app *rmhttp.App, method string, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+// exposition returns the metrics in the text exposition format.
+func exposition(t *testing.T, m *Metrics) string {
+	t.Helper()
+	var sb strings.Builder
+	_, err := m.WriteTo(&sb)
+	require.NoError(t, err)
+	return sb.String()
+}
+
+// Test_Middleware checks that requests are counted and labelled by method, route pattern and
+// status class.
+func Test_Middleware(t *testing.T) {
+	m := New(Options{})
+	app := rmhttp.New()
+	app.Use(m.Middleware())
+	app.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("user"))
+	})
+	app.Post("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	serve(app, http.MethodGet, "/users/1")
+	serve(app, http.MethodGet, "/users/2")
+	serve(app, http.MethodPost, "/users")
+	serve(app, http.MethodGet, "/missing")
+	serve(app, http.MethodGet, "/also-missing")
+
+	out := exposition(t, m)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/users/{id}",status="2xx"} 2`)
+	assert.Contains(t, out, `http_requests_total{method="POST",route="/users",status="4xx"} 1`)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="unmatched",status="4xx"} 2`)
+	assert.Contains(
+		t,
+		out,
+		`http_response_size_bytes_sum{method="GET",route="/users/{id}",status="2xx"} 8`,
+	)
+	assert.Contains(t, out, `http_requests_in_flight{method="GET",route="/users/{id}"} 0`)
+	assert.NotContains(t, out, "/users/1")
+}
+
+// Test_Middleware_in_flight checks that in-flight requests are counted while being handled.
+func Test_Middleware_in_flight(t *testing.T) {
+	m := New(Options{})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		req.Pattern = "GET /slow"
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	<-started
+	assert.Contains(t, exposition(t, m), `http_requests_in_flight{method="GET",route="/slow"} 1`)
+	close(release)
+	<-done
+	assert.Contains(t, exposition(t, m), `http_requests_in_flight{method="GET",route="/slow"} 0`)
+}
+
+// Test_Middleware_panics checks that panics are recorded as server errors, and then re-panicked
+// for the recoverer to handle.
+func Test_Middleware_panics(t *testing.T) {
+	m := New(Options{})
+	h := m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Pattern = "/panic"
+
+	assert.PanicsWithValue(t, "boom", func() {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		m.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	out := exposition(t, m)
+	assert.Contains(t, out, `http_request_panics_total{method="GET",route="/panic"} 2`)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/panic",status="5xx"} 1`)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/panic",status="unknown"} 1`)
+}
+
+// Test_Middleware_timeouts checks that Route timeouts are recorded.
+func Test_Middleware_timeouts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	m := New(Options{})
+	app := rmhttp.New()
+	app.Use(m.Middleware())
+	app.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}).WithTimeout(20*time.Millisecond, "timed out")
+	app.Get("/fast", func(w http.ResponseWriter, r *http.Request) {}).
+		WithTimeout(time.Second, "timed out")
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(app, http.MethodGet, "/slow").Code)
+	serve(app, http.MethodGet, "/fast")
+
+	out := exposition(t, m)
+	assert.Contains(t, out, `http_request_timeouts_total{method="GET",route="/slow"} 1`)
+	assert.Contains(t, out, `http_request_timeouts_total{method="GET",route="/fast"} 0`)
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/slow",status="5xx"} 1`)
+}
+
+// Test_labels checks the label normalisation.
+func Test_labels(t *testing.T) {
+	assert.Equal(t, "GET", methodLabel(http.MethodGet))
+	assert.Equal(t, OtherMethod, methodLabel("PROPFIND"))
+
+	assert.Equal(t, UnmatchedRoute, routeLabel(""))
+	assert.Equal(t, "/users/{id}", routeLabel("GET /users/{id}"))
+	assert.Equal(t, "example.com/users", routeLabel("example.com/users"))
+
+	assert.Equal(t, "1xx", statusLabel(http.StatusContinue))
+	assert.Equal(t, "2xx", statusLabel(http.StatusNoContent))
+	assert.Equal(t, "5xx", statusLabel(http.StatusServiceUnavailable))
+	assert.Equal(t, "unknown", statusLabel(0))
+}
@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ALGORITHMS
+// ------------------------------------------------------------------------------------------------
+
+// Algorithm selects how requests are counted against a Limit.
+type Algorithm int
+
+const (
+	// TokenBucket allows bursts of up to Burst requests, refilled steadily at Requests per
+	// Period.
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Requests in any Period, estimated from the counts of the current and
+	// previous fixed windows, weighted by how much of the previous window is still in the period.
+	SlidingWindow
+)
+
+// Limit is the number of requests allowed for each key.
+type Limit struct {
+	// Requests is the number of requests allowed per Period.
+	Requests int
+	// Period is the period that Requests are allowed in.
+	Period time.Duration
+	// Algorithm selects how requests are counted. Defaults to TokenBucket.
+	Algorithm Algorithm
+	// Burst is the largest number of requests allowed at once with TokenBucket. Defaults to
+	// Requests.
+	Burst int
+}
+
+// PerSecond returns a token bucket Limit of the passed number of requests per second.
+func PerSecond(requests int) Limit {
+	return Limit{Requests: requests, Period: time.Second}
+}
+
+// PerMinute returns a token bucket Limit of the passed number of requests per minute.
+func PerMinute(requests int) Limit {
+	return Limit{Requests: requests, Period: time.Minute}
+}
+
+// PerHour returns a token bucket Limit of the passed number of requests per hour.
+func PerHour(requests int) Limit {
+	return Limit{Requests: requests, Period: time.Hour}
+}
+
+// capacity returns the number of requests reported in the RateLimit-Limit header.
+func (l Limit) capacity() int {
+	if l.Algorithm == TokenBucket && l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Result is the outcome of counting a request against a Limit.
+type Result struct {
+	// Allowed reports whether the request is within the limit.
+	Allowed bool
+	// Limit is the number of requests allowed, for the RateLimit-Limit header.
+	Limit int
+	// Remaining is the number of further requests allowed now.
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long until a request will be allowed, if this one wasn't.
+	RetryAfter time.Duration
+}
+
+// state is the state of a key, used by both algorithms.
+type state struct {
+	// tokens is the number of tokens in the bucket for TokenBucket, or the count of the current
+	// window for SlidingWindow.
+	tokens float64
+	// previous is the count of the previous window for SlidingWindow.
+	previous float64
+	// at is when the bucket was last refilled for TokenBucket, or the start of the current
+	// window for SlidingWindow.
+	at time.Time
+	// expires is when the state is no longer needed, as the full limit would be available.
+	expires time.Time
+}
+
+// take counts a request against the passed limit, updating the state.
+func (s *state) take(limit Limit, now time.Time) Result {
+	if limit.Algorithm == SlidingWindow {
+		return s.slidingWindow(limit, now)
+	}
+	return s.tokenBucket(limit, now)
+}
+
+// tokenBucket counts a request with the TokenBucket algorithm. A new state starts with a full
+// bucket.
+func (s *state) tokenBucket(limit Limit, now time.Time) Result {
+	capacity := float64(limit.capacity())
+	rate := float64(limit.Requests) / limit.Period.Seconds()
+	if s.at.IsZero() {
+		s.tokens = capacity
+	} else {
+		s.tokens = min(capacity, s.tokens+now.Sub(s.at).Seconds()*rate)
+	}
+	s.at = now
+
+	result := Result{Limit: limit.capacity()}
+	if s.tokens >= 1 {
+		s.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - s.tokens) / rate)
+	}
+	result.Remaining = int(s.tokens)
+	result.Reset = seconds((capacity - s.tokens) / rate)
+	s.expires = now.Add(result.Reset)
+	return result
+}
+
+// slidingWindow counts a request with the SlidingWindow algorithm.
+func (s *state) slidingWindow(limit Limit, now time.Time) Result {
+	period := limit.Period
+	start := now.Truncate(period)
+	switch {
+	case s.at.Equal(start):
+	case s.at.Add(period).Equal(start):
+		s.previous, s.tokens = s.tokens, 0
+	default:
+		s.previous, s.tokens = 0, 0
+	}
+	s.at = start
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(period)
+	requests := float64(limit.Requests)
+
+	result := Result{Limit: limit.Requests, Reset: period - elapsed}
+	count := s.previous*weight + s.tokens
+	if count+1 <= requests {
+		s.tokens++
+		count++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = s.retryAfter(requests, weight, period, elapsed)
+	}
+	result.Remaining = max(0, int(requests-count))
+	s.expires = start.Add(2 * period)
+	return result
+}
+
+// retryAfter returns how long until a request will be allowed by the SlidingWindow algorithm,
+// as the weight of the previous window falls, or after the current window ends.
+func (s *state) retryAfter(requests, weight float64, period, elapsed time.Duration) time.Duration {
+	room := requests - 1 - s.tokens
+	if room >= 0 && s.previous > 0 {
+		return time.Duration((weight - room/s.previous) * float64(period))
+	}
+	wait := period - elapsed
+	if s.tokens > 0 {
+		wait += time.Duration((1 - (requests-1)/s.tokens) * float64(period))
+	}
+	return wait
+}
+
+// seconds returns the passed number of seconds as a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
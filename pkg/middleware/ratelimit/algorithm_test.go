@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// ALGORITHM TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_tokenBucket checks that a burst is allowed, then requests are allowed as tokens refill.
+func Test_tokenBucket(t *testing.T) {
+	limit := Limit{Requests: 2, Period: time.Second, Burst: 3}
+	now := time.Unix(1_000_000, 0)
+	s := &state{}
+
+	for i := range 3 {
+		result := s.take(limit, now)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+	result := s.take(limit, now)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+	assert.Equal(t, now.Add(1500*time.Millisecond), s.expires)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, s.take(limit, now).Allowed)
+	assert.False(t, s.take(limit, now).Allowed)
+
+	now = now.Add(time.Hour)
+	result = s.take(limit, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining, "the bucket should not refill beyond the burst")
+}
+
+// Test_slidingWindow checks that the requests in any period are limited, weighting the previous
+// window by how much of it is still in the period.
+func Test_slidingWindow(t *testing.T) {
+	limit := Limit{Requests: 4, Period: time.Minute, Algorithm: SlidingWindow}
+	start := time.Unix(1_000_000, 0).Truncate(time.Minute)
+	s := &state{}
+
+	now := start.Add(30 * time.Second)
+	for i := range 4 {
+		result := s.take(limit, now)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 4, result.Limit)
+		assert.Equal(t, 3-i, result.Remaining)
+		assert.Equal(t, 30*time.Second, result.Reset)
+	}
+	result := s.take(limit, now)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 30*time.Second+15*time.Second, result.RetryAfter)
+
+	// A quarter of the way into the next window, the previous window counts for 3 requests.
+	now = start.Add(75 * time.Second)
+	result = s.take(limit, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	result = s.take(limit, now)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 15*time.Second, result.RetryAfter)
+
+	now = now.Add(result.RetryAfter)
+	assert.True(t, s.take(limit, now).Allowed)
+
+	// After a window without requests, the count starts again.
+	now = start.Add(3 * time.Minute)
+	result = s.take(limit, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 3, result.Remaining)
+	assert.Equal(t, start.Add(5*time.Minute), s.expires)
+}
+
+// Test_Limit checks the limit constructors and the reported limit.
+func Test_Limit(t *testing.T) {
+	assert.Equal(t, Limit{Requests: 5, Period: time.Second}, PerSecond(5))
+	assert.Equal(t, Limit{Requests: 5, Period: time.Minute}, PerMinute(5))
+	assert.Equal(t, Limit{Requests: 5, Period: time.Hour}, PerHour(5))
+	assert.Equal(t, 5, PerMinute(5).capacity())
+	assert.Equal(t, 9, Limit{Requests: 5, Burst: 9}.capacity())
+	assert.Equal(t, 5, Limit{Requests: 5, Burst: 9, Algorithm: SlidingWindow}.capacity())
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"net/http"
+
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/apikey"
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/realip"
+)
+
+// ------------------------------------------------------------------------------------------------
+// KEYS
+// ------------------------------------------------------------------------------------------------
+
+// KeyFunc returns the key that a request is counted under, such as the client it came from. If
+// it returns an empty string, the request is counted under its client IP.
+type KeyFunc func(*http.Request) string
+
+// ByIP counts requests under their client IP, as resolved by the realip middleware if it has
+// run, or the remote address otherwise.
+func ByIP(r *http.Request) string {
+	return "ip:" + realip.ClientIP(r)
+}
+
+// ByAPIKey counts requests under the identity of the API key that authenticated them, so the
+// apikey middleware must run first. Requests without a key are counted under their client IP.
+func ByAPIKey(r *http.Request) string {
+	if key, ok := apikey.FromContext(r.Context()); ok {
+		return "apikey:" + key.Identity
+	}
+	return ""
+}
+
+// ByHeader returns a KeyFunc that counts requests under the value of the passed header, such as
+// a tenant ID set by a trusted proxy. Requests without the header are counted under their client
+// IP. Clients can set any header, so only use one that is checked before this middleware runs.
+func ByHeader(name string) KeyFunc {
+	canonical := http.CanonicalHeaderKey(name)
+	return func(r *http.Request) string {
+		if value := r.Header.Get(canonical); value != "" {
+			return "header:" + canonical + ":" + value
+		}
+		return ""
+	}
+}
@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/apikey"
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// KEY TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_KeyFuncs checks the keys returned for requests with and without the relevant details.
+func Test_KeyFuncs(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "ip:192.0.2.1", ByIP(r))
+	assert.Empty(t, ByAPIKey(r))
+	assert.Empty(t, ByHeader("X-Tenant")(r))
+
+	r.Header.Set("X-Tenant", "acme")
+	assert.Equal(t, "header:X-Tenant:acme", ByHeader("x-tenant")(r))
+
+	r = r.WithContext(apikey.NewContext(r.Context(), apikey.Key{Identity: "billing"}))
+	assert.Equal(t, "apikey:billing", ByAPIKey(r))
+}
@@ -0,0 +1,129 @@
+// Package ratelimit provides middleware that limits the rate of requests from each client.
+//
+// Requests are counted under a key, such as the client IP or API key identity, with a token
+// bucket or sliding window algorithm. Responses carry the RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers, and requests over the limit get a 429 response with a Retry-After
+// header. Different limits can be applied to different groups and routes, by adding a limiter to
+// each of their middleware chains.
+//
+//	api, err := ratelimit.New(ratelimit.Options{
+//		Limit: ratelimit.PerMinute(100),
+//		Key:   ratelimit.ByAPIKey,
+//	})
+//	app.Group("/api").Use(auth.Middleware(), api.Middleware())
+package ratelimit
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// RATE LIMIT
+// ------------------------------------------------------------------------------------------------
+
+// Headers set on responses.
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// Options configures the rate limit middleware.
+type Options struct {
+	// Limit is the number of requests allowed for each key.
+	Limit Limit
+	// Key returns the key that a request is counted under. Defaults to ByIP.
+	Key KeyFunc
+	// Store counts requests. Defaults to a new MemoryStore for the limiter. Use a shared store,
+	// such as one backed by a database, to count requests across processes.
+	Store Store
+	// Name is prefixed to the keys, so that limiters sharing a Store count requests separately.
+	// Limiters with different limits must have different names.
+	Name string
+	// ErrorHandler writes the response for a request over the limit, after the headers have been
+	// set. Defaults to a plain 429 response.
+	ErrorHandler http.Handler
+}
+
+// Limiter limits the rate of requests.
+type Limiter struct {
+	options Options
+}
+
+// New creates, initialises and returns a pointer to a new Limiter. An error is returned if the
+// limit has no requests or period.
+func New(options Options) (*Limiter, error) {
+	limit := options.Limit
+	if limit.Requests <= 0 || limit.Period <= 0 || limit.Burst < 0 {
+		return nil, errors.New("rate limit requires a positive number of requests and period")
+	}
+	if options.Key == nil {
+		options.Key = ByIP
+	}
+	if options.Store == nil {
+		options.Store = NewMemoryStore(0)
+	}
+	if options.ErrorHandler == nil {
+		options.ErrorHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(
+				w,
+				http.StatusText(http.StatusTooManyRequests),
+				http.StatusTooManyRequests,
+			)
+		})
+	}
+	return &Limiter{options: options}, nil
+}
+
+// Middleware returns a middleware function that counts each request, sets the rate limit headers,
+// and rejects requests over the limit with a 429 response. If the Store fails, the error is logged
+// and the request is allowed.
+func (l *Limiter) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := l.options.Key(r)
+			if key == "" {
+				key = ByIP(r)
+			}
+			key = l.options.Name + "/" + key
+			result, err := l.options.Store.Take(r.Context(), key, l.options.Limit)
+			if err != nil {
+				slog.Error("failed to check rate limit", "type", "ratelimit", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setHeaders(w.Header(), result)
+			if !result.Allowed {
+				w.Header().Set(HeaderRetryAfter, formatSeconds(result.RetryAfter))
+				l.options.ErrorHandler.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setHeaders sets the rate limit headers for the passed result. When several limiters apply to a
+// request, the headers of the one with the fewest remaining requests are kept.
+func setHeaders(h http.Header, result Result) {
+	if existing := h.Get(HeaderRemaining); existing != "" {
+		if remaining, err := strconv.Atoi(existing); err == nil && remaining < result.Remaining {
+			return
+		}
+	}
+	h.Set(HeaderLimit, strconv.Itoa(result.Limit))
+	h.Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+	h.Set(HeaderReset, formatSeconds(result.Reset))
+}
+
+// formatSeconds returns the passed duration as a whole number of seconds, rounded up.
+func formatSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
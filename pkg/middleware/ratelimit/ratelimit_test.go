@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// RATE LIMIT TESTS
+// ------------------------------------------------------------------------------------------------
+
+// okHandler writes a 200 response.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("ok"))
+})
+
+// serveLimited runs a request from the passed address through the passed handler.
+func serveLimited(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// failingStore is a Store that always fails.
+type failingStore struct{}
+
+// Take returns an error.
+func (failingStore) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("store unavailable")
+}
+
+// Test_Middleware checks that requests within the limit are allowed with the rate limit headers,
+// and requests over it get a 429 response with a Retry-After header.
+func Test_Middleware(t *testing.T) {
+	l, err := New(Options{Limit: PerMinute(2)})
+	require.NoError(t, err)
+	h := l.Middleware()(okHandler)
+
+	w := serveLimited(h, "192.0.2.1:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(HeaderLimit))
+	assert.Equal(t, "1", w.Header().Get(HeaderRemaining))
+	assert.Equal(t, "30", w.Header().Get(HeaderReset))
+	assert.Empty(t, w.Header().Get(HeaderRetryAfter))
+
+	assert.Equal(t, http.StatusOK, serveLimited(h, "192.0.2.1:1234").Code)
+	w = serveLimited(h, "192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(HeaderRemaining))
+	assert.Equal(t, "30", w.Header().Get(HeaderRetryAfter))
+
+	assert.Equal(t, http.StatusOK, serveLimited(h, "192.0.2.2:1234").Code)
+}
+
+// Test_Middleware_Options checks that the key, name and error handler options are used.
+func Test_Middleware_Options(t *testing.T) {
+	store := NewMemoryStore(0)
+	header := ByHeader("X-Tenant")
+	teapot := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	a, err := New(Options{
+		Limit:        PerHour(1),
+		Key:          header,
+		Store:        store,
+		Name:         "a",
+		ErrorHandler: teapot,
+	})
+	require.NoError(t, err)
+	b, err := New(Options{Limit: PerHour(1), Key: header, Store: store, Name: "b"})
+	require.NoError(t, err)
+
+	request := func(h http.Handler, tenant, remoteAddr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Tenant", tenant)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	ha, hb := a.Middleware()(okHandler), b.Middleware()(okHandler)
+	assert.Equal(t, http.StatusOK, request(ha, "acme", "192.0.2.1:1"))
+	assert.Equal(t, http.StatusTeapot, request(ha, "acme", "192.0.2.2:1"))
+	assert.Equal(t, http.StatusOK, request(hb, "acme", "192.0.2.1:1"))
+	assert.Equal(t, http.StatusOK, request(ha, "other", "192.0.2.1:1"))
+
+	assert.Equal(t, http.StatusOK, request(ha, "", "192.0.2.1:1"), "the IP should be used")
+	assert.Equal(t, http.StatusTeapot, request(ha, "", "192.0.2.1:1"))
+}
+
+// Test_Middleware_Nested checks that the headers of the most restrictive limiter are kept.
+func Test_Middleware_Nested(t *testing.T) {
+	group, err := New(Options{Limit: PerMinute(100)})
+	require.NoError(t, err)
+	route, err := New(Options{Limit: PerMinute(5)})
+	require.NoError(t, err)
+
+	w := serveLimited(group.Middleware()(route.Middleware()(okHandler)), "192.0.2.1:1234")
+	assert.Equal(t, "5", w.Header().Get(HeaderLimit))
+	assert.Equal(t, "4", w.Header().Get(HeaderRemaining))
+
+	w = serveLimited(route.Middleware()(group.Middleware()(okHandler)), "192.0.2.2:1234")
+	assert.Equal(t, "5", w.Header().Get(HeaderLimit))
+	assert.Equal(t, "4", w.Header().Get(HeaderRemaining))
+}
+
+// Test_Middleware_StoreError checks that requests are allowed when the store fails.
+func Test_Middleware_StoreError(t *testing.T) {
+	l, err := New(Options{Limit: PerSecond(1), Store: failingStore{}})
+	require.NoError(t, err)
+	w := serveLimited(l.Middleware()(okHandler), "192.0.2.1:1234")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(HeaderLimit))
+}
+
+// Test_New checks that invalid limits are rejected.
+func Test_New(t *testing.T) {
+	for _, limit := range []Limit{
+		{},
+		{Requests: 1},
+		{Period: time.Second},
+		{Requests: 1, Period: time.Second, Burst: -1},
+	} {
+		_, err := New(Options{Limit: limit})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// STORES
+// ------------------------------------------------------------------------------------------------
+
+// Store counts requests for each key. Stores must be safe for concurrent use, and count each
+// request atomically, so that concurrent requests can't exceed the limit.
+type Store interface {
+	// Take counts a request for the passed key against the passed limit, and returns the result.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// DefaultMaxKeys is the default number of keys held by a MemoryStore.
+const DefaultMaxKeys = 100_000
+
+// shardCount is the number of shards in a MemoryStore. It must be a power of two.
+const shardCount = 64
+
+// MemoryStore is a Store that holds counts in memory, split into shards that are locked
+// separately, so that requests for different keys rarely wait for each other. Keys are evicted
+// once their full limit is available again, and the oldest keys in a shard are evicted when it is
+// full. Counts aren't shared between processes.
+type MemoryStore struct {
+	seed    maphash.Seed
+	shards  [shardCount]shard
+	maxKeys int
+	now     func() time.Time
+}
+
+// shard is a part of a MemoryStore.
+type shard struct {
+	mu     sync.Mutex
+	states map[string]*state
+	swept  time.Time
+}
+
+// sweepInterval is how often each shard removes states that are no longer needed.
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates, initialises and returns a pointer to a new MemoryStore, holding up to
+// the passed number of keys. If maxKeys is zero or less, DefaultMaxKeys is used.
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	s := &MemoryStore{
+		seed:    maphash.MakeSeed(),
+		maxKeys: max(1, maxKeys/shardCount),
+		now:     time.Now,
+	}
+	for i := range s.shards {
+		s.shards[i].states = map[string]*state{}
+	}
+	return s
+}
+
+// Take counts a request for the passed key against the passed limit.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	sh := &s.shards[maphash.String(s.seed, key)&(shardCount-1)]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	now := s.now()
+	st, ok := sh.states[key]
+	if ok && !now.Before(st.expires) {
+		*st = state{}
+	}
+	if !ok {
+		sh.evict(now, s.maxKeys)
+		st = &state{}
+		sh.states[key] = st
+	}
+	return st.take(limit, now), nil
+}
+
+// Len returns the number of keys held, including any that are no longer needed but haven't been
+// evicted yet.
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.states)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// evict removes states that are no longer needed, at most once per sweep interval, or when the
+// shard is full. If the shard is still full, the state that expires soonest is removed, as it is
+// the closest to having its full limit available anyway. It must be called with the lock held.
+func (sh *shard) evict(now time.Time, maxKeys int) {
+	if len(sh.states) < maxKeys && now.Sub(sh.swept) < sweepInterval {
+		return
+	}
+	sh.swept = now
+	for key, st := range sh.states {
+		if !now.Before(st.expires) {
+			delete(sh.states, key)
+		}
+	}
+	if len(sh.states) < maxKeys {
+		return
+	}
+	var oldest string
+	var expires time.Time
+	found := false
+	for key, st := range sh.states {
+		if !found || st.expires.Before(expires) {
+			oldest, expires, found = key, st.expires, true
+		}
+	}
+	delete(sh.states, oldest)
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// STORE TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_MemoryStore checks that requests are counted separately for each key, and that keys are
+// evicted once their full limit is available again.
+func Test_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_000_000, 0)
+	s := NewMemoryStore(0)
+	s.now = func() time.Time { return now }
+	limit := PerSecond(1)
+
+	result, err := s.Take(ctx, "a", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	result, err = s.Take(ctx, "a", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	result, err = s.Take(ctx, "b", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, s.Len())
+
+	now = now.Add(2 * sweepInterval)
+	for i := range shardCount * 4 {
+		_, err := s.Take(ctx, fmt.Sprint("key", i), limit)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, shardCount*4, s.Len(), "expired keys should be evicted")
+}
+
+// Test_MemoryStore_MaxKeys checks that a full store evicts keys to make room.
+func Test_MemoryStore_MaxKeys(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(shardCount)
+	for i := range shardCount * 8 {
+		_, err := s.Take(ctx, fmt.Sprint("key", i), PerHour(1))
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, s.Len(), shardCount)
+}
+
+// Test_MemoryStore_Concurrent checks that concurrent requests can't exceed the limit.
+func Test_MemoryStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(0)
+	limit := PerHour(50)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 200 {
+		wg.Go(func() {
+			result, err := s.Take(ctx, "key", limit)
+			assert.NoError(t, err)
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, 50, allowed)
+}
@@ -0,0 +1,268 @@
+// Package realip resolves the IP address of the client that made a request, taking trusted
+// proxies into account.
+//
+// Proxy headers such as X-Forwarded-For can be set by anyone, so they are only believed when the
+// request arrived from a trusted proxy. The configured headers are then walked from right to left,
+// skipping hops that are themselves trusted proxies, and the first untrusted address is the client.
+//
+//	app.Use(
+//		realip.Middleware(realip.Options{TrustedProxies: []string{"10.0.0.0/8"}}),
+//		httplogger.Middleware(),
+//	)
+//
+// The resolved address is stored in the request context. Other middleware, such as the logger
+// and rate limiters, should read it with ClientIP rather than parsing the headers themselves.
+package realip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------------------------
+// REAL IP
+// ------------------------------------------------------------------------------------------------
+
+// The supported proxy headers.
+const (
+	HeaderForwarded      = "Forwarded"
+	HeaderXForwardedFor  = "X-Forwarded-For"
+	HeaderXRealIP        = "X-Real-IP"
+	HeaderCFConnectingIP = "CF-Connecting-IP"
+)
+
+// DefaultHeaders are the headers that are checked, in order, when none are configured.
+// CF-Connecting-IP is not included, as it should only be trusted behind Cloudflare.
+var DefaultHeaders = []string{HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP}
+
+// Options configures the Resolver.
+type Options struct {
+	// TrustedProxies lists the addresses of trusted proxies, as CIDRs (10.0.0.0/8) or single
+	// addresses (127.0.0.1). Headers are ignored unless the request came from one of them.
+	TrustedProxies []string
+	// Headers lists the proxy headers to check, in order of preference. Only the first header that
+	// is present is used. Defaults to DefaultHeaders.
+	Headers []string
+}
+
+// Resolver resolves the client IP address of requests. It is safe for concurrent use.
+type Resolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+// clientIPKey is the context key for the resolved client IP address.
+type clientIPKey struct{}
+
+// New creates, initialises and returns a pointer to a new Resolver. An error is returned if any
+// of the trusted proxies cannot be parsed, or if an unsupported header is configured.
+func New(options Options) (*Resolver, error) {
+	res := &Resolver{headers: slices.Clone(options.Headers)}
+	if len(res.headers) == 0 {
+		res.headers = slices.Clone(DefaultHeaders)
+	}
+	for i, header := range res.headers {
+		switch canonical := http.CanonicalHeaderKey(header); canonical {
+		case HeaderForwarded, HeaderXForwardedFor, http.CanonicalHeaderKey(HeaderXRealIP),
+			http.CanonicalHeaderKey(HeaderCFConnectingIP):
+			res.headers[i] = canonical
+		default:
+			return nil, fmt.Errorf("unsupported real IP header %q", header)
+		}
+	}
+
+	for _, proxy := range options.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		res.trusted = append(res.trusted, prefix)
+	}
+	return res, nil
+}
+
+// Middleware creates and returns a middleware function that resolves the client IP address of
+// each request, and stores it in the request context. It panics if the options are invalid, as
+// that is a programming error.
+func Middleware(options Options) func(http.Handler) http.Handler {
+	res, err := New(options)
+	if err != nil {
+		panic(err)
+	}
+	return res.Middleware()
+}
+
+// Middleware returns a middleware function that resolves the client IP address of each request,
+// and stores it in the request context.
+func (res *Resolver) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := res.Resolve(r); ip.IsValid() {
+				r = r.WithContext(NewContext(r.Context(), ip))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Resolve returns the client IP address of the passed request. The proxy headers are only used if
+// the request came from a trusted proxy. The returned address is invalid if the remote address of
+// the request cannot be parsed.
+func (res *Resolver) Resolve(r *http.Request) netip.Addr {
+	remote := parseAddr(r.RemoteAddr)
+	if !remote.IsValid() || !res.trusts(remote) {
+		return remote
+	}
+
+	for _, header := range res.headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		var chain []string
+		switch header {
+		case HeaderForwarded:
+			chain = forwardedFor(values)
+		case HeaderXForwardedFor:
+			chain = splitList(values)
+		default:
+			// Single address headers are set by the trusted proxy itself, so the last value wins.
+			chain = []string{strings.TrimSpace(values[len(values)-1])}
+		}
+		return res.walk(chain, remote)
+	}
+	return remote
+}
+
+// walk returns the rightmost address in the chain that is not a trusted proxy. If an address
+// cannot be parsed, nothing to its left can be trusted, so the last trusted hop is returned. If
+// every address is trusted, the leftmost address is returned.
+func (res *Resolver) walk(chain []string, remote netip.Addr) netip.Addr {
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr := parseAddr(chain[i])
+		if !addr.IsValid() {
+			return client
+		}
+		client = addr
+		if !res.trusts(addr) {
+			return client
+		}
+	}
+	return client
+}
+
+// trusts reports whether the passed address belongs to a trusted proxy.
+func (res *Resolver) trusts(addr netip.Addr) bool {
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------------------------------------------------------------
+// CONTEXT
+// ------------------------------------------------------------------------------------------------
+
+// NewContext returns a copy of the passed context, holding the passed client IP address.
+func NewContext(ctx context.Context, ip netip.Addr) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// FromContext returns the client IP address held by the passed context, and whether there was
+// one.
+func FromContext(ctx context.Context) (netip.Addr, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(netip.Addr)
+	return ip, ok
+}
+
+// ClientIP returns the client IP address of the passed request as a string. It returns the address
+// resolved by the middleware if it has run, otherwise the remote address of the request, without
+// the port. Proxy headers are never used without the middleware, so the result cannot be spoofed.
+func ClientIP(r *http.Request) string {
+	if ip, ok := FromContext(r.Context()); ok {
+		return ip.String()
+	}
+	if ip := parseAddr(r.RemoteAddr); ip.IsValid() {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// ------------------------------------------------------------------------------------------------
+// PARSING
+// ------------------------------------------------------------------------------------------------
+
+// parsePrefix parses a CIDR, or a single address as a prefix containing only that address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// parseAddr parses an address that may include a port, and may be bracketed if it is IPv6. IPv4
+// mapped IPv6 addresses are unmapped, and zones are removed. The returned address is invalid if
+// it cannot be parsed.
+func parseAddr(s string) netip.Addr {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap().WithZone("")
+}
+
+// splitList splits comma separated header values into a single list.
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for item := range strings.SplitSeq(value, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+	}
+	return list
+}
+
+// forwardedFor returns the for parameters of the RFC 7239 Forwarded header values, in order.
+// Elements without a for parameter are returned as empty strings, so that they break the chain.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, element := range splitList(values) {
+		node := ""
+		for pair := range strings.SplitSeq(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				node = strings.Trim(value, `"`)
+				break
+			}
+		}
+		chain = append(chain, node)
+	}
+	return chain
+}
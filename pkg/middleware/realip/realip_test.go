@@ -0,0 +1,205 @@
+package realip
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// REAL IP TESTS
+// ------------------------------------------------------------------------------------------------
+
+// newTestRequest returns a request from the passed remote address, with the passed headers.
+func newTestRequest(remote string, headers map[string][]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remote
+	for key, values := range headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return req
+}
+
+// Test_Resolver_Resolve checks that proxy headers are only believed from trusted proxies, and that
+// the header chains are walked from right to left, skipping trusted hops.
+func Test_Resolver_Resolve(t *testing.T) {
+	res, err := New(Options{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		remote   string
+		headers  map[string][]string
+		expected string
+	}{
+		{"no headers", "203.0.113.9:1234", nil, "203.0.113.9"},
+		{
+			"headers from an untrusted client are ignored",
+			"203.0.113.9:1234",
+			map[string][]string{"X-Forwarded-For": {"1.1.1.1"}, "X-Real-Ip": {"1.1.1.1"}},
+			"203.0.113.9",
+		},
+		{
+			"trusted proxy without headers",
+			"10.0.0.1:1234",
+			nil,
+			"10.0.0.1",
+		},
+		{
+			"rightmost untrusted address is the client",
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"6.6.6.6, 198.51.100.7, 10.0.0.2"}},
+			"198.51.100.7",
+		},
+		{
+			"multiple header lines are combined",
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"6.6.6.6, 198.51.100.7", "192.0.2.1"}},
+			"198.51.100.7",
+		},
+		{
+			"every hop trusted returns the leftmost",
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			"10.0.0.3",
+		},
+		{
+			"unparseable hop stops the walk",
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7, garbage, 10.0.0.2"}},
+			"10.0.0.2",
+		},
+		{
+			"addresses with ports",
+			"10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7:5555"}},
+			"198.51.100.7",
+		},
+		{
+			"forwarded takes precedence",
+			"10.0.0.1:1234",
+			map[string][]string{
+				"Forwarded":       {`for=198.51.100.7;proto=https, for="[2001:db8::1]:443"`},
+				"X-Forwarded-For": {"6.6.6.6"},
+			},
+			"198.51.100.7",
+		},
+		{
+			"forwarded IPv6 client",
+			"10.0.0.1:1234",
+			map[string][]string{"Forwarded": {`for="[2001:db9::1]:80";by=10.0.0.1`}},
+			"2001:db9::1",
+		},
+		{
+			"forwarded obfuscated node stops the walk",
+			"10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=_hidden, for=10.0.0.2"}},
+			"10.0.0.2",
+		},
+		{
+			"forwarded element without for stops the walk",
+			"10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=198.51.100.7, proto=https"}},
+			"10.0.0.1",
+		},
+		{
+			"x-real-ip",
+			"10.0.0.1:1234",
+			map[string][]string{"X-Real-Ip": {"198.51.100.7"}},
+			"198.51.100.7",
+		},
+		{
+			"ipv4 mapped remote address",
+			"[::ffff:10.0.0.1]:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			"198.51.100.7",
+		},
+		{
+			"ipv6 trusted proxy",
+			"[2001:db8::5]:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			"198.51.100.7",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ip := res.Resolve(newTestRequest(test.remote, test.headers))
+			assert.Equal(t, test.expected, ip.String())
+		})
+	}
+}
+
+// Test_Resolver_headers checks that only the configured headers are used, in order.
+func Test_Resolver_headers(t *testing.T) {
+	res, err := New(Options{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Headers:        []string{"cf-connecting-ip", "x-forwarded-for"},
+	})
+	require.NoError(t, err)
+
+	req := newTestRequest("10.0.0.1:1234", map[string][]string{
+		"Cf-Connecting-Ip": {"198.51.100.7"},
+		"X-Forwarded-For":  {"6.6.6.6"},
+		"Forwarded":        {"for=5.5.5.5"},
+	})
+	assert.Equal(t, "198.51.100.7", res.Resolve(req).String())
+
+	req.Header.Del("Cf-Connecting-Ip")
+	assert.Equal(t, "6.6.6.6", res.Resolve(req).String())
+
+	// The defaults are not modified by canonicalising configured headers.
+	assert.Equal(t, []string{HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP}, DefaultHeaders)
+}
+
+// Test_New_errors checks that invalid options are reported.
+func Test_New_errors(t *testing.T) {
+	_, err := New(Options{TrustedProxies: []string{"10.0.0.0/33"}})
+	assert.ErrorContains(t, err, `invalid trusted proxy "10.0.0.0/33"`)
+
+	_, err = New(Options{TrustedProxies: []string{"proxy.local"}})
+	assert.ErrorContains(t, err, `invalid trusted proxy "proxy.local"`)
+
+	_, err = New(Options{Headers: []string{"X-Client-IP"}})
+	assert.ErrorContains(t, err, `unsupported real IP header "X-Client-IP"`)
+
+	assert.Panics(t, func() {
+		Middleware(Options{TrustedProxies: []string{"nope"}})
+	})
+}
+
+// Test_Middleware checks that the resolved address is stored in the context, and that ClientIP
+// falls back to the remote address without the middleware.
+func Test_Middleware(t *testing.T) {
+	var seen string
+	var found bool
+	h := Middleware(Options{TrustedProxies: []string{"10.0.0.0/8"}})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = ClientIP(r)
+			_, found = FromContext(r.Context())
+		}),
+	)
+
+	req := newTestRequest("10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4"}})
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "1.2.3.4", seen)
+	assert.True(t, found)
+
+	req = newTestRequest("@", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "@", seen)
+	assert.False(t, found)
+
+	req = newTestRequest("10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4"}})
+	assert.Equal(t, "10.0.0.1", ClientIP(req))
+
+	ctx := NewContext(context.Background(), netip.MustParseAddr("198.51.100.7"))
+	assert.Equal(t, "198.51.100.7", ClientIP(req.WithContext(ctx)))
+}
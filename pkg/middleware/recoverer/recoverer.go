@@ -1,20 +1,211 @@
+// Package recoverer provides middleware that recovers from panics in handlers, logs them with
+// their stack, reports them, and responds with a 500 error if nothing has been sent yet.
+//
+// The recoverer should be the first middleware added, so that it recovers panics from every
+// other middleware. Pass the app's 500 handler, so that recovered panics respond the same way as
+// other server errors.
+//
+//	app.Use(recoverer.Middleware(recoverer.Options{
+//		ErrorHandler: app.ErrorHandler(http.StatusInternalServerError),
+//	}))
 package recoverer
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"runtime/debug"
+
+	"github.com/felixge/httpsnoop"
 )
 
-func Middleware() func(http.Handler) http.Handler {
+// ------------------------------------------------------------------------------------------------
+// RECOVERER
+// ------------------------------------------------------------------------------------------------
+
+// Options configures the recoverer. The zero value logs panics through slog.Default(), and
+// responds with a plain text 500 error.
+type Options struct {
+	// Logger is the logger that panics are logged to. Defaults to slog.Default(), looked up for
+	// each panic, so that later changes to the default logger are respected.
+	Logger *slog.Logger
+	// Reporter is called with each recovered panic after it has been logged, such as to send it to
+	// an error tracking service. It is called before the response is written.
+	Reporter func(r *http.Request, p *Panic)
+	// ErrorHandler writes the response for a recovered panic, if nothing has been sent yet. The
+	// panic is available to it through FromContext. Defaults to a plain text 500 error.
+	ErrorHandler http.Handler
+	// Debug writes the panic value and stack as the response body instead of using the
+	// ErrorHandler. It must only be enabled in development, as the stack reveals internal details.
+	Debug bool
+}
+
+// Panic holds a recovered panic value and the stack of the goroutine that panicked.
+type Panic struct {
+	Value any
+	Stack []byte
+}
+
+// Error returns the panic value formatted as an error message.
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (p *Panic) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// bodyHeaders are the response headers that describe the body, and are removed before an error
+// response is written.
+var bodyHeaders = []string{
+	"Content-Length",
+	"Content-Type",
+	"Content-Encoding",
+	"Content-Disposition",
+	"ETag",
+	"Last-Modified",
+}
+
+// panicKey is the context key for the recovered panic.
+type panicKey struct{}
+
+// Middleware creates and returns a middleware function that recovers from panics. It accepts an
+// optional Options.
+//
+// http.ErrAbortHandler is re-panicked without being logged, as it is used to deliberately abort a
+// response. If the response had already started when the panic happened, the panic is logged and
+// reported, and then the response is aborted with http.ErrAbortHandler, so that the client sees
+// an incomplete response rather than one that looks successful.
+func Middleware(options ...Options) func(http.Handler) http.Handler {
+	var o Options
+	if len(options) > 0 {
+		o = options[0]
+	}
+	if o.ErrorHandler == nil {
+		o.ErrorHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+		})
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			defer func() {
-				if rvr := recover(); rvr != nil {
-					if r.Header.Get("Connection") != "Upgrade" {
-						w.WriteHeader(http.StatusInternalServerError)
+			started := false
+			tracked := httpsnoop.Wrap(w, httpsnoop.Hooks{
+				WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+					return func(code int) {
+						// Informational responses can be followed by the final response.
+						if code >= http.StatusOK || code == http.StatusSwitchingProtocols {
+							started = true
+						}
+						next(code)
+					}
+				},
+				Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+					return func(b []byte) (int, error) {
+						started = true
+						return next(b)
+					}
+				},
+				ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+					return func(src io.Reader) (int64, error) {
+						started = true
+						return next(src)
+					}
+				},
+				Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+					return func() {
+						started = true
+						next()
 					}
+				},
+				Hijack: func(next httpsnoop.HijackFunc) httpsnoop.HijackFunc {
+					return func() (net.Conn, *bufio.ReadWriter, error) {
+						started = true
+						return next()
+					}
+				},
+			})
+
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				if err, ok := rvr.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rvr)
+				}
+
+				p := &Panic{Value: rvr, Stack: debug.Stack()}
+				o.log(r, p)
+				if o.Reporter != nil {
+					o.Reporter(r, p)
+				}
+				if started {
+					panic(http.ErrAbortHandler)
+				}
+
+				// Headers set for the response that was abandoned don't describe the error response.
+				for _, key := range bodyHeaders {
+					w.Header().Del(key)
+				}
+				r = r.WithContext(NewContext(r.Context(), p))
+				if o.Debug {
+					writeDebug(w, p)
+					return
 				}
+				o.ErrorHandler.ServeHTTP(w, r)
 			}()
-			next.ServeHTTP(w, r)
+
+			next.ServeHTTP(tracked, r)
 		})
 	}
 }
+
+// log logs the passed panic, with the request details and stack.
+func (o *Options) log(r *http.Request, p *Panic) {
+	logger := o.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.LogAttrs(
+		r.Context(),
+		slog.LevelError,
+		"panic recovered",
+		slog.String("type", "panic"),
+		slog.String("error", fmt.Sprint(p.Value)),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.EscapedPath()),
+		slog.String("stack", string(p.Stack)),
+	)
+}
+
+// writeDebug writes the panic value and stack as a plain text 500 response.
+func writeDebug(w http.ResponseWriter, p *Panic) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = fmt.Fprintf(w, "%s\n\n%s", p.Error(), p.Stack)
+}
+
+// NewContext returns a copy of the passed context, holding the passed panic.
+func NewContext(ctx context.Context, p *Panic) context.Context {
+	return context.WithValue(ctx, panicKey{}, p)
+}
+
+// FromContext returns the recovered panic held by the passed context, and whether there was one.
+// It is intended for use by error handlers.
+func FromContext(ctx context.Context) (*Panic, bool) {
+	p, ok := ctx.Value(panicKey{}).(*Panic)
+	return p, ok
+}
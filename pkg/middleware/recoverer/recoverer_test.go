@@ -1,12 +1,18 @@
 package recoverer
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // ------------------------------------------------------------------------------------------------
@@ -79,3 +85,114 @@ func Test_Recoverer(t *testing.T) {
 		})
 	}
 }
+
+// newTestLogger returns a logger that writes JSON records to the returned buffer.
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	return slog.New(slog.NewJSONHandler(buf, nil)), buf
+}
+
+// Test_Recoverer_options checks that panics are logged with their stack, reported, and passed to
+// the error handler.
+func Test_Recoverer_options(t *testing.T) {
+	logger, buf := newTestLogger()
+	var reported *Panic
+	h := Middleware(Options{
+		Logger:   logger,
+		Reporter: func(r *http.Request, p *Panic) { reported = p },
+		ErrorHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := FromContext(r.Context())
+			require.True(t, ok)
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(w, `{"detail":%q}`, p.Error())
+		}),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Length", "1000")
+		w.Header().Set("X-Request-ID", "abc-123")
+		panic(errors.New("boom"))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/explode", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, `{"detail":"panic: boom"}`, w.Body.String())
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	assert.Equal(t, "abc-123", w.Header().Get("X-Request-ID"))
+
+	require.NotNil(t, reported)
+	assert.EqualError(t, reported, "panic: boom")
+	assert.EqualError(t, errors.Unwrap(reported), "boom")
+
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "panic recovered", record["msg"])
+	assert.Equal(t, "boom", record["error"])
+	assert.Equal(t, "/explode", record["path"])
+	assert.Contains(t, record["stack"], "recoverer_test.go")
+}
+
+// Test_Recoverer_debug checks that debug mode writes the panic and stack as the response body.
+func Test_Recoverer_debug(t *testing.T) {
+	logger, _ := newTestLogger()
+	h := Middleware(Options{Logger: logger, Debug: true})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("debug me") }),
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "panic: debug me\n\ngoroutine "))
+	assert.Contains(t, w.Body.String(), "recoverer_test.go")
+}
+
+// Test_Recoverer_started checks that a response that has already started is aborted rather than
+// written to again, and that http.ErrAbortHandler is re-panicked without being logged.
+func Test_Recoverer_started(t *testing.T) {
+	logger, buf := newTestLogger()
+	reported := 0
+	options := Options{Logger: logger, Reporter: func(r *http.Request, p *Panic) { reported++ }}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"header written", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("after header")
+		}},
+		{"body written", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("partial"))
+			panic("after body")
+		}},
+		{"flushed", func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+			panic("after flush")
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				Middleware(options)(test.handler).ServeHTTP(w, req)
+			})
+			assert.NotEqual(t, http.StatusInternalServerError, w.Code)
+		})
+	}
+	assert.Equal(t, len(tests), reported)
+
+	buf.Reset()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		Middleware(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+	assert.Empty(t, buf.String())
+	assert.Equal(t, len(tests), reported)
+}
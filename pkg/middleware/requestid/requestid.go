@@ -0,0 +1,197 @@
+// Package requestid provides middleware that tags every request with an ID, so that log records,
+// traces and downstream requests relating to the same request can be correlated.
+//
+// An incoming ID is accepted if it is valid, otherwise a new UUIDv7 is generated. The ID is echoed
+// on the response, and stored in the request context, where handlers can read it with FromContext
+// and forward it to outgoing requests with Inject.
+//
+//	app.Use(requestid.Middleware(requestid.Options{}), httplogger.Middleware())
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// REQUEST ID
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the middleware.
+const (
+	DefaultHeader    = "X-Request-ID"
+	DefaultMaxLength = 128
+)
+
+// Options configures the request ID middleware.
+type Options struct {
+	// Header is the request and response header that holds the ID. Defaults to DefaultHeader.
+	Header string
+	// MaxLength is the maximum length of an accepted incoming ID. Defaults to DefaultMaxLength.
+	MaxLength int
+	// Generator creates new IDs. Defaults to NewUUIDv7.
+	Generator func() string
+	// IgnoreIncoming always generates a new ID, such as when the service is exposed directly to
+	// untrusted clients.
+	IgnoreIncoming bool
+}
+
+// requestIDKey is the context key for the request ID.
+type requestIDKey struct{}
+
+// recordKey is the context key for the request ID record.
+type recordKey struct{}
+
+// record holds the request ID of a request, for middleware that runs before this middleware, such
+// as a logger. It is atomic, as the handler may still be running in another goroutine after a
+// timeout.
+type record struct {
+	id atomic.Pointer[string]
+}
+
+// Middleware creates and returns a middleware function that tags each request with an ID.
+func Middleware(options Options) func(http.Handler) http.Handler {
+	if options.Header == "" {
+		options.Header = DefaultHeader
+	}
+	if options.MaxLength <= 0 {
+		options.MaxLength = DefaultMaxLength
+	}
+	if options.Generator == nil {
+		options.Generator = NewUUIDv7
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := ""
+			if !options.IgnoreIncoming {
+				id = r.Header.Get(options.Header)
+			}
+			if !Valid(id, options.MaxLength) {
+				id = options.Generator()
+			}
+
+			w.Header().Set(options.Header, id)
+			if rec, ok := r.Context().Value(recordKey{}).(*record); ok {
+				rec.id.Store(&id)
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+		})
+	}
+}
+
+// Valid reports whether the passed ID is non-empty, no longer than maxLength, and only contains
+// letters, digits and the characters -_.:/+=, so that it is safe to log and to forward.
+func Valid(id string, maxLength int) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of the passed context, holding the passed request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request ID held by the passed context, or recorded in it with
+// WithRecord, or an empty string if there is none.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	if rec, ok := ctx.Value(recordKey{}).(*record); ok {
+		if id := rec.id.Load(); id != nil {
+			return *id
+		}
+	}
+	return ""
+}
+
+// WithRecord returns a copy of the passed context holding an empty record, which the middleware
+// fills in with the request ID. This allows middleware that runs before this middleware, such as
+// a logger, to read the ID with FromContext once the request has been handled. The passed context
+// is returned if it already holds a record.
+func WithRecord(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(recordKey{}).(*record); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, recordKey{}, &record{})
+}
+
+// Inject sets the named header on the passed header to the request ID held by the passed context,
+// so that the ID is forwarded to outgoing requests. The name defaults to DefaultHeader if empty,
+// and should match the Header option of the middleware. It does nothing if the context has no ID.
+func Inject(ctx context.Context, header http.Header, name string) {
+	if name == "" {
+		name = DefaultHeader
+	}
+	if id := FromContext(ctx); id != "" {
+		header.Set(name, id)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------
+// GENERATORS
+// ------------------------------------------------------------------------------------------------
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewUUIDv7 returns a new, time ordered, RFC 9562 version 7 UUID.
+func NewUUIDv7() string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// NewULID returns a new, time ordered, ULID.
+func NewULID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+
+	// A ULID is the 128 bit value encoded as 26 base32 characters, with the first character only
+	// holding the top 3 bits.
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+	var s [26]byte
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
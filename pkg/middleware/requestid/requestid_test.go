@@ -0,0 +1,136 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// REQUEST ID TESTS
+// ------------------------------------------------------------------------------------------------
+
+var (
+	uuidv7Pattern = regexp.MustCompile(
+		`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`,
+	)
+	ulidPattern = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+// serve runs the middleware with the passed options and incoming header value, and returns the
+// ID seen by the handler and the ID echoed on the response.
+func serve(options Options, header string, incoming string) (string, string) {
+	var seen string
+	h := Middleware(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if incoming != "" {
+		req.Header.Set(header, incoming)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return seen, w.Header().Get(header)
+}
+
+// Test_Middleware checks that valid incoming IDs are accepted, that invalid IDs are replaced, and
+// that the ID is echoed on the response.
+func Test_Middleware(t *testing.T) {
+	tests := []struct {
+		name             string
+		options          Options
+		incoming         string
+		expectedIncoming bool
+	}{
+		{"valid incoming ID", Options{}, "abc-123_DEF.4:5/6+7=", true},
+		{"missing incoming ID", Options{}, "", false},
+		{"invalid characters", Options{}, "abc 123", false},
+		{"log injection", Options{}, "abc\nlevel=ERROR", false},
+		{"too long", Options{}, strings.Repeat("a", DefaultMaxLength+1), false},
+		{"custom max length", Options{MaxLength: 4}, "abcde", false},
+		{"incoming ignored", Options{IgnoreIncoming: true}, "abc-123", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seen, echoed := serve(test.options, DefaultHeader, test.incoming)
+			assert.Equal(t, seen, echoed)
+			if test.expectedIncoming {
+				assert.Equal(t, test.incoming, seen)
+			} else {
+				assert.Regexp(t, uuidv7Pattern, seen)
+			}
+		})
+	}
+}
+
+// Test_Middleware_options checks that the header and generator can be customised.
+func Test_Middleware_options(t *testing.T) {
+	options := Options{Header: "X-Correlation-ID", Generator: func() string { return "generated" }}
+
+	seen, echoed := serve(options, "X-Correlation-ID", "")
+	assert.Equal(t, "generated", seen)
+	assert.Equal(t, "generated", echoed)
+
+	seen, _ = serve(options, "X-Correlation-ID", "incoming")
+	assert.Equal(t, "incoming", seen)
+}
+
+// Test_WithRecord checks that the ID is recorded for middleware that runs before the request ID
+// middleware, and that FromContext prefers the ID held by the context.
+func Test_WithRecord(t *testing.T) {
+	ctx := WithRecord(context.Background())
+	assert.Equal(t, ctx, WithRecord(ctx))
+	assert.Empty(t, FromContext(ctx))
+
+	var seen string
+	h := Middleware(Options{Header: "X-Correlation-ID"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = FromContext(r.Context())
+		}),
+	)
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("X-Correlation-ID", "abc-123")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "abc-123", seen)
+	assert.Equal(t, "abc-123", FromContext(ctx))
+
+	assert.Equal(t, "def-456", FromContext(NewContext(ctx, "def-456")))
+}
+
+// Test_Inject checks that the ID is forwarded to outgoing requests, under the default or the
+// passed header name.
+func Test_Inject(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header, "")
+	assert.Empty(t, header)
+
+	ctx := NewContext(context.Background(), "abc-123")
+	Inject(ctx, header, "")
+	assert.Equal(t, "abc-123", header.Get(DefaultHeader))
+
+	header = http.Header{}
+	Inject(ctx, header, "X-Correlation-ID")
+	assert.Equal(t, "abc-123", header.Get("X-Correlation-ID"))
+	assert.Empty(t, header.Get(DefaultHeader))
+}
+
+// Test_generators checks the format, uniqueness and ordering of the generated IDs.
+func Test_generators(t *testing.T) {
+	a, b := NewUUIDv7(), NewUUIDv7()
+	assert.Regexp(t, uuidv7Pattern, a)
+	assert.NotEqual(t, a, b)
+	assert.LessOrEqual(t, a[:13], b[:13])
+
+	c, d := NewULID(), NewULID()
+	assert.Regexp(t, ulidPattern, c)
+	assert.NotEqual(t, c, d)
+	assert.LessOrEqual(t, c[:10], d[:10])
+	assert.True(t, Valid(c, DefaultMaxLength))
+}
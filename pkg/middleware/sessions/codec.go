@@ -0,0 +1,75 @@
+package sessions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CODEC
+// ------------------------------------------------------------------------------------------------
+
+// MinKeySize is the minimum size of a session key, in bytes.
+const MinKeySize = 32
+
+// errInvalidCookie is returned when a cookie can't be opened with any of the keys.
+var errInvalidCookie = errors.New("invalid session cookie")
+
+// codec seals cookie values with AES-256-GCM, which both encrypts and authenticates them. Values
+// are sealed with the first key, and opened with any key, so that keys can be rotated.
+type codec struct {
+	aeads []cipher.AEAD
+}
+
+// newCodec creates, initialises and returns a pointer to a new codec, deriving an AES key from
+// each of the passed keys.
+func newCodec(keys [][]byte) (*codec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("sessions require at least one key")
+	}
+	c := &codec{}
+	for i, key := range keys {
+		if len(key) < MinKeySize {
+			return nil, fmt.Errorf("session key %d must be at least %d bytes", i, MinKeySize)
+		}
+		derived, err := hkdf.Key(sha256.New, key, nil, "rmhttp sessions", 32)
+		if err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(derived)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCMWithRandomNonce(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+// seal encrypts and authenticates the passed value with the first key. The cookie name is
+// authenticated too, so that a value can't be moved to a different cookie.
+func (c *codec) seal(name string, value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(c.aeads[0].Seal(nil, nil, value, []byte(name)))
+}
+
+// open decrypts and authenticates the passed cookie value, trying each key in turn.
+func (c *codec) open(name, value string) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCookie
+	}
+	for _, aead := range c.aeads {
+		if plain, err := aead.Open(nil, nil, data, []byte(name)); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, errInvalidCookie
+}
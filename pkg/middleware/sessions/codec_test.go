@@ -0,0 +1,72 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CODEC TESTS
+// ------------------------------------------------------------------------------------------------
+
+// testKey returns a valid key made of the passed byte.
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, MinKeySize)
+}
+
+// Test_codec checks that sealed values can be opened, and can't be opened for a different
+// cookie, after tampering, or with an unknown key.
+func Test_codec(t *testing.T) {
+	c, err := newCodec([][]byte{testKey(1)})
+	require.NoError(t, err)
+
+	sealed := c.seal("session", []byte("hello"))
+	assert.NotContains(t, sealed, "hello")
+	plain, err := c.open("session", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(plain))
+
+	_, err = c.open("other", sealed)
+	assert.ErrorIs(t, err, errInvalidCookie)
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)/2] ^= 1
+	_, err = c.open("session", string(tampered))
+	assert.ErrorIs(t, err, errInvalidCookie)
+
+	_, err = c.open("session", "not base64!")
+	assert.ErrorIs(t, err, errInvalidCookie)
+
+	other, err := newCodec([][]byte{testKey(2)})
+	require.NoError(t, err)
+	_, err = other.open("session", sealed)
+	assert.ErrorIs(t, err, errInvalidCookie)
+}
+
+// Test_codec_Rotation checks that values sealed with an old key are still accepted once a new
+// key has been added to the front.
+func Test_codec_Rotation(t *testing.T) {
+	old, err := newCodec([][]byte{testKey(1)})
+	require.NoError(t, err)
+	sealed := old.seal("session", []byte("hello"))
+
+	rotated, err := newCodec([][]byte{testKey(2), testKey(1)})
+	require.NoError(t, err)
+	plain, err := rotated.open("session", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(plain))
+
+	_, err = old.open("session", rotated.seal("session", []byte("hello")))
+	assert.ErrorIs(t, err, errInvalidCookie)
+}
+
+// Test_newCodec_Errors checks that missing and short keys are rejected.
+func Test_newCodec_Errors(t *testing.T) {
+	_, err := newCodec(nil)
+	assert.Error(t, err)
+	_, err = newCodec([][]byte{testKey(1), []byte("short")})
+	assert.ErrorContains(t, err, "session key 1")
+}
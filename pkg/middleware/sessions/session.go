@@ -0,0 +1,269 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// SESSION
+// ------------------------------------------------------------------------------------------------
+
+// ErrNoSession is returned when the context has no session, because the sessions middleware
+// hasn't run.
+var ErrNoSession = errors.New("no session in context")
+
+// Session is the session of a request. Its methods are safe for concurrent use. Changes are saved
+// when the response starts, so they must be made before the response is written.
+type Session struct {
+	mu   sync.Mutex
+	data sessionData
+
+	// isNew is true if the request had no valid session.
+	isNew bool
+	// dirty is true if the data has changed.
+	dirty bool
+	// regenerated is true if Regenerate was called, so the absolute expiry restarts.
+	regenerated bool
+	// previousID is the ID before Regenerate or Destroy, which is removed from the store.
+	previousID string
+	destroyed  bool
+	// committed is true once the session has been saved for the response.
+	committed bool
+}
+
+// sessionData is the encoded form of a session, held in the cookie or the store.
+type sessionData struct {
+	ID         string                     `json:"id"`
+	Created    int64                      `json:"created"`
+	LastActive int64                      `json:"active"`
+	Values     map[string]json.RawMessage `json:"values,omitempty"`
+	Flashes    []json.RawMessage          `json:"flashes,omitempty"`
+}
+
+// newSession returns a new, empty session.
+func newSession(now time.Time) *Session {
+	return &Session{
+		isNew: true,
+		data:  sessionData{ID: newID(), Created: now.Unix(), LastActive: now.Unix()},
+	}
+}
+
+// idLength is the length of session IDs.
+const idLength = 26
+
+// newID returns a new random session ID, with 130 bits of entropy.
+func newID() string {
+	return rand.Text()
+}
+
+// validID reports whether the passed ID could have been returned by newID.
+func validID(id string) bool {
+	if len(id) != idLength {
+		return false
+	}
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '2' || c > '7') {
+			return false
+		}
+	}
+	return true
+}
+
+// ID returns the session ID. It changes when the session is regenerated.
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.ID
+}
+
+// IsNew reports whether the session was created for this request.
+func (s *Session) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isNew
+}
+
+// Set stores the passed value under the passed key. The value is encoded as JSON, so it must be
+// a type that can be marshalled, and should be read back with the same type.
+func (s *Session) Set(key string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Values == nil {
+		s.data.Values = map[string]json.RawMessage{}
+	}
+	s.data.Values[key] = raw
+	s.dirty = true
+	return nil
+}
+
+// Delete removes the value under the passed key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.dirty = true
+	}
+}
+
+// value returns the raw value under the passed key.
+func (s *Session) value(key string) (json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	raw, ok := s.data.Values[key]
+	return raw, ok
+}
+
+// AddFlash adds a flash message, which is kept until it is read with Flashes, such as to show a
+// message on the page that a form redirects to.
+func (s *Session) AddFlash(message any) error {
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Flashes = append(s.data.Flashes, raw)
+	s.dirty = true
+	return nil
+}
+
+// flashes returns and removes the raw flash messages.
+func (s *Session) flashes() []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes := s.data.Flashes
+	if len(flashes) > 0 {
+		s.data.Flashes = nil
+		s.dirty = true
+	}
+	return flashes
+}
+
+// Regenerate gives the session a new ID, keeping its values, and restarts its absolute expiry.
+// Call it when the user's privileges change, such as when they log in, so that an attacker who
+// planted a session ID before login can't use it afterwards.
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.previousID == "" && !s.isNew {
+		s.previousID = s.data.ID
+	}
+	s.data.ID = newID()
+	s.regenerated = true
+	s.dirty = true
+}
+
+// Destroy removes the session's values, and deletes it from the client and store, such as when
+// the user logs out. Later changes in the same request start a new session.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.previousID == "" && !s.isNew {
+		s.previousID = s.data.ID
+	}
+	s.data = sessionData{ID: newID()}
+	s.dirty = false
+	s.destroyed = true
+}
+
+// ------------------------------------------------------------------------------------------------
+// CONTEXT
+// ------------------------------------------------------------------------------------------------
+
+// sessionKey is the context key for the session.
+type sessionKey struct{}
+
+// NewContext returns a copy of the passed context, holding the passed session.
+func NewContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// FromContext returns the session held by the passed context, or nil if there isn't one.
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// Get returns the value under the passed key in the session held by the passed context, decoded
+// as a T, and whether there was one that could be decoded.
+func Get[T any](ctx context.Context, key string) (T, bool) {
+	var value T
+	s := FromContext(ctx)
+	if s == nil {
+		return value, false
+	}
+	raw, ok := s.value(key)
+	if !ok || json.Unmarshal(raw, &value) != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// Set stores the passed value under the passed key in the session held by the passed context.
+func Set(ctx context.Context, key string, value any) error {
+	s := FromContext(ctx)
+	if s == nil {
+		return ErrNoSession
+	}
+	return s.Set(key, value)
+}
+
+// Delete removes the value under the passed key from the session held by the passed context.
+func Delete(ctx context.Context, key string) {
+	if s := FromContext(ctx); s != nil {
+		s.Delete(key)
+	}
+}
+
+// AddFlash adds a flash message to the session held by the passed context.
+func AddFlash(ctx context.Context, message any) error {
+	s := FromContext(ctx)
+	if s == nil {
+		return ErrNoSession
+	}
+	return s.AddFlash(message)
+}
+
+// Flashes returns and removes the flash messages in the session held by the passed context,
+// decoded as Ts. Messages that can't be decoded as a T are dropped.
+func Flashes[T any](ctx context.Context) []T {
+	s := FromContext(ctx)
+	if s == nil {
+		return nil
+	}
+	var messages []T
+	for _, raw := range s.flashes() {
+		var message T
+		if json.Unmarshal(raw, &message) == nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Regenerate gives the session held by the passed context a new ID.
+func Regenerate(ctx context.Context) error {
+	s := FromContext(ctx)
+	if s == nil {
+		return ErrNoSession
+	}
+	s.Regenerate()
+	return nil
+}
+
+// Destroy destroys the session held by the passed context.
+func Destroy(ctx context.Context) {
+	if s := FromContext(ctx); s != nil {
+		s.Destroy()
+	}
+}
@@ -0,0 +1,111 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// SESSION TESTS
+// ------------------------------------------------------------------------------------------------
+
+// testContext returns a context holding a new session.
+func testContext() (context.Context, *Session) {
+	s := newSession(time.Now())
+	return NewContext(context.Background(), s), s
+}
+
+// Test_Get checks that values are read back with their type, and that missing values and values
+// of the wrong type are reported.
+func Test_Get(t *testing.T) {
+	type user struct {
+		ID   int64
+		Name string
+	}
+	ctx, s := testContext()
+	require.NoError(t, Set(ctx, "id", int64(42)))
+	require.NoError(t, Set(ctx, "user", user{ID: 1, Name: "alice"}))
+	assert.True(t, s.dirty)
+
+	id, ok := Get[int64](ctx, "id")
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), id)
+
+	u, ok := Get[user](ctx, "user")
+	assert.True(t, ok)
+	assert.Equal(t, user{ID: 1, Name: "alice"}, u)
+
+	_, ok = Get[string](ctx, "id")
+	assert.False(t, ok)
+	_, ok = Get[string](ctx, "missing")
+	assert.False(t, ok)
+
+	Delete(ctx, "id")
+	_, ok = Get[int64](ctx, "id")
+	assert.False(t, ok)
+
+	assert.Error(t, Set(ctx, "bad", func() {}))
+}
+
+// Test_Flashes checks that flash messages are returned once.
+func Test_Flashes(t *testing.T) {
+	ctx, s := testContext()
+	require.NoError(t, AddFlash(ctx, "saved"))
+	require.NoError(t, AddFlash(ctx, "emailed"))
+
+	s.dirty = false
+	assert.Equal(t, []string{"saved", "emailed"}, Flashes[string](ctx))
+	assert.True(t, s.dirty, "reading flashes should change the session")
+	assert.Empty(t, Flashes[string](ctx))
+}
+
+// Test_Regenerate checks that regenerating a session changes its ID and keeps its values.
+func Test_Regenerate(t *testing.T) {
+	ctx, s := testContext()
+	s.isNew = false
+	require.NoError(t, Set(ctx, "id", 42))
+	id := s.ID()
+
+	require.NoError(t, Regenerate(ctx))
+	require.NoError(t, Regenerate(ctx))
+	assert.NotEqual(t, id, s.ID())
+	assert.True(t, validID(s.ID()))
+	assert.Equal(t, id, s.previousID)
+	value, ok := Get[int](ctx, "id")
+	assert.True(t, ok)
+	assert.Equal(t, 42, value)
+}
+
+// Test_Destroy checks that destroying a session removes its values and changes its ID.
+func Test_Destroy(t *testing.T) {
+	ctx, s := testContext()
+	s.isNew = false
+	require.NoError(t, Set(ctx, "id", 42))
+	id := s.ID()
+
+	Destroy(ctx)
+	assert.NotEqual(t, id, s.ID())
+	assert.Equal(t, id, s.previousID)
+	assert.True(t, s.destroyed)
+	assert.False(t, s.dirty)
+	_, ok := Get[int](ctx, "id")
+	assert.False(t, ok)
+}
+
+// Test_NoSession checks that the context functions handle a context without a session.
+func Test_NoSession(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, FromContext(ctx))
+	_, ok := Get[int](ctx, "id")
+	assert.False(t, ok)
+	assert.ErrorIs(t, Set(ctx, "id", 1), ErrNoSession)
+	assert.ErrorIs(t, AddFlash(ctx, "x"), ErrNoSession)
+	assert.ErrorIs(t, Regenerate(ctx), ErrNoSession)
+	assert.Nil(t, Flashes[string](ctx))
+	Delete(ctx, "id")
+	Destroy(ctx)
+}
@@ -0,0 +1,278 @@
+// Package sessions provides middleware that gives each request a session, held in an encrypted
+// cookie or in a server side Store.
+//
+// Cookies are encrypted and authenticated with AES-GCM, using keys derived from the configured
+// keys. New cookies use the first key, and cookies from any key are accepted, so keys can be
+// rotated by adding a new key to the front, and removing old keys once their cookies have
+// expired. Sessions expire after a period of inactivity, and after an absolute lifetime.
+//
+//	manager, err := sessions.New(sessions.Options{Keys: [][]byte{key}})
+//	app.Use(manager.Middleware())
+//
+//	app.Post("/login", func(w http.ResponseWriter, r *http.Request) {
+//		// ...
+//		sessions.Regenerate(r.Context())
+//		sessions.Set(r.Context(), "user_id", user.ID)
+//	})
+//	app.Get("/account", func(w http.ResponseWriter, r *http.Request) {
+//		userID, ok := sessions.Get[int64](r.Context(), "user_id")
+//		// ...
+//	})
+package sessions
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+)
+
+// ------------------------------------------------------------------------------------------------
+// SESSIONS
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the middleware.
+const (
+	DefaultCookieName      = "session"
+	DefaultIdleTimeout     = 30 * time.Minute
+	DefaultAbsoluteTimeout = 24 * time.Hour
+)
+
+// maxCookieSize is the largest cookie that browsers are guaranteed to accept.
+const maxCookieSize = 4096
+
+// Options configures the sessions middleware.
+type Options struct {
+	// Keys encrypt and authenticate the cookies. Each key must be at least MinKeySize random
+	// bytes. New cookies use the first key, and cookies from any key are accepted.
+	Keys [][]byte
+	// Store holds the session data on the server, so that the cookie only holds the session ID.
+	// If it is nil, the session data is held in the cookie, which limits it to about 3KB.
+	Store Store
+	// CookieName is the name of the session cookie. Defaults to DefaultCookieName.
+	CookieName string
+	// Path is the path of the session cookie. Defaults to "/".
+	Path string
+	// Domain is the domain of the session cookie. Defaults to the host of the request.
+	Domain string
+	// Insecure allows the cookie to be sent over plain HTTP, such as for local development.
+	Insecure bool
+	// SameSite is the SameSite attribute of the cookie. Defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// IdleTimeout is how long a session lasts without a request. Defaults to DefaultIdleTimeout.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout is how long a session lasts after it is created or regenerated, however
+	// active it is. Defaults to DefaultAbsoluteTimeout.
+	AbsoluteTimeout time.Duration
+}
+
+// Manager loads and saves sessions.
+type Manager struct {
+	options Options
+	codec   *codec
+	now     func() time.Time
+}
+
+// New creates, initialises and returns a pointer to a new Manager. An error is returned if there
+// are no keys, or a key is too short.
+func New(options Options) (*Manager, error) {
+	c, err := newCodec(options.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if options.CookieName == "" {
+		options.CookieName = DefaultCookieName
+	}
+	if options.Path == "" {
+		options.Path = "/"
+	}
+	if options.SameSite == 0 {
+		options.SameSite = http.SameSiteLaxMode
+	}
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = DefaultIdleTimeout
+	}
+	if options.AbsoluteTimeout <= 0 {
+		options.AbsoluteTimeout = DefaultAbsoluteTimeout
+	}
+	return &Manager{options: options, codec: c, now: time.Now}, nil
+}
+
+// Middleware returns a middleware function that loads the session for each request into the
+// request context, and saves it when the response is written. A new session is only saved if it
+// has been changed, so visitors that never use the session don't get a cookie.
+func (m *Manager) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := m.load(r)
+			commit := func() { m.commit(w, r, s) }
+			tracked := httpsnoop.Wrap(w, httpsnoop.Hooks{
+				WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+					return func(code int) {
+						// Informational responses are sent before the session is final.
+						if code >= http.StatusOK || code == http.StatusSwitchingProtocols {
+							commit()
+						}
+						next(code)
+					}
+				},
+				Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+					return func(b []byte) (int, error) {
+						commit()
+						return next(b)
+					}
+				},
+				ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+					return func(src io.Reader) (int64, error) {
+						commit()
+						return next(src)
+					}
+				},
+				Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+					return func() {
+						commit()
+						next()
+					}
+				},
+				Hijack: func(next httpsnoop.HijackFunc) httpsnoop.HijackFunc {
+					return func() (net.Conn, *bufio.ReadWriter, error) {
+						commit()
+						return next()
+					}
+				},
+			})
+
+			next.ServeHTTP(tracked, r.WithContext(NewContext(r.Context(), s)))
+			commit()
+		})
+	}
+}
+
+// load returns the session for the passed request, or a new session if it has none, or it is
+// invalid or has expired.
+func (m *Manager) load(r *http.Request) *Session {
+	now := m.now()
+	cookie, err := r.Cookie(m.options.CookieName)
+	if err != nil {
+		return newSession(now)
+	}
+	plain, err := m.codec.open(m.options.CookieName, cookie.Value)
+	if err != nil {
+		return newSession(now)
+	}
+
+	id := ""
+	if m.options.Store != nil {
+		id = string(plain)
+		plain, err = m.options.Store.Load(r.Context(), id)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				slog.Error("failed to load session", "type", "session", "error", err)
+			}
+			return newSession(now)
+		}
+	}
+
+	var data sessionData
+	if err := json.Unmarshal(plain, &data); err != nil || (id != "" && data.ID != id) {
+		return newSession(now)
+	}
+	if !now.Before(m.expires(&data)) {
+		if id != "" {
+			_ = m.options.Store.Delete(r.Context(), id)
+		}
+		return newSession(now)
+	}
+	return &Session{data: data}
+}
+
+// expires returns the time that the passed session expires, which is the earlier of its idle and
+// absolute expiry times.
+func (m *Manager) expires(data *sessionData) time.Time {
+	idle := time.Unix(data.LastActive, 0).Add(m.options.IdleTimeout)
+	absolute := time.Unix(data.Created, 0).Add(m.options.AbsoluteTimeout)
+	if idle.Before(absolute) {
+		return idle
+	}
+	return absolute
+}
+
+// commit saves the passed session and sets the session cookie, if the session has changed, or
+// its idle expiry needs extending. It only runs once per request.
+func (m *Manager) commit(w http.ResponseWriter, r *http.Request, s *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.committed {
+		return
+	}
+	s.committed = true
+
+	ctx := r.Context()
+	store := m.options.Store
+	if s.previousID != "" && store != nil {
+		if err := store.Delete(ctx, s.previousID); err != nil {
+			slog.Error("failed to delete session", "type", "session", "error", err)
+		}
+	}
+	if s.destroyed && !s.dirty {
+		if !s.isNew {
+			cookie := m.cookie("", time.Time{})
+			cookie.MaxAge = -1
+			http.SetCookie(w, cookie)
+		}
+		return
+	}
+
+	// Extending the idle expiry rewrites the cookie, so it is only done once a minute.
+	now := m.now()
+	touch := min(time.Minute, m.options.IdleTimeout/2)
+	if !s.dirty && (s.isNew || now.Sub(time.Unix(s.data.LastActive, 0)) < touch) {
+		return
+	}
+	if s.regenerated || s.data.Created == 0 {
+		s.data.Created = now.Unix()
+	}
+	s.data.LastActive = now.Unix()
+	expires := m.expires(&s.data)
+
+	data, err := json.Marshal(s.data)
+	if err == nil && store != nil {
+		err = store.Save(ctx, s.data.ID, data, expires)
+		data = []byte(s.data.ID)
+	}
+	if err != nil {
+		slog.Error("failed to save session", "type", "session", "error", err)
+		return
+	}
+
+	cookie := m.cookie(m.codec.seal(m.options.CookieName, data), expires)
+	if v := cookie.String(); len(v) > maxCookieSize {
+		slog.Error(
+			"session cookie is too large, use a store instead",
+			"type", "session",
+			"size", len(v),
+		)
+		return
+	}
+	http.SetCookie(w, cookie)
+}
+
+// cookie returns the session cookie with the passed value and expiry time.
+func (m *Manager) cookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.options.CookieName,
+		Value:    value,
+		Path:     m.options.Path,
+		Domain:   m.options.Domain,
+		Expires:  expires,
+		Secure:   !m.options.Insecure,
+		HttpOnly: true,
+		SameSite: m.options.SameSite,
+	}
+}
@@ -0,0 +1,233 @@
+package sessions
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// SESSIONS TESTS
+// ------------------------------------------------------------------------------------------------
+
+// testManager returns a Manager with the passed options and a test key, and a pointer to the
+// time that it uses.
+func testManager(t *testing.T, options Options) (*Manager, *time.Time) {
+	t.Helper()
+	options.Keys = [][]byte{testKey(1)}
+	m, err := New(options)
+	require.NoError(t, err)
+	now := time.Unix(1_000_000, 0)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+// serveSession runs a request with the passed session cookie through the passed handler, with
+// the passed manager's middleware, and returns the session cookie that was set, if any.
+func serveSession(
+	m *Manager,
+	cookie *http.Cookie,
+	h http.HandlerFunc,
+) (*httptest.ResponseRecorder, *http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	m.Middleware()(h).ServeHTTP(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == m.options.CookieName {
+			return w, c
+		}
+	}
+	return w, nil
+}
+
+// setHandler sets the user_id value in the session.
+func setHandler(w http.ResponseWriter, r *http.Request) {
+	_ = Set(r.Context(), "user_id", 42)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// getHandler writes the user_id value in the session.
+func getHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := Get[int](r.Context(), "user_id")
+	_, _ = w.Write([]byte(strings.Repeat("x", id)))
+}
+
+// Test_Middleware checks that a session is saved in a cookie with secure attributes, and loaded
+// from it on the next request, and that unused new sessions don't get a cookie.
+func Test_Middleware(t *testing.T) {
+	m, _ := testManager(t, Options{})
+
+	_, cookie := serveSession(m, nil, getHandler)
+	assert.Nil(t, cookie, "an unused session should not set a cookie")
+
+	w, cookie := serveSession(m, nil, setHandler)
+	assert.Equal(t, "ok", w.Body.String())
+	require.NotNil(t, cookie)
+	assert.Equal(t, DefaultCookieName, cookie.Name)
+	assert.Equal(t, "/", cookie.Path)
+	assert.True(t, cookie.Secure)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	assert.NotContains(t, cookie.Value, "user_id")
+
+	w, next := serveSession(m, cookie, getHandler)
+	assert.Equal(t, 42, w.Body.Len())
+	assert.Nil(t, next, "an unchanged session should not be saved again")
+
+	cookie.Value = "invalid"
+	w, _ = serveSession(m, cookie, getHandler)
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+// Test_Middleware_Options checks that the cookie options are applied.
+func Test_Middleware_Options(t *testing.T) {
+	m, _ := testManager(t, Options{
+		CookieName: "sid",
+		Path:       "/app",
+		Domain:     "example.com",
+		Insecure:   true,
+		SameSite:   http.SameSiteStrictMode,
+	})
+	_, cookie := serveSession(m, nil, setHandler)
+	require.NotNil(t, cookie)
+	assert.Equal(t, "sid", cookie.Name)
+	assert.Equal(t, "/app", cookie.Path)
+	assert.Equal(t, "example.com", cookie.Domain)
+	assert.False(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+}
+
+// Test_Middleware_Expiry checks that sessions expire after the idle timeout, that requests
+// extend the idle expiry, and that sessions expire after the absolute timeout regardless.
+func Test_Middleware_Expiry(t *testing.T) {
+	m, now := testManager(t, Options{
+		IdleTimeout:     10 * time.Minute,
+		AbsoluteTimeout: time.Hour,
+	})
+	_, cookie := serveSession(m, nil, setHandler)
+	require.NotNil(t, cookie)
+	assert.Equal(t, now.Add(10*time.Minute).Unix(), cookie.Expires.Unix())
+
+	*now = now.Add(11 * time.Minute)
+	w, _ := serveSession(m, cookie, getHandler)
+	assert.Equal(t, 0, w.Body.Len(), "the session should have expired while idle")
+
+	_, cookie = serveSession(m, nil, setHandler)
+	require.NotNil(t, cookie)
+	for range 6 {
+		*now = now.Add(9 * time.Minute)
+		w, next := serveSession(m, cookie, getHandler)
+		require.Equal(t, 42, w.Body.Len(), "activity should extend the session")
+		require.NotNil(t, next)
+		cookie = next
+	}
+
+	*now = now.Add(9 * time.Minute)
+	w, _ = serveSession(m, cookie, getHandler)
+	assert.Equal(t, 0, w.Body.Len(), "the session should have reached its absolute expiry")
+}
+
+// Test_Middleware_Store checks that the session data is held in the store, that regenerating
+// the session removes the old ID, and that destroying it expires the cookie.
+func Test_Middleware_Store(t *testing.T) {
+	store := NewMemoryStore()
+	m, _ := testManager(t, Options{Store: store})
+	store.now = m.now
+
+	_, cookie := serveSession(m, nil, setHandler)
+	require.NotNil(t, cookie)
+	assert.Equal(t, 1, store.Len())
+
+	var oldID string
+	w, next := serveSession(m, cookie, func(w http.ResponseWriter, r *http.Request) {
+		oldID = FromContext(r.Context()).ID()
+		_ = Regenerate(r.Context())
+		getHandler(w, r)
+	})
+	assert.Equal(t, 42, w.Body.Len())
+	require.NotNil(t, next)
+	assert.Equal(t, 1, store.Len())
+	_, err := store.Load(t.Context(), oldID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	w, _ = serveSession(m, cookie, getHandler)
+	assert.Equal(t, 0, w.Body.Len(), "the old session ID should no longer work")
+
+	_, cookie = serveSession(m, next, func(w http.ResponseWriter, r *http.Request) {
+		Destroy(r.Context())
+	})
+	require.NotNil(t, cookie)
+	assert.Equal(t, -1, cookie.MaxAge)
+	assert.Equal(t, 0, store.Len())
+}
+
+// Test_Middleware_Destroy checks that destroying a cookie session expires the cookie, and that
+// values set after destroying it start a new session.
+func Test_Middleware_Destroy(t *testing.T) {
+	m, _ := testManager(t, Options{})
+	_, cookie := serveSession(m, nil, setHandler)
+	require.NotNil(t, cookie)
+
+	_, expired := serveSession(m, cookie, func(w http.ResponseWriter, r *http.Request) {
+		Destroy(r.Context())
+	})
+	require.NotNil(t, expired)
+	assert.Equal(t, -1, expired.MaxAge)
+
+	_, next := serveSession(m, cookie, func(w http.ResponseWriter, r *http.Request) {
+		Destroy(r.Context())
+		_ = Set(r.Context(), "other", true)
+	})
+	require.NotNil(t, next)
+	assert.NotEqual(t, -1, next.MaxAge)
+	w, _ := serveSession(m, next, getHandler)
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+// Test_Middleware_WriteHeader checks that the session is saved before the headers are written,
+// so that the cookie is sent.
+func Test_Middleware_WriteHeader(t *testing.T) {
+	m, _ := testManager(t, Options{})
+	_, cookie := serveSession(m, nil, func(w http.ResponseWriter, r *http.Request) {
+		_ = Set(r.Context(), "user_id", 1)
+		w.WriteHeader(http.StatusCreated)
+		_ = Set(r.Context(), "user_id", 2)
+	})
+	require.NotNil(t, cookie)
+	w, _ := serveSession(m, cookie, getHandler)
+	assert.Equal(t, 1, w.Body.Len())
+}
+
+// Test_Middleware_TooLarge checks that a session that is too large for a cookie is logged and
+// not saved.
+func Test_Middleware_TooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	m, _ := testManager(t, Options{})
+	_, cookie := serveSession(m, nil, func(w http.ResponseWriter, r *http.Request) {
+		_ = Set(r.Context(), "big", strings.Repeat("x", maxCookieSize))
+	})
+	assert.Nil(t, cookie)
+	assert.Contains(t, buf.String(), "session cookie is too large")
+}
+
+// Test_New checks that the manager requires valid keys.
+func Test_New(t *testing.T) {
+	_, err := New(Options{})
+	assert.Error(t, err)
+	_, err = New(Options{Keys: [][]byte{[]byte("short")}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,251 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// STORES
+// ------------------------------------------------------------------------------------------------
+
+// sweepInterval is how often the stores remove expired sessions.
+const sweepInterval = time.Minute
+
+// ErrNotFound is returned by a Store when there is no session with the passed ID, or it has
+// expired.
+var ErrNotFound = errors.New("session not found")
+
+// Store holds session data on the server, so that the cookie only holds the session ID. Stores
+// must be safe for concurrent use.
+type Store interface {
+	// Load returns the data of the session with the passed ID, or ErrNotFound.
+	Load(ctx context.Context, id string) ([]byte, error)
+	// Save stores the data of the session with the passed ID, until the passed expiry time.
+	Save(ctx context.Context, id string, data []byte, expires time.Time) error
+	// Delete removes the session with the passed ID. Deleting a missing session is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is a Store that holds sessions in memory, removing them once they expire. Sessions
+// are lost when the process exits, and aren't shared between processes.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	swept    time.Time
+	now      func() time.Time
+}
+
+// memorySession is a session held by a MemoryStore.
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates, initialises and returns a pointer to a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]memorySession{}, now: time.Now}
+}
+
+// Load returns the data of the session with the passed ID.
+func (s *MemoryStore) Load(_ context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || !s.now().Before(session.expires) {
+		return nil, ErrNotFound
+	}
+	return session.data, nil
+}
+
+// Save stores the data of the session with the passed ID.
+func (s *MemoryStore) Save(_ context.Context, id string, data []byte, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.swept) >= sweepInterval {
+		s.swept = now
+		for id, session := range s.sessions {
+			if !now.Before(session.expires) {
+				delete(s.sessions, id)
+			}
+		}
+	}
+	s.sessions[id] = memorySession{data: data, expires: expires}
+	return nil
+}
+
+// Delete removes the session with the passed ID.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// Len returns the number of sessions held, including any that have expired but haven't been
+// removed yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// FileStore is a Store that holds each session in a file in a directory, so that sessions
+// survive restarts. Expired session files are removed periodically.
+type FileStore struct {
+	dir string
+	now func() time.Time
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+// fileSession is the content of a FileStore session file.
+type fileSession struct {
+	Expires time.Time `json:"expires"`
+	Data    []byte    `json:"data"`
+}
+
+// Name parts of FileStore files.
+const (
+	// sessionFileExt is the extension of session files.
+	sessionFileExt = ".session"
+	// tempFilePrefix is the prefix of the temporary files that sessions are written to, before
+	// they are renamed.
+	tempFilePrefix = ".tmp-"
+)
+
+// NewFileStore creates, initialises and returns a pointer to a new FileStore, creating the passed
+// directory if it doesn't exist. Session files are only readable by the current user.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, now: time.Now}, nil
+}
+
+// Load returns the data of the session with the passed ID.
+func (s *FileStore) Load(_ context.Context, id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path) // #nosec G304 - path is validated
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session fileSession
+	if err := json.Unmarshal(content, &session); err != nil {
+		return nil, fmt.Errorf("reading session file: %w", err)
+	}
+	if !s.now().Before(session.Expires) {
+		return nil, ErrNotFound
+	}
+	return session.Data, nil
+}
+
+// Save stores the data of the session with the passed ID. The file is written to a temporary file
+// and renamed, so that a concurrent Load never sees a partial file.
+func (s *FileStore) Save(_ context.Context, id string, data []byte, expires time.Time) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(fileSession{Expires: expires, Data: data})
+	if err != nil {
+		return err
+	}
+	s.sweep()
+
+	tmp, err := os.CreateTemp(s.dir, tempFilePrefix+"*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Delete removes the session with the passed ID.
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns the path of the file for the passed session ID. IDs are checked, so that they
+// can't refer to files outside the directory.
+func (s *FileStore) path(id string) (string, error) {
+	if !validID(id) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, id+sessionFileExt), nil
+}
+
+// sweep removes expired and unreadable session files, and temporary files left behind by a
+// process that exited while saving a session, at most once per sweep interval.
+func (s *FileStore) sweep() {
+	s.mu.Lock()
+	now := s.now()
+	if now.Sub(s.swept) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.swept = now
+	s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(s.dir, entry.Name())
+		switch {
+		case strings.HasPrefix(entry.Name(), tempFilePrefix):
+			info, err := entry.Info()
+			if err == nil && now.Sub(info.ModTime()) >= sweepInterval {
+				_ = os.Remove(path)
+			}
+		case strings.HasSuffix(entry.Name(), sessionFileExt):
+			if s.stale(path, now) {
+				_ = os.Remove(path)
+			}
+		}
+	}
+}
+
+// stale reports whether the session file at the passed path has expired, or can't be parsed, such
+// as after being truncated. Files that can't be read are kept, as the error may be temporary.
+func (s *FileStore) stale(path string, now time.Time) bool {
+	content, err := os.ReadFile(path) // #nosec G304 - path is within the store directory
+	if err != nil {
+		return false
+	}
+	var session fileSession
+	if err := json.Unmarshal(content, &session); err != nil {
+		return true
+	}
+	return !now.Before(session.Expires)
+}
@@ -0,0 +1,134 @@
+package sessions
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// STORE TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_MemoryStore checks that sessions can be saved, loaded and deleted, expire, and are
+// removed once expired.
+func Test_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_000_000, 0)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Save(ctx, "a", []byte("alpha"), now.Add(time.Minute)))
+	require.NoError(t, s.Save(ctx, "b", []byte("beta"), now.Add(time.Hour)))
+	data, err := s.Load(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "alpha", string(data))
+
+	_, err = s.Load(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	now = now.Add(2 * time.Minute)
+	_, err = s.Load(ctx, "a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, s.Len())
+
+	require.NoError(t, s.Save(ctx, "c", []byte("gamma"), now.Add(time.Hour)))
+	assert.Equal(t, 2, s.Len(), "the expired session should be swept")
+
+	require.NoError(t, s.Delete(ctx, "b"))
+	require.NoError(t, s.Delete(ctx, "b"))
+	_, err = s.Load(ctx, "b")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// Test_FileStore checks that sessions can be saved, loaded and deleted, expire, and are
+// removed once expired.
+func Test_FileStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_000_000, 0)
+	dir := filepath.Join(t.TempDir(), "sessions")
+	s, err := NewFileStore(dir)
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+
+	a, b := newID(), newID()
+	require.NoError(t, s.Save(ctx, a, []byte("alpha"), now.Add(time.Minute)))
+	require.NoError(t, s.Save(ctx, b, []byte("beta"), now.Add(time.Hour)))
+	data, err := s.Load(ctx, a)
+	require.NoError(t, err)
+	assert.Equal(t, "alpha", string(data))
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	_, err = s.Load(ctx, newID())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	now = now.Add(2 * time.Minute)
+	_, err = s.Load(ctx, a)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Save(ctx, newID(), []byte("gamma"), now.Add(time.Hour)))
+	_, err = os.Stat(filepath.Join(dir, a+sessionFileExt))
+	assert.ErrorIs(t, err, os.ErrNotExist, "the expired session should be swept")
+
+	require.NoError(t, s.Delete(ctx, b))
+	require.NoError(t, s.Delete(ctx, b))
+	_, err = s.Load(ctx, b)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// Test_FileStore_sweep checks that session files that can't be parsed, and temporary files that
+// are older than the sweep interval, are removed.
+func Test_FileStore_sweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+
+	corrupt := filepath.Join(dir, newID()+sessionFileExt)
+	require.NoError(t, os.WriteFile(corrupt, []byte(`{"expires":`), 0o600))
+	oldTemp := filepath.Join(dir, tempFilePrefix+"old")
+	require.NoError(t, os.WriteFile(oldTemp, []byte("{}"), 0o600))
+	old := now.Add(-2 * sweepInterval)
+	require.NoError(t, os.Chtimes(oldTemp, old, old))
+	newTemp := filepath.Join(dir, tempFilePrefix+"new")
+	require.NoError(t, os.WriteFile(newTemp, []byte("{}"), 0o600))
+
+	live := newID()
+	require.NoError(t, s.Save(ctx, live, []byte("alpha"), now.Add(time.Hour)))
+
+	for _, path := range []string{corrupt, oldTemp} {
+		_, err = os.Stat(path)
+		assert.ErrorIs(t, err, os.ErrNotExist, path)
+	}
+	_, err = os.Stat(newTemp)
+	assert.NoError(t, err, "a temporary file that may still be in use should be kept")
+	data, err := s.Load(ctx, live)
+	require.NoError(t, err)
+	assert.Equal(t, "alpha", string(data))
+}
+
+// Test_FileStore_InvalidID checks that IDs that could refer to files outside the directory are
+// rejected.
+func Test_FileStore_InvalidID(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, id := range []string{"", "../../etc/passwd", "short", newID() + "/x"} {
+		err := s.Save(ctx, id, []byte("x"), time.Now().Add(time.Hour))
+		assert.ErrorIs(t, err, ErrNotFound, id)
+		_, err = s.Load(ctx, id)
+		assert.ErrorIs(t, err, ErrNotFound, id)
+		assert.NoError(t, s.Delete(ctx, id), id)
+	}
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// ------------------------------------------------------------------------------------------------
+// EXPORTERS
+// ------------------------------------------------------------------------------------------------
+
+// An Exporter sends ended spans to a tracing backend. The Tracer calls ExportSpans from a single
+// goroutine, with batches of spans, so implementations do not need to handle concurrent exports.
+type Exporter interface {
+	// ExportSpans exports a batch of ended spans. The spans must not be modified.
+	ExportSpans(ctx context.Context, spans []*Span) error
+	// Shutdown releases any resources held by the Exporter. It is called once, when the Tracer is
+	// shut down, after the final batch has been exported.
+	Shutdown(ctx context.Context) error
+}
+
+// InMemoryExporter is an Exporter that keeps every exported span in memory, for use in tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewInMemoryExporter creates, initialises and returns a pointer to a new InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpans stores the passed spans.
+func (e *InMemoryExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Shutdown does nothing, so that the spans can still be inspected after the Tracer has been shut
+// down.
+func (e *InMemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns a copy of the exported spans, in the order that they were exported.
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make([]*Span, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset removes every exported span.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
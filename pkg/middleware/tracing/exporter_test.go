@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// EXPORTER TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_InMemoryExporter checks that exported spans are kept until reset.
+func Test_InMemoryExporter(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	first, second := &Span{Name: "first"}, &Span{Name: "second"}
+
+	require.NoError(t, exporter.ExportSpans(context.Background(), []*Span{first}))
+	require.NoError(t, exporter.ExportSpans(context.Background(), []*Span{second}))
+	require.NoError(t, exporter.Shutdown(context.Background()))
+
+	spans := exporter.Spans()
+	assert.Equal(t, []*Span{first, second}, spans)
+	spans[0] = nil
+	assert.Equal(t, first, exporter.Spans()[0])
+
+	exporter.Reset()
+	assert.Empty(t, exporter.Spans())
+}
@@ -0,0 +1,271 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// OTLP/HTTP EXPORTER
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the OTLPExporter.
+const (
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+	DefaultServiceName  = "rmhttp"
+)
+
+// instrumentationScope is the name of the instrumentation scope reported with every span.
+const instrumentationScope = "github.com/rmhubbert/rmhttp/v5/pkg/middleware/tracing"
+
+// otlpSpanKindServer is the OTLP value of SPAN_KIND_SERVER.
+const otlpSpanKindServer = 2
+
+// OTLPOptions configures the OTLPExporter.
+type OTLPOptions struct {
+	// Endpoint is the full URL of the collector's traces endpoint. Defaults to
+	// DefaultOTLPEndpoint.
+	Endpoint string
+	// ServiceName is reported as the service.name resource attribute. Defaults to
+	// DefaultServiceName.
+	ServiceName string
+	// ResourceAttributes are reported alongside service.name, such as deployment.environment.
+	ResourceAttributes []Attribute
+	// Headers are added to every export request, such as for authentication.
+	Headers map[string]string
+	// Client is used to send the export requests. Defaults to a client with a 10 second timeout.
+	Client *http.Client
+}
+
+// OTLPExporter is an Exporter that sends spans to an OpenTelemetry collector using OTLP/HTTP,
+// with the JSON encoding.
+type OTLPExporter struct {
+	options OTLPOptions
+}
+
+// NewOTLPExporter creates, initialises and returns a pointer to a new OTLPExporter.
+func NewOTLPExporter(options OTLPOptions) *OTLPExporter {
+	if options.Endpoint == "" {
+		options.Endpoint = DefaultOTLPEndpoint
+	}
+	if options.ServiceName == "" {
+		options.ServiceName = DefaultServiceName
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OTLPExporter{options: options}
+}
+
+// ExportSpans sends the passed spans to the collector in a single request. Any response other
+// than a 2xx is returned as an error.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		e.options.Endpoint,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.options.Headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := e.options.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf(
+			"failed to export spans: collector responded with %d: %s",
+			res.StatusCode,
+			bytes.TrimSpace(msg),
+		)
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	return nil
+}
+
+// Shutdown closes any idle connections to the collector.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.options.Client.CloseIdleConnections()
+	return nil
+}
+
+// request converts the passed spans into an OTLP ExportTraceServiceRequest.
+func (e *OTLPExporter) request(spans []*Span) otlpRequest {
+	resource := append(
+		[]otlpAttribute{otlpAttr(Attribute{Key: "service.name", Value: e.options.ServiceName})},
+		otlpAttrs(e.options.ResourceAttributes)...,
+	)
+
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		converted = append(converted, otlpSpanFrom(span))
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: resource},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: instrumentationScope},
+			Spans: converted,
+		}},
+	}}}
+}
+
+// ------------------------------------------------------------------------------------------------
+// OTLP JSON ENCODING
+// ------------------------------------------------------------------------------------------------
+
+// The following types mirror the OTLP protobuf messages, as encoded by the protobuf JSON mapping.
+// Trace and span IDs are hex encoded, and 64 bit integers are encoded as strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Flags             uint32          `json:"flags"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// otlpSpanFrom converts an ended span.
+func otlpSpanFrom(span *Span) otlpSpan {
+	s := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		TraceState:        span.SpanContext.TraceState,
+		Name:              span.Name,
+		Kind:              otlpSpanKindServer,
+		StartTimeUnixNano: unixNano(span.Start),
+		EndTimeUnixNano:   unixNano(span.End),
+		Attributes:        otlpAttrs(span.Attributes),
+		Status:            otlpStatus{Code: int(span.Status), Message: span.StatusMessage},
+	}
+	if span.SpanContext.Sampled {
+		s.Flags = 0x01
+	}
+	if span.Parent.IsValid() {
+		s.ParentSpanID = span.Parent.SpanID.String()
+	}
+	for _, event := range span.Events {
+		s.Events = append(s.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   otlpAttrs(event.Attributes),
+		})
+	}
+	return s
+}
+
+// otlpAttrs converts a slice of attributes.
+func otlpAttrs(attributes []Attribute) []otlpAttribute {
+	if len(attributes) == 0 {
+		return nil
+	}
+	converted := make([]otlpAttribute, 0, len(attributes))
+	for _, attr := range attributes {
+		converted = append(converted, otlpAttr(attr))
+	}
+	return converted
+}
+
+// otlpAttr converts a single attribute, using the string representation of unsupported types.
+func otlpAttr(attr Attribute) otlpAttribute {
+	var value otlpValue
+	switch v := attr.Value.(type) {
+	case string:
+		value.StringValue = &v
+	case bool:
+		value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		value.IntValue = &s
+	case float64:
+		value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		value.StringValue = &s
+	}
+	return otlpAttribute{Key: attr.Key, Value: value}
+}
+
+// unixNano returns the passed time as a string of nanoseconds since the Unix epoch.
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// OTLP EXPORTER TESTS
+// ------------------------------------------------------------------------------------------------
+
+// newTestCollector starts a collector stand-in, which responds with the passed status code and
+// sends each received request body to the returned channel.
+func newTestCollector(t *testing.T, code int) (*httptest.Server, chan map[string]any) {
+	t.Helper()
+	received := make(chan map[string]any, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte("collector says no\n"))
+	}))
+	t.Cleanup(collector.Close)
+	return collector, received
+}
+
+// Test_OTLPExporter checks that spans are sent to the collector in the OTLP/HTTP JSON encoding.
+func Test_OTLPExporter(t *testing.T) {
+	collector, received := newTestCollector(t, http.StatusOK)
+	exporter := NewOTLPExporter(OTLPOptions{
+		Endpoint:           collector.URL + "/v1/traces",
+		ServiceName:        "api",
+		ResourceAttributes: []Attribute{{Key: "deployment.environment", Value: "test"}},
+		Headers:            map[string]string{"Authorization": "secret"},
+	})
+
+	start := time.Unix(1700000000, 0)
+	parent, ok := parseTraceParent(testTraceParent)
+	require.True(t, ok)
+	span := &Span{
+		Name: "GET /users/{id}",
+		SpanContext: SpanContext{
+			TraceID:    parent.TraceID,
+			SpanID:     SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+			Sampled:    true,
+			TraceState: "congo=t61rcWkgMzE",
+		},
+		Parent: parent,
+		Start:  start,
+		Attributes: []Attribute{
+			{Key: "http.route", Value: "/users/{id}"},
+			{Key: "http.response.status_code", Value: 503},
+			{Key: "late", Value: true},
+			{Key: "ratio", Value: 0.5},
+			{Key: "other", Value: time.Second},
+		},
+		Events: []Event{{Name: "timeout", Time: start.Add(time.Second)}},
+	}
+	span.SetStatus(StatusError, "request timed out after 1s")
+	span.end()
+	span.End = start.Add(2 * time.Second)
+
+	require.NoError(t, exporter.ExportSpans(context.Background(), []*Span{span}))
+	require.NoError(t, exporter.ExportSpans(context.Background(), nil))
+	require.NoError(t, exporter.Shutdown(context.Background()))
+
+	body := <-received
+	assert.Empty(t, received)
+	resourceSpans := body["resourceSpans"].([]any)[0].(map[string]any)
+	assert.Equal(t, []any{
+		map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "api"}},
+		map[string]any{
+			"key":   "deployment.environment",
+			"value": map[string]any{"stringValue": "test"},
+		},
+	}, resourceSpans["resource"].(map[string]any)["attributes"])
+
+	scopeSpans := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)
+	assert.Equal(t, instrumentationScope, scopeSpans["scope"].(map[string]any)["name"])
+
+	exported := scopeSpans["spans"].([]any)[0].(map[string]any)
+	assert.Equal(t, testTraceID, exported["traceId"])
+	assert.Equal(t, "0102030405060708", exported["spanId"])
+	assert.Equal(t, testSpanID, exported["parentSpanId"])
+	assert.Equal(t, "congo=t61rcWkgMzE", exported["traceState"])
+	assert.Equal(t, "GET /users/{id}", exported["name"])
+	assert.Equal(t, float64(2), exported["kind"])
+	assert.Equal(t, float64(1), exported["flags"])
+	assert.Equal(t, "1700000000000000000", exported["startTimeUnixNano"])
+	assert.Equal(t, "1700000002000000000", exported["endTimeUnixNano"])
+	assert.Equal(t, map[string]any{
+		"code":    float64(2),
+		"message": "request timed out after 1s",
+	}, exported["status"])
+	assert.Equal(t, []any{
+		map[string]any{"key": "http.route", "value": map[string]any{"stringValue": "/users/{id}"}},
+		map[string]any{
+			"key":   "http.response.status_code",
+			"value": map[string]any{"intValue": "503"},
+		},
+		map[string]any{"key": "late", "value": map[string]any{"boolValue": true}},
+		map[string]any{"key": "ratio", "value": map[string]any{"doubleValue": 0.5}},
+		map[string]any{"key": "other", "value": map[string]any{"stringValue": "1s"}},
+	}, exported["attributes"])
+	assert.Equal(t, []any{map[string]any{
+		"name":         "timeout",
+		"timeUnixNano": "1700000001000000000",
+	}}, exported["events"])
+}
+
+// Test_OTLPExporter_errors checks that failed exports are reported.
+func Test_OTLPExporter_errors(t *testing.T) {
+	collector, received := newTestCollector(t, http.StatusBadRequest)
+	exporter := NewOTLPExporter(OTLPOptions{
+		Endpoint: collector.URL + "/v1/traces",
+		Headers:  map[string]string{"Authorization": "secret"},
+	})
+	spans := []*Span{{Name: "GET /"}}
+
+	err := exporter.ExportSpans(context.Background(), spans)
+	assert.EqualError(
+		t,
+		err,
+		"failed to export spans: collector responded with 400: collector says no",
+	)
+	<-received
+
+	collector.Close()
+	assert.Error(t, exporter.ExportSpans(context.Background(), spans))
+
+	exporter = NewOTLPExporter(OTLPOptions{Endpoint: "://invalid"})
+	assert.ErrorContains(
+		t,
+		exporter.ExportSpans(context.Background(), spans),
+		"failed to create export request",
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	exporter = NewOTLPExporter(OTLPOptions{Endpoint: collector.URL})
+	assert.ErrorIs(t, exporter.ExportSpans(ctx, spans), context.Canceled)
+}
+
+// Test_OTLPExporter_with_Tracer checks the exporter end to end, with spans exported by a Tracer.
+func Test_OTLPExporter_with_Tracer(t *testing.T) {
+	collector, received := newTestCollector(t, http.StatusOK)
+	tracer := New(Options{Exporter: NewOTLPExporter(OTLPOptions{
+		Endpoint: collector.URL + "/v1/traces",
+		Headers:  map[string]string{"Authorization": "secret"},
+	})})
+	h := tracer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	body := <-received
+	resourceSpans := body["resourceSpans"].([]any)[0].(map[string]any)
+	scopeSpans := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)
+	assert.Len(t, scopeSpans["spans"], 1)
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------------------------
+// W3C TRACE CONTEXT PROPAGATION
+// ------------------------------------------------------------------------------------------------
+
+// The W3C Trace Context headers.
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// maxTraceStateMembers is the maximum number of list members that tracestate may contain.
+const maxTraceStateMembers = 32
+
+// Extract returns the SpanContext propagated in the passed headers. The returned SpanContext is
+// invalid if there is no traceparent header, or if it cannot be parsed, in which case tracestate
+// is ignored as well.
+func Extract(header http.Header) SpanContext {
+	values := header.Values(TraceParentHeader)
+	if len(values) != 1 {
+		return SpanContext{}
+	}
+	sc, ok := parseTraceParent(values[0])
+	if !ok {
+		return SpanContext{}
+	}
+	sc.TraceState = parseTraceState(header.Values(TraceStateHeader))
+	return sc
+}
+
+// Inject sets the traceparent and tracestate headers for the span in the passed context, so that
+// the trace continues in outgoing requests. It does nothing if the context has no span.
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil || !span.SpanContext.IsValid() {
+		return
+	}
+	header.Set(TraceParentHeader, formatTraceParent(span.SpanContext))
+	if span.SpanContext.TraceState != "" {
+		header.Set(TraceStateHeader, span.SpanContext.TraceState)
+	} else {
+		header.Del(TraceStateHeader)
+	}
+}
+
+// formatTraceParent returns the version 00 traceparent header value for the passed SpanContext.
+func formatTraceParent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// parseTraceParent parses a traceparent header value. Versions above 00 are parsed as far as the
+// version 00 fields, as the specification requires, but version ff is invalid.
+func parseTraceParent(value string) (SpanContext, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return SpanContext{}, false
+	}
+
+	version, ok := decodeHex(value[0:2], 1)
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(value) != 55) {
+		return SpanContext{}, false
+	}
+	if len(value) > 55 && value[55] != '-' {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	traceID, ok := decodeHex(value[3:35], 16)
+	if !ok {
+		return SpanContext{}, false
+	}
+	spanID, ok := decodeHex(value[36:52], 8)
+	if !ok {
+		return SpanContext{}, false
+	}
+	flags, ok := decodeHex(value[53:55], 1)
+	if !ok {
+		return SpanContext{}, false
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, sc.IsValid()
+}
+
+// decodeHex decodes a lowercase hex string of the expected number of bytes.
+func decodeHex(s string, size int) ([]byte, bool) {
+	if len(s) != size*2 || strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// parseTraceState combines the passed tracestate header values, dropping empty or malformed list
+// members, and any beyond the 32 member limit.
+func parseTraceState(values []string) string {
+	members := make([]string, 0, len(values))
+	for _, value := range values {
+		for member := range strings.SplitSeq(value, ",") {
+			member = strings.TrimSpace(member)
+			key, val, ok := strings.Cut(member, "=")
+			if !ok || key == "" || val == "" || strings.ContainsAny(member, " \t") {
+				continue
+			}
+			if len(members) == maxTraceStateMembers {
+				return strings.Join(members, ",")
+			}
+			members = append(members, member)
+		}
+	}
+	return strings.Join(members, ",")
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// PROPAGATION TESTS
+// ------------------------------------------------------------------------------------------------
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID      = "00f067aa0ba902b7"
+	testTraceParent = "00-" + testTraceID + "-" + testSpanID + "-01"
+)
+
+// Test_Extract_traceparent checks that valid traceparent headers are parsed, and that invalid
+// headers are ignored.
+func Test_Extract_traceparent(t *testing.T) {
+	zeroTraceID := "00-" + strings.Repeat("0", 32) + "-" + testSpanID + "-01"
+	zeroSpanID := "00-" + testTraceID + "-" + strings.Repeat("0", 16) + "-01"
+
+	tests := []struct {
+		name            string
+		traceparent     []string
+		expectedValid   bool
+		expectedSampled bool
+	}{
+		{"sampled", []string{testTraceParent}, true, true},
+		{"not sampled", []string{"00-" + testTraceID + "-" + testSpanID + "-00"}, true, false},
+		{"other flags", []string{"00-" + testTraceID + "-" + testSpanID + "-09"}, true, true},
+		{"future version", []string{"cc-" + testTraceID + "-" + testSpanID + "-01-extra"}, true, true},
+		{"missing", nil, false, false},
+		{"duplicated", []string{testTraceParent, testTraceParent}, false, false},
+		{"version ff", []string{"ff-" + testTraceID + "-" + testSpanID + "-01"}, false, false},
+		{"version 00 too long", []string{testTraceParent + "-extra"}, false, false},
+		{"uppercase", []string{strings.ToUpper(testTraceParent)}, false, false},
+		{"zero trace id", []string{zeroTraceID}, false, false},
+		{"zero span id", []string{zeroSpanID}, false, false},
+		{"not hex", []string{"00-" + strings.Repeat("x", 32) + "-" + testSpanID + "-01"}, false, false},
+		{"wrong separators", []string{strings.ReplaceAll(testTraceParent, "-", "_")}, false, false},
+		{"truncated", []string{testTraceParent[:50]}, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range test.traceparent {
+				header.Add(TraceParentHeader, value)
+			}
+			header.Set(TraceStateHeader, "congo=t61rcWkgMzE")
+
+			sc := Extract(header)
+			assert.Equal(t, test.expectedValid, sc.IsValid())
+			assert.Equal(t, test.expectedSampled, sc.Sampled)
+			if test.expectedValid {
+				assert.Equal(t, testTraceID, sc.TraceID.String())
+				assert.Equal(t, testSpanID, sc.SpanID.String())
+				assert.Equal(t, "congo=t61rcWkgMzE", sc.TraceState)
+			} else {
+				assert.Empty(t, sc.TraceState)
+			}
+		})
+	}
+}
+
+// Test_parseTraceState checks that tracestate values are combined, and that malformed or excess
+// list members are dropped.
+func Test_parseTraceState(t *testing.T) {
+	assert.Equal(
+		t,
+		"rojo=00f067aa0ba902b7,congo=t61rcWkgMzE",
+		parseTraceState([]string{"rojo=00f067aa0ba902b7", " congo=t61rcWkgMzE ,"}),
+	)
+	assert.Equal(t, "a=1,b=2", parseTraceState([]string{"a=1,,invalid,=x,y=,b=2,c d=3"}))
+
+	members := make([]string, 40)
+	for i := range members {
+		members[i] = fmt.Sprintf("k%d=v", i)
+	}
+	parsed := parseTraceState([]string{strings.Join(members, ",")})
+	assert.Len(t, strings.Split(parsed, ","), maxTraceStateMembers)
+}
+
+// Test_Inject checks that the current span is propagated to outgoing requests.
+func Test_Inject(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceStateHeader, "stale=1")
+	Inject(context.Background(), header)
+	assert.Empty(t, header.Get(TraceParentHeader))
+
+	span := &Span{SpanContext: SpanContext{
+		TraceID: newTraceID(),
+		SpanID:  newSpanID(),
+		Sampled: true,
+	}}
+	Inject(contextWithSpan(context.Background(), span), header)
+	assert.Equal(t, formatTraceParent(span.SpanContext), header.Get(TraceParentHeader))
+	assert.Empty(t, header.Values(TraceStateHeader))
+
+	span.SpanContext.TraceState = "congo=t61rcWkgMzE"
+	span.SpanContext.Sampled = false
+	Inject(contextWithSpan(context.Background(), span), header)
+	assert.True(t, strings.HasSuffix(header.Get(TraceParentHeader), "-00"))
+	assert.Equal(t, "congo=t61rcWkgMzE", header.Get(TraceStateHeader))
+
+	// The injected header must round trip.
+	assert.Equal(t, span.SpanContext, Extract(header))
+}
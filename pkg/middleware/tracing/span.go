@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// IDS
+// ------------------------------------------------------------------------------------------------
+
+// TraceID identifies a trace. The zero value is invalid.
+type TraceID [16]byte
+
+// String returns the TraceID as lowercase hex.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the TraceID is not all zeroes.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span within a trace. The zero value is invalid.
+type SpanID [8]byte
+
+// String returns the SpanID as lowercase hex.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the SpanID is not all zeroes.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// newTraceID returns a random, valid TraceID.
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// newSpanID returns a random, valid SpanID.
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// ------------------------------------------------------------------------------------------------
+// SPAN CONTEXT
+// ------------------------------------------------------------------------------------------------
+
+// SpanContext is the part of a span that is propagated between services, as defined by the W3C
+// Trace Context specification.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether both the TraceID and SpanID are valid.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// ------------------------------------------------------------------------------------------------
+// SPAN
+// ------------------------------------------------------------------------------------------------
+
+// StatusCode is the status of a span, as defined by OpenTelemetry.
+type StatusCode int
+
+// The possible span statuses. Server spans are only marked as errors for 5xx responses, panics
+// and timeouts.
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// An Attribute is a key value pair describing a span or event. Values should be a string, bool,
+// int, int64 or float64. Any other type is exported as its string representation.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// An Event is a timestamped annotation of a span, such as a timeout or an exception.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// A Span records a single server request. It is safe for concurrent use, and any changes made
+// after it has ended are ignored, so a handler that outlives its timeout cannot modify an exported
+// span.
+type Span struct {
+	Name          string
+	SpanContext   SpanContext
+	Parent        SpanContext
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Events        []Event
+	Status        StatusCode
+	StatusMessage string
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// SetAttribute adds an attribute to the span, replacing any existing attribute with the same key.
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	for i, attr := range s.Attributes {
+		if attr.Key == key {
+			s.Attributes[i].Value = value
+			return
+		}
+	}
+	s.Attributes = append(s.Attributes, Attribute{Key: key, Value: value})
+}
+
+// AddEvent adds a timestamped event to the span.
+func (s *Span) AddEvent(name string, attributes ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.Events = append(s.Events, Event{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+// RecordError adds an exception event for the passed error, and marks the span as an error.
+func (s *Span) RecordError(err error, attributes ...Attribute) {
+	if err == nil {
+		return
+	}
+	attributes = append([]Attribute{
+		{Key: "exception.type", Value: fmt.Sprintf("%T", err)},
+		{Key: "exception.message", Value: err.Error()},
+	}, attributes...)
+	s.AddEvent("exception", attributes...)
+	s.SetStatus(StatusError, err.Error())
+}
+
+// SetStatus sets the status of the span. An error status cannot be overwritten by an OK or unset
+// status, so the first error is kept.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended || s.Status == StatusError {
+		return
+	}
+	s.Status = code
+	if code == StatusError {
+		s.StatusMessage = message
+	}
+}
+
+// end marks the span as ended, returning false if it had already ended.
+func (s *Span) end() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return false
+	}
+	s.ended = true
+	s.End = time.Now()
+	return true
+}
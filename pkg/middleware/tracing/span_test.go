@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// SPAN TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_IDs checks that generated IDs are valid and unique, and that they format as hex.
+func Test_IDs(t *testing.T) {
+	assert.False(t, TraceID{}.IsValid())
+	assert.False(t, SpanID{}.IsValid())
+	assert.True(t, newTraceID().IsValid())
+	assert.True(t, newSpanID().IsValid())
+	assert.NotEqual(t, newTraceID(), newTraceID())
+	assert.Len(t, newTraceID().String(), 32)
+	assert.Len(t, newSpanID().String(), 16)
+}
+
+// Test_Span checks that attributes, events and status are recorded, and that changes are ignored
+// once the span has ended.
+func Test_Span(t *testing.T) {
+	span := &Span{}
+	span.SetAttribute("user.id", "1")
+	span.SetAttribute("user.id", "2")
+	span.AddEvent("cache.miss")
+	span.SetStatus(StatusOK, "ignored")
+	assert.Equal(t, []Attribute{{Key: "user.id", Value: "2"}}, span.Attributes)
+	assert.Equal(t, StatusOK, span.Status)
+	assert.Empty(t, span.StatusMessage)
+
+	span.RecordError(errors.New("database unavailable"))
+	span.RecordError(nil)
+	span.SetStatus(StatusOK, "")
+	assert.Equal(t, StatusError, span.Status)
+	assert.Equal(t, "database unavailable", span.StatusMessage)
+	assert.Len(t, span.Events, 2)
+	assert.Equal(t, "exception", span.Events[1].Name)
+	assert.Contains(
+		t,
+		span.Events[1].Attributes,
+		Attribute{Key: "exception.message", Value: "database unavailable"},
+	)
+
+	assert.True(t, span.end())
+	assert.False(t, span.end())
+	assert.False(t, span.End.IsZero())
+
+	span.SetAttribute("late", true)
+	span.AddEvent("late")
+	assert.Len(t, span.Attributes, 1)
+	assert.Len(t, span.Events, 2)
+}
@@ -0,0 +1,413 @@
+// Package tracing provides OpenTelemetry compatible distributed tracing middleware for an rmhttp
+// App, without depending on the OpenTelemetry SDK.
+//
+// The middleware continues the trace propagated in the W3C traceparent and tracestate headers, or
+// starts a new one, and creates a server span for each request. Spans are named after the matched
+// route pattern, such as "GET /users/{id}", and record the response status code, any panic (as an
+// exception event) and any Route timeout (as a timeout event). Ended spans are batched and sent to
+// an Exporter in the background.
+//
+//	tracer := tracing.New(tracing.Options{
+//		Exporter: tracing.NewOTLPExporter(tracing.OTLPOptions{ServiceName: "api"}),
+//	})
+//	defer tracer.Shutdown(context.Background())
+//	app.Use(recoverer.Middleware(), tracer.Middleware())
+//
+// Handlers can annotate the current span with SpanFromContext, and continue the trace in outgoing
+// requests with Inject.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/rmhubbert/rmhttp/v5"
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/realip"
+)
+
+// ------------------------------------------------------------------------------------------------
+// TRACER
+// ------------------------------------------------------------------------------------------------
+
+// Defaults used by the Tracer.
+const (
+	DefaultBatchSize     = 512
+	DefaultBatchTimeout  = 5 * time.Second
+	DefaultQueueSize     = 2048
+	DefaultExportTimeout = 30 * time.Second
+)
+
+// Options configures the Tracer.
+type Options struct {
+	// Exporter receives the ended spans. Spans are still created and propagated without one, but
+	// they are discarded.
+	Exporter Exporter
+	// Sampler decides whether a new trace is sampled. Requests that continue a trace follow the
+	// sampling decision of the caller. Defaults to sampling every trace.
+	Sampler func(r *http.Request) bool
+	// BatchSize is the maximum number of spans exported at once. Defaults to DefaultBatchSize.
+	BatchSize int
+	// BatchTimeout is the longest that a span waits to be exported. Defaults to
+	// DefaultBatchTimeout.
+	BatchTimeout time.Duration
+	// QueueSize is the number of spans that can wait to be exported. Spans are dropped, rather than
+	// blocking requests, when the queue is full. Defaults to DefaultQueueSize.
+	QueueSize int
+	// ExportTimeout limits how long a single export may take. Defaults to DefaultExportTimeout.
+	ExportTimeout time.Duration
+	// OnError is called with any export error. Defaults to logging the error with slog.
+	OnError func(err error)
+}
+
+// Tracer creates the server spans for requests, and exports them in the background. It is safe
+// for concurrent use.
+type Tracer struct {
+	options Options
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *Span
+	flush  chan chan struct{}
+	done   chan struct{}
+}
+
+// New creates, initialises and returns a pointer to a new Tracer, and starts exporting spans in
+// the background. Shutdown should be called to export any remaining spans before the process
+// exits.
+func New(options Options) *Tracer {
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.BatchTimeout <= 0 {
+		options.BatchTimeout = DefaultBatchTimeout
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultQueueSize
+	}
+	if options.ExportTimeout <= 0 {
+		options.ExportTimeout = DefaultExportTimeout
+	}
+	if options.OnError == nil {
+		options.OnError = func(err error) {
+			slog.Error("failed to export spans", "type", "tracing", "error", err)
+		}
+	}
+
+	t := &Tracer{
+		options: options,
+		queue:   make(chan *Span, options.QueueSize),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Middleware returns a middleware function that creates a server span for each request.
+//
+// Panics are recorded and then re-panicked, so that a recoverer registered before this middleware
+// can still handle them. Timeouts are recorded via rmhttp.OnTimeout, so this middleware must run
+// before the Route timeout, which it will do when added with App.Use or Group.WithMiddleware.
+func (t *Tracer) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := t.start(r)
+
+			code := 0
+			hooked := httpsnoop.Wrap(w, httpsnoop.Hooks{
+				WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+					return func(c int) {
+						if code == 0 && c >= http.StatusOK {
+							code = c
+						}
+						next(c)
+					}
+				},
+				Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+					return func(b []byte) (int, error) {
+						if code == 0 {
+							code = http.StatusOK
+						}
+						return next(b)
+					}
+				},
+			})
+
+			defer func() {
+				if p := recover(); p != nil {
+					if p == http.ErrAbortHandler {
+						span.SetStatus(StatusError, "request aborted")
+					} else {
+						recordPanic(span, p)
+						if code == 0 {
+							code = http.StatusInternalServerError
+						}
+					}
+					t.finish(span, code)
+					panic(p)
+				}
+				if code == 0 {
+					code = http.StatusOK
+				}
+				t.finish(span, code)
+			}()
+
+			ctx := rmhttp.OnTimeout(contextWithSpan(r.Context(), span), func(timeout rmhttp.Timeout) {
+				duration := timeout.Duration.String()
+				span.AddEvent("timeout", Attribute{Key: "rmhttp.timeout", Value: duration})
+				span.SetStatus(StatusError, "request timed out after "+duration)
+			})
+			next.ServeHTTP(hooked, r.WithContext(ctx))
+		})
+	}
+}
+
+// Flush exports every span that has ended, blocking until the export has finished or the context
+// is done.
+func (t *Tracer) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case t.flush <- flushed:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops the Tracer, exports any remaining spans, and then shuts down the Exporter. Spans
+// that end after Shutdown has been called are discarded.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	first := !t.closed
+	if first {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !first || t.options.Exporter == nil {
+		return nil
+	}
+	return t.options.Exporter.Shutdown(ctx)
+}
+
+// start creates the span for the passed request, continuing the propagated trace if there is
+// one.
+func (t *Tracer) start(r *http.Request) *Span {
+	parent := Extract(r.Header)
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.options.Sampler == nil || t.options.Sampler(r)
+	}
+
+	method := r.Method
+	attributes := make([]Attribute, 0, 8)
+	if !isStandardMethod(method) {
+		attributes = append(
+			attributes,
+			Attribute{Key: "http.request.method_original", Value: method},
+		)
+		method = "_OTHER"
+	}
+	attributes = append(attributes,
+		Attribute{Key: "http.request.method", Value: method},
+		Attribute{Key: "url.path", Value: r.URL.Path},
+		Attribute{Key: "url.scheme", Value: scheme(r)},
+		Attribute{Key: "server.address", Value: r.Host},
+		Attribute{Key: "network.protocol.version", Value: strings.TrimPrefix(r.Proto, "HTTP/")},
+	)
+	if ua := r.UserAgent(); ua != "" {
+		attributes = append(attributes, Attribute{Key: "user_agent.original", Value: ua})
+	}
+	attributes = append(attributes, Attribute{Key: "client.address", Value: realip.ClientIP(r)})
+
+	// Spans are named after the route, rather than the path, as recommended by the HTTP semantic
+	// conventions. Unmatched requests are named after the method alone.
+	name := method
+	if method == "_OTHER" {
+		name = "HTTP"
+	}
+	if route := routeFromPattern(r.Pattern); route != "" {
+		name += " " + route
+		attributes = append(attributes, Attribute{Key: "http.route", Value: route})
+	}
+
+	return &Span{
+		Name:        name,
+		SpanContext: sc,
+		Parent:      parent,
+		Start:       time.Now(),
+		Attributes:  attributes,
+	}
+}
+
+// finish records the status code, ends the span, and queues it for export if it is sampled.
+func (t *Tracer) finish(span *Span, code int) {
+	span.SetAttribute("http.response.status_code", code)
+	if code >= http.StatusInternalServerError {
+		span.SetStatus(StatusError, "")
+	}
+	if !span.end() || !span.SpanContext.Sampled || t.options.Exporter == nil {
+		return
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- span:
+	default:
+		t.options.OnError(fmt.Errorf("span queue is full, dropping span %q", span.Name))
+	}
+}
+
+// run batches the queued spans, and exports them when the batch is full, the batch timeout
+// elapses, or a flush is requested. It returns once the queue has been closed and drained.
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.options.BatchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, t.options.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.options.ExportTimeout)
+		defer cancel()
+		if err := t.options.Exporter.ExportSpans(ctx, batch); err != nil {
+			t.options.OnError(err)
+		}
+		batch = make([]*Span, 0, t.options.BatchSize)
+	}
+	add := func(span *Span) {
+		batch = append(batch, span)
+		if len(batch) >= t.options.BatchSize {
+			export()
+		}
+	}
+
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				export()
+				return
+			}
+			add(span)
+		case <-ticker.C:
+			export()
+		case flushed := <-t.flush:
+			t.drain(add)
+			export()
+			close(flushed)
+		}
+	}
+}
+
+// drain passes every span waiting in the queue to add, without blocking.
+func (t *Tracer) drain(add func(*Span)) {
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				return
+			}
+			add(span)
+		default:
+			return
+		}
+	}
+}
+
+// recordPanic adds an exception event for the recovered value, with the stack trace, and marks the
+// span as an error.
+func recordPanic(span *Span, p any) {
+	message := fmt.Sprint(p)
+	span.AddEvent("exception",
+		Attribute{Key: "exception.type", Value: fmt.Sprintf("%T", p)},
+		Attribute{Key: "exception.message", Value: message},
+		Attribute{Key: "exception.stacktrace", Value: string(debug.Stack())},
+	)
+	span.SetStatus(StatusError, "panic: "+message)
+}
+
+// ------------------------------------------------------------------------------------------------
+// CONTEXT
+// ------------------------------------------------------------------------------------------------
+
+// spanKey is the context key for the current span.
+type spanKey struct{}
+
+// contextWithSpan returns a copy of the passed context, holding the passed span.
+func contextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the current span, or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ------------------------------------------------------------------------------------------------
+// HELPERS
+// ------------------------------------------------------------------------------------------------
+
+// routeFromPattern returns the path template of the passed route pattern, removing the method and
+// host, if there are any.
+func routeFromPattern(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimSpace(path)
+	}
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// isStandardMethod reports whether the passed method is one of the methods defined by RFC 9110 or
+// RFC 5789.
+func isStandardMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// scheme returns the scheme of the passed request.
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
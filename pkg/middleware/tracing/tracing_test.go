@@ -0,0 +1,264 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmhubbert/rmhttp/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// TRACING TESTS
+// ------------------------------------------------------------------------------------------------
+
+// newTestTracer returns a Tracer that exports to an InMemoryExporter, and shuts it down when the
+// test finishes.
+func newTestTracer(t *testing.T, options Options) (*Tracer, *InMemoryExporter) {
+	t.Helper()
+	exporter := NewInMemoryExporter()
+	options.Exporter = exporter
+	tracer := New(options)
+	t.Cleanup(func() {
+		_ = tracer.Shutdown(context.Background())
+	})
+	return tracer, exporter
+}
+
+// flushedSpans flushes the tracer, and returns the exported spans.
+func flushedSpans(t *testing.T, tracer *Tracer, exporter *InMemoryExporter) []*Span {
+	t.Helper()
+	require.NoError(t, tracer.Flush(context.Background()))
+	return exporter.Spans()
+}
+
+// attribute returns the value of the span attribute with the passed key.
+func attribute(span *Span, key string) any {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return nil
+}
+
+// Test_Middleware checks that a server span is created for each request, named after the route
+// pattern, and that the trace context is available to the handler.
+func Test_Middleware(t *testing.T) {
+	tracer, exporter := newTestTracer(t, Options{})
+	app := rmhttp.New()
+	app.Use(tracer.Middleware())
+
+	var handlerSpan *Span
+	app.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = SpanFromContext(r.Context())
+		handlerSpan.SetAttribute("user.id", r.PathValue("id"))
+		w.WriteHeader(http.StatusCreated)
+	})
+	app.Get("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/broken", nil))
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	spans := flushedSpans(t, tracer, exporter)
+	require.Len(t, spans, 3)
+
+	span := spans[0]
+	assert.Same(t, handlerSpan, span)
+	assert.Equal(t, "GET /users/{id}", span.Name)
+	assert.True(t, span.SpanContext.IsValid())
+	assert.True(t, span.SpanContext.Sampled)
+	assert.False(t, span.Parent.IsValid())
+	assert.Equal(t, StatusUnset, span.Status)
+	assert.Equal(t, "/users/{id}", attribute(span, "http.route"))
+	assert.Equal(t, "/users/42", attribute(span, "url.path"))
+	assert.Equal(t, "GET", attribute(span, "http.request.method"))
+	assert.Equal(t, "test-agent", attribute(span, "user_agent.original"))
+	assert.Equal(t, http.StatusCreated, attribute(span, "http.response.status_code"))
+	assert.Equal(t, "42", attribute(span, "user.id"))
+	assert.False(t, span.End.Before(span.Start))
+
+	assert.Equal(t, StatusError, spans[1].Status)
+	assert.Equal(t, http.StatusBadGateway, attribute(spans[1], "http.response.status_code"))
+
+	assert.Equal(t, "GET", spans[2].Name)
+	assert.Nil(t, attribute(spans[2], "http.route"))
+	assert.Equal(t, StatusUnset, spans[2].Status)
+}
+
+// Test_Middleware_propagation checks that an incoming trace is continued, and that its sampling
+// decision is respected.
+func Test_Middleware_propagation(t *testing.T) {
+	tracer, exporter := newTestTracer(t, Options{Sampler: func(*http.Request) bool {
+		return false
+	}})
+	h := tracer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing := http.Header{}
+		Inject(r.Context(), outgoing)
+		_, _ = w.Write([]byte(outgoing.Get(TraceParentHeader)))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceParentHeader, testTraceParent)
+	req.Header.Set(TraceStateHeader, "congo=t61rcWkgMzE")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	// New traces are not sampled, so only the continued trace is exported.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	spans := flushedSpans(t, tracer, exporter)
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, testTraceID, span.SpanContext.TraceID.String())
+	assert.Equal(t, testSpanID, span.Parent.SpanID.String())
+	assert.NotEqual(t, span.Parent.SpanID, span.SpanContext.SpanID)
+	assert.Equal(t, "congo=t61rcWkgMzE", span.SpanContext.TraceState)
+	assert.Equal(t, formatTraceParent(span.SpanContext), w.Body.String())
+}
+
+// Test_Middleware_panics checks that panics are recorded as exceptions, and then re-panicked for
+// the recoverer to handle.
+func Test_Middleware_panics(t *testing.T) {
+	tracer, exporter := newTestTracer(t, Options{})
+	h := tracer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(errors.New("boom"))
+	}))
+
+	assert.Panics(t, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	spans := flushedSpans(t, tracer, exporter)
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, StatusError, span.Status)
+	assert.Equal(t, "panic: boom", span.StatusMessage)
+	assert.Equal(t, http.StatusInternalServerError, attribute(span, "http.response.status_code"))
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, "exception", span.Events[0].Name)
+	assert.Contains(
+		t,
+		span.Events[0].Attributes,
+		Attribute{Key: "exception.type", Value: "*errors.errorString"},
+	)
+}
+
+// Test_Middleware_timeouts checks that Route timeouts are recorded as timeout events.
+func Test_Middleware_timeouts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	tracer, exporter := newTestTracer(t, Options{})
+	app := rmhttp.New()
+	app.Use(tracer.Middleware())
+	app.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		SpanFromContext(r.Context()).SetAttribute("late", true)
+	}).WithTimeout(20*time.Millisecond, "timed out")
+
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	spans := flushedSpans(t, tracer, exporter)
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, StatusError, span.Status)
+	assert.Equal(t, "request timed out after 20ms", span.StatusMessage)
+	assert.Equal(t, http.StatusServiceUnavailable, attribute(span, "http.response.status_code"))
+	require.Len(t, span.Events, 1)
+	assert.Equal(t, "timeout", span.Events[0].Name)
+}
+
+// Test_Tracer_batching checks that spans are exported in batches, and that Shutdown exports the
+// remaining spans.
+func Test_Tracer_batching(t *testing.T) {
+	exporter := &countingExporter{}
+	tracer := New(Options{Exporter: exporter, BatchSize: 2, BatchTimeout: time.Hour})
+	h := tracer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for range 5 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	assert.Equal(t, int32(5), exporter.spans.Load())
+	assert.Equal(t, int32(3), exporter.batches.Load())
+	assert.True(t, exporter.shutdown.Load())
+
+	// Spans that end after shutdown are discarded.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.NoError(t, tracer.Flush(context.Background()))
+	assert.NoError(t, tracer.Shutdown(context.Background()))
+	assert.Equal(t, int32(5), exporter.spans.Load())
+}
+
+// Test_Tracer_full_queue checks that spans are dropped, rather than blocking requests, when the
+// queue is full.
+func Test_Tracer_full_queue(t *testing.T) {
+	exporter := &countingExporter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	var errs atomic.Int32
+	tracer := New(Options{
+		Exporter:  exporter,
+		BatchSize: 1,
+		QueueSize: 1,
+		OnError:   func(error) { errs.Add(1) },
+	})
+	h := tracer.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// The first span blocks the exporter, the second waits in the queue, and the third is dropped.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	<-exporter.started
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, int32(1), errs.Load())
+
+	close(exporter.release)
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	assert.Equal(t, int32(2), exporter.spans.Load())
+}
+
+// countingExporter counts the exported spans and batches. If release is set, each export blocks
+// until it is closed, after signalling started.
+type countingExporter struct {
+	spans    atomic.Int32
+	batches  atomic.Int32
+	shutdown atomic.Bool
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	if e.release != nil {
+		select {
+		case e.started <- struct{}{}:
+		default:
+		}
+		<-e.release
+	}
+	e.batches.Add(1)
+	e.spans.Add(int32(len(spans)))
+	return nil
+}
+
+func (e *countingExporter) Shutdown(ctx context.Context) error {
+	e.shutdown.Store(true)
+	return nil
+}
+
+// Test_routeFromPattern checks that the method and host are removed from route patterns.
+func Test_routeFromPattern(t *testing.T) {
+	assert.Equal(t, "", routeFromPattern(""))
+	assert.Equal(t, "/users/{id}", routeFromPattern("GET /users/{id}"))
+	assert.Equal(t, "/users", routeFromPattern("example.com/users"))
+	assert.Equal(t, "/users", routeFromPattern("POST example.com/users"))
+}
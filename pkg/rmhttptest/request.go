@@ -0,0 +1,166 @@
+package rmhttptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------------------------------------------------------------
+// REQUEST
+// ------------------------------------------------------------------------------------------------
+
+// Request builds a request to be sent by a Client. Every builder method returns the Request, so
+// that calls can be chained, finishing with Do.
+type Request struct {
+	client  *Client
+	method  string
+	path    string
+	header  http.Header
+	query   url.Values
+	cookies []*http.Cookie
+	body    []byte
+	ctx     context.Context
+	err     error
+}
+
+// newRequest creates, initialises and returns a pointer to a new Request.
+func newRequest(client *Client, method string, path string) *Request {
+	return &Request{
+		client: client,
+		method: strings.ToUpper(method),
+		path:   path,
+		header: http.Header{},
+		query:  url.Values{},
+		ctx:    context.Background(),
+	}
+}
+
+// Header sets a request header, replacing any existing values for the key.
+func (req *Request) Header(key string, value string) *Request {
+	req.header.Set(key, value)
+	return req
+}
+
+// Query adds a query string parameter.
+func (req *Request) Query(key string, value string) *Request {
+	req.query.Add(key, value)
+	return req
+}
+
+// Cookie adds a cookie to the request.
+func (req *Request) Cookie(cookie *http.Cookie) *Request {
+	req.cookies = append(req.cookies, cookie)
+	return req
+}
+
+// BasicAuth sets the Authorization header to use HTTP Basic Authentication.
+func (req *Request) BasicAuth(username string, password string) *Request {
+	r := &http.Request{Header: http.Header{}}
+	r.SetBasicAuth(username, password)
+	return req.Header("Authorization", r.Header.Get("Authorization"))
+}
+
+// BearerToken sets the Authorization header to use the passed bearer token.
+func (req *Request) BearerToken(token string) *Request {
+	return req.Header("Authorization", "Bearer "+token)
+}
+
+// Body sets the raw request body, along with its content type.
+func (req *Request) Body(contentType string, body []byte) *Request {
+	req.body = body
+	return req.Header("Content-Type", contentType)
+}
+
+// JSON encodes the passed value as the request body, and sets the Content-Type header to
+// application/json. Any encoding error is reported when the request is sent.
+func (req *Request) JSON(value any) *Request {
+	body, err := json.Marshal(value)
+	if err != nil {
+		req.err = err
+		return req
+	}
+	return req.Body("application/json", body)
+}
+
+// Form encodes the passed values as the request body, and sets the Content-Type header to
+// application/x-www-form-urlencoded.
+func (req *Request) Form(values url.Values) *Request {
+	return req.Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// WithContext sets the context used for the request.
+func (req *Request) WithContext(ctx context.Context) *Request {
+	req.ctx = ctx
+	return req
+}
+
+// Build creates the http.Request. The path is resolved against the Client's base URL.
+func (req *Request) Build() (*http.Request, error) {
+	if req.err != nil {
+		return nil, req.err
+	}
+
+	u, err := url.Parse(req.client.baseURL + req.path)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.query) > 0 {
+		q := u.Query()
+		for key, values := range req.query {
+			for _, value := range values {
+				q.Add(key, value)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequestWithContext(req.ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range req.header {
+		r.Header[key] = values
+	}
+	for _, cookie := range req.cookies {
+		r.AddCookie(cookie)
+	}
+	return r, nil
+}
+
+// Do sends the request, and returns the Response. The test is failed immediately if the request
+// cannot be built or sent. The response body is read in full, so there is no need to close it.
+func (req *Request) Do(t testing.TB) *Response {
+	t.Helper()
+	r, err := req.Build()
+	if err != nil {
+		t.Fatalf("rmhttptest: failed to build request: %v", err)
+		return nil
+	}
+
+	res, err := req.client.client.Do(r)
+	if err != nil {
+		t.Fatalf("rmhttptest: %s %s failed: %v", req.method, req.path, err)
+		return nil
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("rmhttptest: failed to read response body: %v", err)
+		return nil
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return &Response{Response: res, t: t, body: body}
+}
@@ -0,0 +1,76 @@
+package rmhttptest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// REQUEST TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_Request_Build checks that each builder method is reflected in the built http.Request.
+func Test_Request_Build(t *testing.T) {
+	client := &Client{baseURL: DefaultBaseURL}
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+
+	r, err := client.Post("/users?sort=name").
+		Query("page", "2").
+		Header("X-Test", "test").
+		Cookie(&http.Cookie{Name: "session", Value: "abc"}).
+		BearerToken("token").
+		JSON(map[string]int{"age": 36}).
+		WithContext(ctx).
+		Build()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "/users", r.URL.Path)
+	assert.Equal(t, url.Values{"sort": {"name"}, "page": {"2"}}, r.URL.Query())
+	assert.Equal(t, "test", r.Header.Get("X-Test"))
+	assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, "value", r.Context().Value(key{}))
+
+	cookie, err := r.Cookie("session")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", cookie.Value)
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"age": 36}`, string(body))
+}
+
+// Test_Request_Form checks that form values and basic auth credentials are encoded.
+func Test_Request_Form(t *testing.T) {
+	client := &Client{baseURL: DefaultBaseURL}
+
+	r, err := client.NewRequest("put", "/form").
+		BasicAuth("ada", "secret").
+		Form(url.Values{"name": {"Ada Lovelace"}}).
+		Build()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPut, r.Method)
+	assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+	username, password, ok := r.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "ada", username)
+	assert.Equal(t, "secret", password)
+	require.NoError(t, r.ParseForm())
+	assert.Equal(t, "Ada Lovelace", r.PostForm.Get("name"))
+}
+
+// Test_Request_Build_errors checks that encoding errors are reported when the request is built.
+func Test_Request_Build_errors(t *testing.T) {
+	client := &Client{baseURL: DefaultBaseURL}
+	_, err := client.Post("/").JSON(make(chan int)).Build()
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package rmhttptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------------------------------------------------------------
+// RESPONSE
+// ------------------------------------------------------------------------------------------------
+
+// Response wraps the http.Response returned by Request.Do, and provides chained assertions. Each
+// assertion reports failures with t.Errorf, so that every failing assertion in a chain is shown.
+type Response struct {
+	*http.Response
+	t    testing.TB
+	body []byte
+}
+
+// Bytes returns the response body.
+func (res *Response) Bytes() []byte {
+	return res.body
+}
+
+// String returns the response body as a string.
+func (res *Response) String() string {
+	return string(res.body)
+}
+
+// DecodeJSON decodes the response body into the passed value, failing the test if it cannot be
+// decoded.
+func (res *Response) DecodeJSON(v any) *Response {
+	res.t.Helper()
+	if err := json.Unmarshal(res.body, v); err != nil {
+		res.t.Errorf("rmhttptest: failed to decode JSON response body: %v", err)
+	}
+	return res
+}
+
+// AssertStatus checks the response status code.
+func (res *Response) AssertStatus(code int) *Response {
+	res.t.Helper()
+	if res.StatusCode != code {
+		res.t.Errorf("expected status %d, got %d", code, res.StatusCode)
+	}
+	return res
+}
+
+// AssertHeader checks that the response header has the passed value.
+func (res *Response) AssertHeader(key string, value string) *Response {
+	res.t.Helper()
+	if actual := res.Header.Get(key); actual != value {
+		res.t.Errorf("expected header %s to be %q, got %q", key, value, actual)
+	}
+	return res
+}
+
+// AssertHeaderContains checks that the response header contains the passed substring.
+func (res *Response) AssertHeaderContains(key string, substr string) *Response {
+	res.t.Helper()
+	if actual := res.Header.Get(key); !strings.Contains(actual, substr) {
+		res.t.Errorf("expected header %s to contain %q, got %q", key, substr, actual)
+	}
+	return res
+}
+
+// AssertHeaderMissing checks that the response header has not been set.
+func (res *Response) AssertHeaderMissing(key string) *Response {
+	res.t.Helper()
+	if values := res.Header.Values(key); len(values) > 0 {
+		res.t.Errorf("expected header %s to be missing, got %q", key, values)
+	}
+	return res
+}
+
+// AssertCookie checks that the response sets a cookie with the passed name and value.
+func (res *Response) AssertCookie(name string, value string) *Response {
+	res.t.Helper()
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == name {
+			if cookie.Value != value {
+				res.t.Errorf("expected cookie %s to be %q, got %q", name, value, cookie.Value)
+			}
+			return res
+		}
+	}
+	res.t.Errorf("expected cookie %s to be set", name)
+	return res
+}
+
+// AssertBody checks that the response body is equal to the passed string.
+func (res *Response) AssertBody(body string) *Response {
+	res.t.Helper()
+	if actual := string(res.body); actual != body {
+		res.t.Errorf("expected body %q, got %q", body, actual)
+	}
+	return res
+}
+
+// AssertBodyContains checks that the response body contains the passed substring.
+func (res *Response) AssertBodyContains(substr string) *Response {
+	res.t.Helper()
+	if !bytes.Contains(res.body, []byte(substr)) {
+		res.t.Errorf("expected body to contain %q, got %q", substr, string(res.body))
+	}
+	return res
+}
+
+// AssertJSON checks that the response body is JSON equivalent to the passed value. The value is
+// encoded to JSON and decoded again before comparison, so structs, maps and numeric types can be
+// used interchangeably.
+func (res *Response) AssertJSON(expected any) *Response {
+	res.t.Helper()
+	var actual any
+	if err := json.Unmarshal(res.body, &actual); err != nil {
+		res.t.Errorf("expected a JSON body: %v", err)
+		return res
+	}
+	if err := compareJSON(expected, actual); err != nil {
+		res.t.Errorf("unexpected JSON body: %v", err)
+	}
+	return res
+}
+
+// AssertJSONPath checks the value at the passed path within the JSON response body. Path
+// segments are separated by dots, and array elements are selected by index, either as a segment
+// or in brackets, so items.0.name and items[0].name are equivalent. See AssertJSON for how the
+// values are compared.
+func (res *Response) AssertJSONPath(path string, expected any) *Response {
+	res.t.Helper()
+	var document any
+	if err := json.Unmarshal(res.body, &document); err != nil {
+		res.t.Errorf("expected a JSON body: %v", err)
+		return res
+	}
+	actual, err := lookupJSONPath(document, path)
+	if err != nil {
+		res.t.Errorf("JSON path %s: %v", path, err)
+		return res
+	}
+	if err := compareJSON(expected, actual); err != nil {
+		res.t.Errorf("JSON path %s: %v", path, err)
+	}
+	return res
+}
+
+// lookupJSONPath returns the value at the passed path within a decoded JSON document.
+func lookupJSONPath(document any, path string) (any, error) {
+	path = strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+	current := document
+	for segment := range strings.SplitSeq(path, ".") {
+		if segment == "" {
+			continue
+		}
+		switch v := current.(type) {
+		case map[string]any:
+			value, ok := v[segment]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", segment)
+			}
+			current = value
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf(
+					"index %q out of range for array of length %d",
+					segment,
+					len(v),
+				)
+			}
+			current = v[i]
+		default:
+			return nil, fmt.Errorf("cannot select %q from %T", segment, current)
+		}
+	}
+	return current, nil
+}
+
+// compareJSON normalises the expected value via a JSON round trip, and compares it with the
+// decoded actual value.
+func compareJSON(expected any, actual any) error {
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return fmt.Errorf("cannot encode expected value: %w", err)
+	}
+	var normalised any
+	if err := json.Unmarshal(data, &normalised); err != nil {
+		return fmt.Errorf("cannot decode expected value: %w", err)
+	}
+	if !reflect.DeepEqual(normalised, actual) {
+		got, _ := json.Marshal(actual)
+		return fmt.Errorf("expected %s, got %s", data, got)
+	}
+	return nil
+}
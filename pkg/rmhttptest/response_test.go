@@ -0,0 +1,97 @@
+package rmhttptest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rmhubbert/rmhttp/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// ------------------------------------------------------------------------------------------------
+// RESPONSE TESTS
+// ------------------------------------------------------------------------------------------------
+
+// newJSONClient creates a Client for an App that responds with a fixed JSON document.
+func newJSONClient() *Client {
+	app := rmhttp.New()
+	app.Get("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"user": {"name": "Ada", "age": 36, "tags": ["math", "code"]}}`))
+	})
+	return NewClient(app)
+}
+
+// Test_Response_assertions_pass checks that passing assertions do not fail the test.
+func Test_Response_assertions_pass(t *testing.T) {
+	rt := &recordingT{TB: t}
+	var decoded struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+
+	newJSONClient().Get("/user").
+		Do(rt).
+		AssertStatus(http.StatusOK).
+		AssertHeader("Content-Type", "application/json; charset=utf-8").
+		AssertHeaderContains("Content-Type", "json").
+		AssertHeaderMissing("X-Missing").
+		AssertBodyContains(`"name": "Ada"`).
+		AssertJSONPath("user.name", "Ada").
+		AssertJSONPath("user.age", 36).
+		AssertJSONPath("user.tags[1]", "code").
+		AssertJSONPath("user.tags", []string{"math", "code"}).
+		AssertJSON(map[string]any{
+			"user": map[string]any{"name": "Ada", "age": 36.0, "tags": []string{"math", "code"}},
+		}).
+		DecodeJSON(&decoded)
+
+	assert.Empty(t, rt.errors)
+	assert.Equal(t, "Ada", decoded.User.Name)
+}
+
+// Test_Response_assertions_fail checks that each failing assertion is reported, and that the
+// chain continues after a failure.
+func Test_Response_assertions_fail(t *testing.T) {
+	rt := &recordingT{TB: t}
+
+	newJSONClient().Get("/user").
+		Do(rt).
+		AssertStatus(http.StatusTeapot).
+		AssertHeader("Content-Type", "text/plain").
+		AssertHeaderContains("Content-Type", "xml").
+		AssertHeaderMissing("Content-Type").
+		AssertCookie("session", "abc").
+		AssertBody("nope").
+		AssertBodyContains("nope").
+		AssertJSONPath("user.name", "Grace").
+		AssertJSONPath("user.missing", "value").
+		AssertJSONPath("user.tags.5", "value").
+		AssertJSONPath("user.name.first", "value").
+		AssertJSON(map[string]any{})
+
+	assert.Len(t, rt.errors, 12)
+}
+
+// Test_lookupJSONPath checks path parsing against a decoded JSON document.
+func Test_lookupJSONPath(t *testing.T) {
+	document := map[string]any{
+		"items": []any{map[string]any{"name": "first"}, map[string]any{"name": "second"}},
+	}
+
+	value, err := lookupJSONPath(document, "items[1].name")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", value)
+
+	value, err = lookupJSONPath(document, "items.0.name")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value)
+
+	value, err = lookupJSONPath(document, "")
+	assert.NoError(t, err)
+	assert.Equal(t, document, value)
+
+	_, err = lookupJSONPath(document, "items.x")
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+// Package rmhttptest provides utilities for testing rmhttp Apps.
+//
+// A Client sends requests through the App's fully compiled stack (middleware, headers, timeouts
+// and error handlers) using an in-memory transport, so no network connection is needed. Requests
+// are built fluently, and the Response offers chained assertions.
+//
+// Example:
+//
+//	app := rmhttp.New()
+//	app.Post("/users", createUser)
+//
+//	client := rmhttptest.NewClient(app)
+//	client.Post("/users").
+//		JSON(map[string]any{"name": "Ada"}).
+//		Do(t).
+//		AssertStatus(http.StatusCreated).
+//		AssertHeader("Content-Type", "application/json").
+//		AssertJSONPath("user.name", "Ada")
+//
+// Pass WithServer to NewClient to start a real Server on an ephemeral port instead, which is
+// useful for end to end tests.
+package rmhttptest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+
+	"github.com/rmhubbert/rmhttp/v5"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CLIENT
+// ------------------------------------------------------------------------------------------------
+
+// DefaultBaseURL is the base URL used for requests sent via the in-memory transport.
+const DefaultBaseURL = "http://example.com"
+
+// An Option configures a Client.
+type Option func(*Client)
+
+// WithServer starts a real Server for the App on an ephemeral port on 127.0.0.1, and sends
+// requests to it over the network. The Client should be closed once the test has finished.
+func WithServer() Option {
+	return func(c *Client) {
+		c.startServer = true
+	}
+}
+
+// Client sends requests to an App. Cookies set by the App are stored, and sent with subsequent
+// requests. Redirects are not followed, so that they can be asserted on.
+type Client struct {
+	app         *rmhttp.App
+	client      *http.Client
+	baseURL     string
+	startServer bool
+	serverErr   chan error
+}
+
+// NewClient compiles the passed App, and returns a Client that sends requests to it. Routes
+// should be registered before the Client is created.
+//
+// Any error that occurs whilst starting the Server (when WithServer has been passed) will cause a
+// panic.
+func NewClient(app *rmhttp.App, options ...Option) *Client {
+	jar, _ := cookiejar.New(nil)
+	c := &Client{
+		app:     app,
+		baseURL: DefaultBaseURL,
+		client: &http.Client{
+			Jar: jar,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	if !c.startServer {
+		app.Compile()
+		c.client.Transport = Transport(app)
+		return c
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("cannot start test server: %v", err))
+	}
+	c.baseURL = "http://" + ln.Addr().String()
+	c.client.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	c.serverErr = make(chan error, 1)
+	go func() {
+		c.serverErr <- app.Serve(ln)
+	}()
+	return c
+}
+
+// URL returns the base URL that requests are sent to.
+func (c *Client) URL() string {
+	return c.baseURL
+}
+
+// HTTPClient returns the underlying http.Client, which can be used to send requests directly.
+func (c *Client) HTTPClient() *http.Client {
+	return c.client
+}
+
+// Close shuts down the Server, if one was started. It is safe to call on any Client.
+func (c *Client) Close() error {
+	if c.serverErr == nil {
+		return nil
+	}
+	if err := c.app.Shutdown(context.Background()); err != nil {
+		return err
+	}
+	if err := <-c.serverErr; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// NewRequest creates a Request with the passed method and path.
+func (c *Client) NewRequest(method string, path string) *Request {
+	return newRequest(c, method, path)
+}
+
+// Get creates a GET Request for the passed path.
+func (c *Client) Get(path string) *Request {
+	return c.NewRequest(http.MethodGet, path)
+}
+
+// Head creates a HEAD Request for the passed path.
+func (c *Client) Head(path string) *Request {
+	return c.NewRequest(http.MethodHead, path)
+}
+
+// Post creates a POST Request for the passed path.
+func (c *Client) Post(path string) *Request {
+	return c.NewRequest(http.MethodPost, path)
+}
+
+// Put creates a PUT Request for the passed path.
+func (c *Client) Put(path string) *Request {
+	return c.NewRequest(http.MethodPut, path)
+}
+
+// Patch creates a PATCH Request for the passed path.
+func (c *Client) Patch(path string) *Request {
+	return c.NewRequest(http.MethodPatch, path)
+}
+
+// Delete creates a DELETE Request for the passed path.
+func (c *Client) Delete(path string) *Request {
+	return c.NewRequest(http.MethodDelete, path)
+}
+
+// Options creates an OPTIONS Request for the passed path.
+func (c *Client) Options(path string) *Request {
+	return c.NewRequest(http.MethodOptions, path)
+}
+
+// ------------------------------------------------------------------------------------------------
+// TRANSPORT
+// ------------------------------------------------------------------------------------------------
+
+// Transport returns an http.RoundTripper that serves every request in memory with the passed
+// http.Handler. Requests are converted into server requests (with RequestURI and RemoteAddr set),
+// and the response is recorded.
+func Transport(handler http.Handler) http.RoundTripper {
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		r := req.Clone(req.Context())
+		r.RequestURI = req.URL.RequestURI()
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Host = req.URL.Host
+		if r.Body == nil {
+			r.Body = http.NoBody
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		res := w.Result()
+		res.Request = req
+		return res, nil
+	})
+}
+
+// roundTripper adapts a function into an http.RoundTripper.
+type roundTripper func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls the function.
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
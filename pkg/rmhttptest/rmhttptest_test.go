@@ -0,0 +1,120 @@
+package rmhttptest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rmhubbert/rmhttp/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// CLIENT TESTS
+// ------------------------------------------------------------------------------------------------
+
+// recordingT is a testing.TB that records failures instead of reporting them, so that the
+// assertions themselves can be tested.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+// Helper does nothing.
+func (t *recordingT) Helper() {}
+
+// Errorf records the failure.
+func (t *recordingT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, format)
+}
+
+// newTestApp creates an App with a selection of routes for testing the Client.
+func newTestApp() *rmhttp.App {
+	app := rmhttp.New()
+	app.WithHeader("X-Global", "global")
+	app.Get("/hello/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello " + r.PathValue("name") + " from " + r.RemoteAddr))
+	})
+	app.Post("/echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.Copy(w, r.Body)
+	})
+	app.Get("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+	})
+	app.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(cookie.Value))
+	})
+	app.Redirect("/old", "/new", http.StatusMovedPermanently)
+	app.StatusNotFoundHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("custom not found"))
+	})
+	return app
+}
+
+// Test_NewClient checks that requests are served in memory through the compiled App, including
+// global headers and error handlers.
+func Test_NewClient(t *testing.T) {
+	client := NewClient(newTestApp())
+	defer func() {
+		assert.NoError(t, client.Close())
+	}()
+	assert.Equal(t, DefaultBaseURL, client.URL())
+
+	client.Get("/hello/ada").
+		Do(t).
+		AssertStatus(http.StatusOK).
+		AssertHeader("X-Global", "global").
+		AssertBody("hello ada from 192.0.2.1:1234")
+
+	client.Get("/missing").
+		Do(t).
+		AssertStatus(http.StatusNotFound).
+		AssertBody("custom not found")
+
+	client.Get("/old").
+		Do(t).
+		AssertStatus(http.StatusMovedPermanently).
+		AssertHeader("Location", "/new")
+}
+
+// Test_Client_cookies checks that cookies set by the App are sent with later requests.
+func Test_Client_cookies(t *testing.T) {
+	client := NewClient(newTestApp())
+
+	client.Get("/whoami").Do(t).AssertStatus(http.StatusUnauthorized)
+	client.Get("/login").Do(t).AssertCookie("session", "abc")
+	client.Get("/whoami").Do(t).AssertStatus(http.StatusOK).AssertBody("abc")
+}
+
+// Test_NewClient_WithServer checks that a real Server is started on an ephemeral port.
+func Test_NewClient_WithServer(t *testing.T) {
+	client := NewClient(newTestApp(), WithServer())
+	require.True(t, strings.HasPrefix(client.URL(), "http://127.0.0.1:"))
+
+	res, err := client.HTTPClient().Get(client.URL() + "/hello/grace")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "hello grace from 127.0.0.1:")
+
+	client.Post("/echo").
+		JSON(map[string]string{"name": "grace"}).
+		Do(t).
+		AssertStatus(http.StatusCreated).
+		AssertJSONPath("name", "grace")
+
+	require.NoError(t, client.Close())
+	_, err = client.HTTPClient().Get(client.URL() + "/hello/grace")
+	assert.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package rmhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------------------------
+// PROTOCOLS
+// ------------------------------------------------------------------------------------------------
+
+// Protocol names accepted by ServerConfig.HTTPProtocols.
+const (
+	ProtocolHTTP1 = "http1"
+	ProtocolHTTP2 = "h2"
+	ProtocolH2C   = "h2c"
+)
+
+// ParseProtocols converts a list of protocol names into an http.Protocols. The names http1, h2
+// and h2c are accepted (case insensitively), along with the aliases http/1.1 and http2. An error
+// is returned for unknown names, or if no names are passed.
+func ParseProtocols(names []string) (*http.Protocols, error) {
+	p := &http.Protocols{}
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case ProtocolHTTP1, "http/1.1":
+			p.SetHTTP1(true)
+		case ProtocolHTTP2, "http2":
+			p.SetHTTP2(true)
+		case ProtocolH2C:
+			p.SetUnencryptedHTTP2(true)
+		case "":
+			continue
+		default:
+			return nil, fmt.Errorf("unknown HTTP protocol: %s", name)
+		}
+	}
+	if !p.HTTP1() && !p.HTTP2() && !p.UnencryptedHTTP2() {
+		return nil, errors.New("at least one HTTP protocol must be enabled")
+	}
+	return p, nil
+}
+
+// validateProtocols checks that the passed protocols can actually be served on a listener with
+// or without TLS. HTTP/2 over TLS cannot be negotiated on a plain listener, and h2c is never used
+// over TLS, so a listener that only has one of those available would reject every client.
+func validateProtocols(p *http.Protocols, tls bool) error {
+	if p == nil {
+		return nil
+	}
+	if tls && !p.HTTP1() && !p.HTTP2() {
+		return errors.New("no HTTP protocols are available over TLS: enable http1 or h2")
+	}
+	if !tls && !p.HTTP1() && !p.UnencryptedHTTP2() {
+		return errors.New("no HTTP protocols are available without TLS: enable http1 or h2c")
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package rmhttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// PROTOCOLS TESTS
+// ------------------------------------------------------------------------------------------------
+
+// Test_ParseProtocols checks that protocol names are converted into an http.Protocols.
+func Test_ParseProtocols(t *testing.T) {
+	tests := []struct {
+		name          string
+		names         []string
+		expectedHTTP1 bool
+		expectedHTTP2 bool
+		expectedH2C   bool
+		errorExpected bool
+	}{
+		{"http1 only", []string{"http1"}, true, false, false, false},
+		{"http1 and h2", []string{"http1", "h2"}, true, true, false, false},
+		{"h2 only", []string{"h2"}, false, true, false, false},
+		{"all with aliases", []string{" HTTP/1.1 ", "http2", "H2C"}, true, true, true, false},
+		{"empty names are ignored", []string{"", "h2c"}, false, false, true, false},
+		{"unknown name", []string{"http3"}, false, false, false, true},
+		{"no protocols", []string{}, false, false, false, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ParseProtocols(test.names)
+			if test.errorExpected {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedHTTP1, p.HTTP1())
+			assert.Equal(t, test.expectedHTTP2, p.HTTP2())
+			assert.Equal(t, test.expectedH2C, p.UnencryptedHTTP2())
+		})
+	}
+}
+
+// Test_validateProtocols checks that protocol combinations that cannot be served are rejected.
+func Test_validateProtocols(t *testing.T) {
+	tests := []struct {
+		name          string
+		names         []string
+		tls           bool
+		errorExpected bool
+	}{
+		{"h2 only with TLS", []string{"h2"}, true, false},
+		{"h2 only without TLS", []string{"h2"}, false, true},
+		{"h2c only without TLS", []string{"h2c"}, false, false},
+		{"h2c only with TLS", []string{"h2c"}, true, true},
+		{"http1 with TLS", []string{"http1"}, true, false},
+		{"http1 without TLS", []string{"http1"}, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := ParseProtocols(test.names)
+			require.NoError(t, err)
+			err = validateProtocols(p, test.tls)
+			if test.errorExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	assert.NoError(t, validateProtocols(nil, false))
+}
+
+// Test_NewServer_HTTPProtocols checks that explicit protocols replace the defaults, and that
+// invalid protocols are reported when the Server starts.
+func Test_NewServer_HTTPProtocols(t *testing.T) {
+	srv := NewServer(ServerConfig{
+		Host:          "127.0.0.1",
+		Port:          freePort(t),
+		HTTPProtocols: []string{"http1", "h2"},
+	}, http.NewServeMux())
+	assert.True(t, srv.Server.Protocols.HTTP1())
+	assert.True(t, srv.Server.Protocols.HTTP2())
+	assert.False(t, srv.Server.Protocols.UnencryptedHTTP2())
+
+	srv = NewServer(ServerConfig{
+		Host:          "127.0.0.1",
+		Port:          freePort(t),
+		HTTPProtocols: []string{"h2"},
+	}, http.NewServeMux())
+	assert.ErrorContains(t, srv.ListenAndServe(), "no HTTP protocols are available without TLS")
+
+	srv = NewServer(ServerConfig{
+		Host:          "127.0.0.1",
+		Port:          freePort(t),
+		HTTPProtocols: []string{"spdy"},
+	}, http.NewServeMux())
+	assert.True(t, srv.Server.Protocols.UnencryptedHTTP2(), "defaults should be kept")
+	assert.ErrorContains(t, srv.ListenAndServe(), "unknown HTTP protocol")
+
+	srv = NewServer(ServerConfig{
+		Host:          "127.0.0.1",
+		Port:          freePort(t),
+		HTTPProtocols: []string{"h2"},
+	}, http.NewServeMux())
+	srv.AddListener(Listener{Host: "127.0.0.1", Port: freePort(t)})
+	assert.ErrorContains(t, srv.ListenAndServeTLS("cert.pem", "key.pem"), "127.0.0.1")
+}
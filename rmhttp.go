@@ -55,15 +55,29 @@
 //   - Server.Start() should be called from a single goroutine
 //
 // The underlying http.ServeMux is used for thread-safe route matching.
+//
+// # Using the App as an http.Handler
+//
+// The App implements http.Handler, so it can be embedded in another server, passed to
+// httptest.NewServer, or wrapped by an adapter. The App is compiled exactly once, on the first
+// request (or when it starts serving), and requests are then dispatched through the most
+// efficient router. Everything must be registered before then, as any later registration panics
+// with ErrAlreadyCompiled.
 package rmhttp
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/headers"
+	"github.com/rmhubbert/rmhttp/v5/pkg/middleware/recoverer"
 )
 
 // ------------------------------------------------------------------------------------------------
@@ -77,6 +91,14 @@ type App struct {
 	Router        *Router
 	rootGroup     *Group
 	errorHandlers map[int]http.Handler
+	config        Config
+	compileOnce   sync.Once
+	handler       http.Handler
+	// debugChains holds the middleware names for each compiled route pattern in debug mode.
+	debugChains map[string][]string
+
+	// Admin is the admin server, if one has been configured via Config.Admin.
+	Admin *AdminServer
 }
 
 // New creates, initialises and returns a pointer to a new App. An optional configuration can be
@@ -91,7 +113,7 @@ func New(c ...Config) *App {
 	}
 	config, err := LoadConfig(cfg)
 	if err != nil {
-		panic("cannot load config")
+		panic(fmt.Sprintf("cannot load config: %v", err))
 	}
 
 	router := NewRouter()
@@ -99,21 +121,41 @@ func New(c ...Config) *App {
 		config.Server,
 		router,
 	)
-	server.maybeUpdateTimeout(time.Duration(config.Server.RequestTimeout) * time.Second)
+	server.maybeUpdateTimeout(
+		effectiveTimeout(config.Server.RequestTimeout, config.Server.RequestTimeoutDuration),
+	)
 
 	rootGroup := NewGroup("")
 
 	errorHandlers := map[int]http.Handler{
-		http.StatusNotFound:         createDefaultHandler(http.StatusNotFound),
-		http.StatusMethodNotAllowed: createDefaultHandler(http.StatusMethodNotAllowed),
+		http.StatusNotFound:            createDefaultHandler(http.StatusNotFound),
+		http.StatusMethodNotAllowed:    createDefaultHandler(http.StatusMethodNotAllowed),
+		http.StatusInternalServerError: createDefaultHandler(http.StatusInternalServerError),
 	}
 
-	return &App{
+	app := &App{
 		Server:        server,
 		Router:        router,
 		rootGroup:     rootGroup,
 		errorHandlers: errorHandlers,
+		config:        config,
+		debugChains:   map[string][]string{},
+	}
+	if config.Debug {
+		for code := range errorHandlers {
+			errorHandlers[code] = app.debugErrorHandler(code)
+		}
+	}
+	if config.Admin.Port > 0 {
+		app.Admin = newAdminServer(config.Admin, app)
 	}
+	return app
+}
+
+// Settings returns every effective config setting for the App, along with its source, with
+// secrets redacted. See Config.Settings for details.
+func (app *App) Settings() []Setting {
+	return app.config.Settings()
 }
 
 // Handle binds the passed http.Handler to the specified route method and pattern.
@@ -238,6 +280,7 @@ func (app *App) Redirect(pattern string, target string, code int) *Route {
 
 // StatusNotFoundHandler registers a handler to be used when an internal 404 error is thrown.
 func (app *App) StatusNotFoundHandler(handler http.HandlerFunc) {
+	app.rootGroup.checkNotSealed("set the 404 handler")
 	app.errorHandlers[http.StatusNotFound] = http.HandlerFunc(handler)
 }
 
@@ -245,9 +288,41 @@ func (app *App) StatusNotFoundHandler(handler http.HandlerFunc) {
 func (app *App) StatusMethodNotAllowedHandler(
 	handler http.HandlerFunc,
 ) {
+	app.rootGroup.checkNotSealed("set the 405 handler")
 	app.errorHandlers[http.StatusMethodNotAllowed] = http.HandlerFunc(handler)
 }
 
+// StatusForbiddenHandler registers a handler to be used when a 403 error is raised, such as by the
+// csrf middleware via ErrorHandler.
+func (app *App) StatusForbiddenHandler(handler http.HandlerFunc) {
+	app.rootGroup.checkNotSealed("set the 403 handler")
+	app.errorHandlers[http.StatusForbidden] = http.HandlerFunc(handler)
+}
+
+// StatusInternalServerErrorHandler registers a handler to be used when a 500 error is raised, such
+// as by the recoverer middleware via ErrorHandler.
+func (app *App) StatusInternalServerErrorHandler(handler http.HandlerFunc) {
+	app.rootGroup.checkNotSealed("set the 500 handler")
+	app.errorHandlers[http.StatusInternalServerError] = http.HandlerFunc(handler)
+}
+
+// ErrorHandler returns a handler that responds using the error handler registered for the passed
+// status code, or with the status text if there is none. The registered handler is looked up on
+// each request, so ErrorHandler can be passed to middleware before the error handlers are set.
+//
+// Global middleware is not applied, as the returned handler is intended to be called from within
+// it, such as by the recoverer middleware.
+func (app *App) ErrorHandler(code int) http.Handler {
+	fallback := createDefaultHandler(code)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := app.errorHandlers[code]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
 // Group creates, initialises, and returns a pointer to a Route Group.
 //
 // This is typically used to create new Routes as part of the Group, but can also be used to add
@@ -302,6 +377,16 @@ func (app *App) WithMiddleware(middlewares ...func(http.Handler) http.Handler) *
 	return app
 }
 
+// WithHealthChecks registers liveness and readiness handlers for the passed HealthRegistry at
+// DefaultLivenessPath and DefaultReadinessPath.
+//
+// This method will return a pointer to the app, allowing the user to chain any of the other
+// builder methods that the app implements.
+func (app *App) WithHealthChecks(health *HealthRegistry) *App {
+	health.mount(app.rootGroup)
+	return app
+}
+
 // Use is a convenience method for adding global middleware handlers. It uses WithMiddleware
 // behind the scenes.
 //
@@ -314,11 +399,43 @@ func (app *App) Use(middlewares ...func(http.Handler) http.Handler) *App {
 
 // Compile prepares the app for starting by applying the middleware, and loading the Routes. It
 // should be the last function to be called before starting the Server.
+//
+// The App is only ever compiled once, so it is safe to call this method multiple times, and from
+// multiple goroutines. Once compiled, any further registration will panic with
+// ErrAlreadyCompiled.
 func (app *App) Compile() {
+	app.compileOnce.Do(func() {
+		app.rootGroup.seal()
+		app.compile()
+		app.handler = app.Router.BestHandler()
+	})
+}
+
+// ServeHTTP allows the App to fulfill the http.Handler interface. The App is compiled on the first
+// call, and each request is then dispatched through the most efficient router.
+func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	app.Compile()
+	app.handler.ServeHTTP(w, r)
+}
+
+// compile applies the middleware to each Route, and loads them, along with the error handlers,
+// into the Router.
+func (app *App) compile() {
 	routes := app.rootGroup.ComputedRoutes()
 
+	// In debug mode, every route recovers panics with the developer error page.
+	var debugRecoverer func(http.Handler) http.Handler
+	if app.config.Debug {
+		debugRecoverer = recoverer.Middleware(recoverer.Options{
+			ErrorHandler: app.ErrorHandler(http.StatusInternalServerError),
+		})
+	}
+
 	for _, route := range routes {
 		middleware := []func(http.Handler) http.Handler{}
+		if debugRecoverer != nil {
+			middleware = append(middleware, debugRecoverer)
+		}
 
 		if len(route.ComputedHeaders()) > 0 {
 			middleware = append(middleware, headers.Middleware(route.ComputedHeaders()))
@@ -336,6 +453,10 @@ func (app *App) Compile() {
 			middleware = append(middleware, TimeoutMiddleware(route.ComputedTimeout()))
 		}
 
+		if app.config.Debug {
+			app.debugChains[route.Method+" "+route.ComputedPattern()] = middlewareNames(middleware)
+		}
+
 		var handler = route.Handler
 		if len(middleware) > 0 {
 			handler = applyMiddleware(
@@ -349,6 +470,9 @@ func (app *App) Compile() {
 
 	// Add the error handlers to the router with any global middleware added.
 	for code, errorHandler := range app.errorHandlers {
+		if app.config.Debug && code == http.StatusNotFound {
+			errorHandler = app.logNotFound(errorHandler)
+		}
 		app.Router.AddErrorHandler(code, applyMiddleware(errorHandler, app.rootGroup.Middleware))
 	}
 }
@@ -356,17 +480,55 @@ func (app *App) Compile() {
 // ListenAndServe compiles and loads the registered Routes, and then starts the Server without SSL.
 func (app *App) ListenAndServe() error {
 	app.Compile()
-	return app.Server.ListenAndServe()
+	return app.serve(app.Server.ListenAndServe)
 }
 
 // ListenAndServeTLS compiles and loads the registered Routes, and then starts the Server with the
 // SSL certificate and key at the file paths passed as the arguments.
 func (app *App) ListenAndServeTLS(cert string, key string) error {
 	app.Compile()
-	return app.Server.ListenAndServeTLS(cert, key)
+	return app.serve(func() error {
+		return app.Server.ListenAndServeTLS(cert, key)
+	})
 }
 
-// Shutdown stops the Server.
+// Serve compiles and loads the registered Routes, and then starts the Server without SSL on the
+// passed net.Listener.
+func (app *App) Serve(ln net.Listener) error {
+	app.Compile()
+	return app.serve(func() error {
+		return app.Server.Serve(ln)
+	})
+}
+
+// serve starts the admin server (if configured), and then the main Server via the passed
+// function. The admin server reports the App as ready once the main Server has bound its
+// listeners. If the main Server fails, the admin server is closed immediately. Otherwise, it is
+// left running until Shutdown, so that readiness can be reported whilst draining.
+func (app *App) serve(start func() error) error {
+	if app.config.Debug {
+		app.writeBanner(os.Stderr)
+	}
+	if app.Admin != nil {
+		if err := app.Admin.start(); err != nil {
+			return err
+		}
+	}
+
+	err := start()
+	if app.Admin != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.Admin.close()
+	}
+	return err
+}
+
+// Shutdown stops the Server. If an admin server has been configured, it reports the App as not
+// ready as soon as the Server begins draining, and is itself stopped once the Server has
+// finished.
 func (app *App) Shutdown(ctx context.Context) error {
-	return app.Server.Shutdown(ctx)
+	err := app.Server.Shutdown(ctx)
+	if app.Admin != nil {
+		err = errors.Join(err, app.Admin.Shutdown(ctx))
+	}
+	return err
 }
@@ -2,11 +2,15 @@ package rmhttp
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // ------------------------------------------------------------------------------------------------
@@ -97,6 +101,117 @@ func Test_Pattern_MethodSpecific(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+// Test_App_ServeHTTP checks that the App can be used directly as an http.Handler, compiling once
+// on first use, even when the first requests arrive concurrently.
+func Test_App_ServeHTTP(t *testing.T) {
+	app := New()
+	app.WithHeader("X-Global", "global")
+	app.Get("/hello", createTestHandlerFunc(http.StatusOK, "hello"))
+	app.StatusNotFoundHandler(createTestHandlerFunc(http.StatusNotFound, "custom not found"))
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			res, err := http.Get(srv.URL + "/hello")
+			if !assert.NoError(t, err) {
+				return
+			}
+			body, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			assert.Equal(t, "hello", string(body))
+			assert.Equal(t, "global", res.Header.Get("X-Global"))
+		})
+	}
+	wg.Wait()
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "custom not found", w.Body.String())
+
+	// Compiling again must not re-register the routes.
+	assert.NotPanics(t, app.Compile)
+}
+
+// Test_App_late_registration checks that changes made after the App has been compiled panic with
+// ErrAlreadyCompiled.
+func Test_App_late_registration(t *testing.T) {
+	app := New()
+	group := app.Group("/api")
+	route := app.Get("/hello", createTestHandlerFunc(http.StatusOK, "hello"))
+	app.Compile()
+
+	tests := []struct {
+		name     string
+		register func()
+	}{
+		{"route", func() { app.Get("/late", createTestHandlerFunc(http.StatusOK, "late")) }},
+		{"group route", func() { group.Get("/late", createTestHandlerFunc(http.StatusOK, "late")) }},
+		{"group", func() { app.Group("/late") }},
+		{"middleware", func() { app.Use(createTestMiddlewareHandler("x-late", "late")) }},
+		{"group header", func() { group.WithHeader("X-Late", "late") }},
+		{"route header", func() { route.WithHeader("X-Late", "late") }},
+		{"route timeout", func() { route.WithTimeout(time.Second, "late") }},
+		{"error handler", func() {
+			app.StatusNotFoundHandler(createTestHandlerFunc(http.StatusNotFound, "late"))
+		}},
+		{"403 error handler", func() {
+			app.StatusForbiddenHandler(createTestHandlerFunc(http.StatusForbidden, "late"))
+		}},
+		{"500 error handler", func() {
+			app.StatusInternalServerErrorHandler(
+				createTestHandlerFunc(http.StatusInternalServerError, "late"),
+			)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				err, ok := recover().(error)
+				require.True(t, ok, "expected a panic with an error")
+				assert.ErrorIs(t, err, ErrAlreadyCompiled)
+			}()
+			test.register()
+		})
+	}
+}
+
+// Test_App_ErrorHandler checks that ErrorHandler responds with the registered error handler, even
+// when it is registered after ErrorHandler is called, and falls back to the status text.
+func Test_App_ErrorHandler(t *testing.T) {
+	app := New()
+	internal := app.ErrorHandler(http.StatusInternalServerError)
+	forbidden := app.ErrorHandler(http.StatusForbidden)
+	teapot := app.ErrorHandler(http.StatusTeapot)
+
+	w := httptest.NewRecorder()
+	internal.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal Server Error", w.Body.String())
+
+	app.StatusInternalServerErrorHandler(
+		createTestHandlerFunc(http.StatusInternalServerError, "custom error"),
+	)
+	w = httptest.NewRecorder()
+	internal.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "custom error", w.Body.String())
+
+	app.StatusForbiddenHandler(createTestHandlerFunc(http.StatusForbidden, "custom forbidden"))
+	w = httptest.NewRecorder()
+	forbidden.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "custom forbidden", w.Body.String())
+
+	w = httptest.NewRecorder()
+	teapot.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "I'm a teapot", w.Body.String())
+}
+
 // Benchmark_Compile benchmarks the performance of compiling routes with middleware.
 // It sets up an app with multiple routes and groups to simulate real-world usage.
 func Benchmark_Compile(b *testing.B) {
@@ -118,9 +233,10 @@ func Benchmark_Compile(b *testing.B) {
 		g.Use(createTestMiddlewareHandler("x-group", fmt.Sprintf("group%d", i)))
 	}
 
-	// Benchmark Compile() - reset the router each iteration to avoid conflicts
+	// Benchmark compile() - reset the router each iteration to avoid conflicts. Compile() itself
+	// only ever compiles once.
 	for b.Loop() {
 		app.Router = NewRouter()
-		app.Compile()
+		app.compile()
 	}
 }
@@ -135,6 +135,7 @@ func (route *Route) findMiddleware(
 // This method will return a pointer to the receiver Route, allowing the user to chain any of the
 // other builder methods that Route implements.
 func (route *Route) WithMiddleware(middlewares ...func(http.Handler) http.Handler) *Route {
+	route.Parent.checkNotSealed("add middleware to route " + route.String())
 	route.Middleware = append(route.Middleware, middlewares...)
 	return route
 }
@@ -148,11 +149,22 @@ func (route *Route) Use(middlewares ...func(http.Handler) http.Handler) *Route {
 	return route.WithMiddleware(middlewares...)
 }
 
+// RequireClientCert adds middleware to the receiver Route that rejects requests with a 403
+// response, unless the client presented a verified TLS certificate that satisfies the passed
+// matcher. A nil matcher accepts any verified certificate.
+//
+// This method will return a pointer to the receiver Route, allowing the user to chain any of the
+// other builder methods that Route implements.
+func (route *Route) RequireClientCert(match func(*ClientIdentity) bool) *Route {
+	return route.WithMiddleware(RequireClientCertMiddleware(match))
+}
+
 // WithTimeout sets a request timeout amount and message for this route.
 //
 // This method will return a pointer to the receiver Route, allowing the user to chain any of the
 // other builder methods that Route implements.
 func (route *Route) WithTimeout(timeout time.Duration, message string) *Route {
+	route.Parent.checkNotSealed("set timeout on route " + route.String())
 	route.Timeout = NewTimeout(timeout, message)
 	return route
 }
@@ -163,6 +175,7 @@ func (route *Route) WithTimeout(timeout time.Duration, message string) *Route {
 // This method will return a pointer to the receiver Route, allowing the user to chain any of the
 // other builder methods that Route implements.
 func (route *Route) WithHeader(key, value string) *Route {
+	route.Parent.checkNotSealed("set header " + key + " on route " + route.String())
 	route.Headers[key] = value
 	return route
 }
@@ -82,6 +82,16 @@ func (rt *Router) AddErrorHandler(code int, handler http.Handler) {
 	rt.errorHandlers.LoadOrStore(code, handler)
 }
 
+// BestHandler returns the most efficient http.Handler for serving the Router's Routes. If no
+// custom error handlers have been registered, the Router doesn't need to intercept responses, so
+// the underlying http.ServeMux is returned. Otherwise, the Router itself is returned.
+func (rt *Router) BestHandler() http.Handler {
+	if rt.HasErrorHandlers() {
+		return rt
+	}
+	return rt.Mux
+}
+
 // HasErrorHandlers returns true if the Router has any error handlers registered.
 func (rt *Router) HasErrorHandlers() bool {
 	var count int
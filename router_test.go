@@ -139,3 +139,13 @@ func Test_Router_ConcurrentAccess(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "test body", w.Body.String())
 }
+
+// Test_Router_BestHandler checks that the ServeMux is used directly unless error handlers have
+// been registered.
+func Test_Router_BestHandler(t *testing.T) {
+	rt := NewRouter()
+	assert.Same(t, rt.Mux, rt.BestHandler())
+
+	rt.AddErrorHandler(http.StatusNotFound, http.NotFoundHandler())
+	assert.Same(t, rt, rt.BestHandler())
+}
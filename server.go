@@ -2,8 +2,11 @@ package rmhttp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"dario.cat/mergo"
@@ -20,7 +23,22 @@ type Server struct {
 	Router              http.Handler
 	Port                int
 	Host                string
+	ConnTracker         *ConnTracker
 	writeTimeoutPadding time.Duration
+	pinTimeouts         bool
+
+	mu                   sync.Mutex
+	listeners            []Listener
+	additional           []*http.Server
+	keepAlives           bool
+	hsts                 string
+	httpsPort            int
+	redirectExemptPrefix string
+	clientAuthErr        error
+	httpProtocols        []string
+
+	// onListening is called once every listener has been bound, just before serving begins.
+	onListening func()
 }
 
 // NewServer creates, initialises and returns a pointer to a Server.
@@ -48,8 +66,8 @@ func NewServer(
 		// Only override if the user explicitly set a value different from the zero value.
 		// Since we can't detect "touched" fields, we OR the values:
 		// if either default or user enables a protocol, it stays enabled.
-		// This means users can only disable protocols by providing a config
-		// that has ALL protocols set to false, then re-enabling the ones they want.
+		// This means protocols cannot be disabled this way; HTTPProtocols should be used
+		// for that instead.
 		if config.Protocols.HTTP1() {
 			protocols.SetHTTP1(true)
 		}
@@ -61,23 +79,75 @@ func NewServer(
 		}
 	}
 
+	// Explicit protocol names fully replace the defaults. Any error is reported when the Server
+	// starts, so the defaults are kept in the meantime.
+	if len(config.HTTPProtocols) > 0 {
+		if p, err := ParseProtocols(config.HTTPProtocols); err == nil {
+			protocols = p
+		}
+	}
+
+	readTimeout := effectiveTimeout(config.TCPReadTimeout, config.TCPReadTimeoutDuration)
+	readHeaderTimeout := effectiveTimeout(
+		config.TCPReadHeaderTimeout,
+		config.TCPReadHeaderTimeoutDuration,
+	)
+	writeTimeout := effectiveTimeout(config.TCPWriteTimeout, config.TCPWriteTimeoutDuration)
+	idleTimeout := effectiveTimeout(config.TCPIdleTimeout, config.TCPIdleTimeoutDuration)
+	writeTimeoutPadding := effectiveTimeout(
+		config.TCPWriteTimeoutPadding,
+		config.TCPWriteTimeoutPaddingDuration,
+	)
+
 	srv := Server{
 		Server: http.Server{
 			Handler:                      router,
-			ReadTimeout:                  time.Duration(config.TCPReadTimeout) * time.Second,
-			ReadHeaderTimeout:            time.Duration(config.TCPReadHeaderTimeout) * time.Second,
-			WriteTimeout:                 time.Duration(config.TCPWriteTimeout) * time.Second,
-			IdleTimeout:                  time.Duration(config.TCPIdleTimeout) * time.Second,
+			ReadTimeout:                  readTimeout,
+			ReadHeaderTimeout:            readHeaderTimeout,
+			WriteTimeout:                 writeTimeout,
+			IdleTimeout:                  idleTimeout,
+			MaxHeaderBytes:               config.MaxHeaderBytes,
 			DisableGeneralOptionsHandler: config.DisableGeneralOptionsHandler,
+			TLSConfig:                    config.TLSConfig,
+			TLSNextProto:                 config.TLSNextProto,
+			ConnState:                    config.ConnState,
+			ErrorLog:                     config.ErrorLog,
+			BaseContext:                  config.BaseContext,
+			ConnContext:                  config.ConnContext,
 			HTTP2:                        http2Config,
 			Protocols:                    protocols,
 		},
 		Router:              router,
 		Host:                config.Host,
 		Port:                config.Port,
-		writeTimeoutPadding: time.Duration(config.TCPWriteTimeoutPadding) * time.Second,
+		writeTimeoutPadding: writeTimeoutPadding,
+		pinTimeouts:         config.PinTCPTimeouts,
+		keepAlives:          config.TCPKeepAlive,
+		hsts: hstsHeaderValue(
+			config.HSTSMaxAge,
+			config.HSTSIncludeSubdomains,
+			config.HSTSPreload,
+		),
+		httpsPort:            config.HTTPSPort,
+		redirectExemptPrefix: config.HTTPSRedirectExemptPrefix,
+		httpProtocols:        config.HTTPProtocols,
+	}
+	if srv.redirectExemptPrefix == "" {
+		srv.redirectExemptPrefix = DefaultACMEChallengePrefix
 	}
 	srv.Server.Addr = fmt.Sprintf("%s:%d", config.Host, config.Port)
+	srv.Server.Handler = srv.withHSTS(router)
+
+	// Client auth is applied once, here, so that restarting the Server doesn't apply it again. Any
+	// error is reported when the Server starts.
+	if config.ClientAuth.Mode != "" {
+		tlsConfig, err := config.ClientAuth.apply(config.TLSConfig)
+		if err != nil {
+			srv.clientAuthErr = fmt.Errorf("failed to configure client auth: %w", err)
+		} else {
+			srv.Server.TLSConfig = tlsConfig
+		}
+	}
 
 	// Configure HTTP keep-alive settings
 	// Note: TCP-level keep-alive (for detecting dead connections in long-lived SSE)
@@ -86,15 +156,58 @@ func NewServer(
 		srv.Server.SetKeepAlivesEnabled(false)
 	}
 
+	// Chain the connection tracker in front of any user supplied ConnState hook, so that both
+	// see every state change. Idle connections are forcibly closed as soon as shutdown begins
+	// (after the listeners have been closed), rather than waiting for http.Server to poll for
+	// them.
+	if config.TrackConnections {
+		tracker := NewConnTracker()
+		userConnState := config.ConnState
+		srv.Server.ConnState = func(c net.Conn, state http.ConnState) {
+			tracker.ConnState(c, state)
+			if userConnState != nil {
+				userConnState(c, state)
+			}
+		}
+		srv.Server.RegisterOnShutdown(func() { tracker.CloseIdle() })
+		srv.ConnTracker = tracker
+	}
+
+	if config.HTTPRedirectPort > 0 {
+		srv.AddListener(Listener{
+			Host:            config.Host,
+			Port:            config.HTTPRedirectPort,
+			RedirectToHTTPS: true,
+		})
+	}
+
 	return &srv
 }
 
+// AddListener adds an additional address for the Server to accept connections on. The Listener
+// will be started and shut down together with the Server, and will serve the same routes
+// (unless it is configured to redirect to HTTPS). Listeners must be added before the Server is
+// started.
+//
+// This method will return a pointer to the Server, allowing the user to chain further calls.
+func (srv *Server) AddListener(listener Listener) *Server {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.listeners = append(srv.listeners, listener)
+	return srv
+}
+
 // maybeUpdateTimeout updates the http.Server read and write timeouts, if the passed duration
 // is longer than the current values. We do this to ensure that the TCP connection does not
 // timeout before the longest request timeout.
 //
+// The timeouts are never updated if they have been pinned via ServerConfig.PinTCPTimeouts.
+//
 // See https://adam-p.ca/blog/2022/01/golang-http-server-timeouts/
 func (srv *Server) maybeUpdateTimeout(timeout time.Duration) {
+	if srv.pinTimeouts {
+		return
+	}
 	readTimeout := timeout + srv.Server.ReadHeaderTimeout + srv.writeTimeoutPadding
 	writeTimeout := timeout + srv.writeTimeoutPadding
 	if readTimeout > srv.Server.ReadTimeout && writeTimeout > srv.Server.WriteTimeout {
@@ -108,29 +221,63 @@ func (srv *Server) maybeUpdateTimeout(timeout time.Duration) {
 // Router's underlying Mux.
 func (srv *Server) setBestRouter() {
 	if r, ok := srv.Router.(*Router); ok {
-		if !r.HasErrorHandlers() {
-			srv.Router = r.Mux
-			srv.Server.Handler = r.Mux
-		}
+		srv.Router = r.BestHandler()
+		srv.Server.Handler = srv.withHSTS(srv.Router)
 	}
 }
 
+// withHSTS returns the passed handler, wrapped to set the Strict-Transport-Security header if
+// HSTS has been configured.
+func (srv *Server) withHSTS(handler http.Handler) http.Handler {
+	if srv.hsts == "" || handler == nil {
+		return handler
+	}
+	return hstsHandler(srv.hsts, handler)
+}
+
 // ListenAndServe directly proxies the http.Server.ListenAndServe method. It starts the server
 // without TLS support on the configured address and port.
 func (srv *Server) ListenAndServe() error {
 	srv.setBestRouter()
-	return srv.Server.ListenAndServe()
+	return srv.serve(false, nil, srv.Server.Serve)
 }
 
 // ListenAndServeTLS directly proxies the http.Server.ListenAndServeTLS method. It starts the
 // server with TLS support on the configured address and port.
 func (srv *Server) ListenAndServeTLS(cert string, key string) error {
 	srv.setBestRouter()
-	return srv.Server.ListenAndServeTLS(cert, key)
+	return srv.serve(true, nil, func(ln net.Listener) error {
+		// ServeTLS leaves the listener open if the certificate can't be loaded.
+		defer func() { _ = ln.Close() }()
+		return srv.Server.ServeTLS(ln, cert, key)
+	})
+}
+
+// Serve proxies the http.Server.Serve method. It starts the server without TLS support, accepting
+// connections on the passed net.Listener rather than the configured address and port. This is
+// useful for binding to an ephemeral port, or to a listener provided by the environment.
+func (srv *Server) Serve(ln net.Listener) error {
+	srv.setBestRouter()
+	return srv.serve(false, ln, srv.Server.Serve)
 }
 
-// Shutdown directly proxies the net/http.Server.Shutdown method. It will stop the Server, if
-// running.
+// Shutdown proxies the net/http.Server.Shutdown method. It will gracefully stop the Server and
+// every additional Listener, if running. The Listeners are shut down concurrently, and any
+// errors are joined together.
 func (srv *Server) Shutdown(ctx context.Context) error {
-	return srv.Server.Shutdown(ctx)
+	additional := srv.additionalServers()
+	if len(additional) == 0 {
+		return srv.Server.Shutdown(ctx)
+	}
+
+	errs := make([]error, len(additional)+1)
+	var wg sync.WaitGroup
+	for i, hs := range additional {
+		wg.Go(func() {
+			errs[i+1] = hs.Shutdown(ctx)
+		})
+	}
+	errs[0] = srv.Server.Shutdown(ctx)
+	wg.Wait()
+	return errors.Join(errs...)
 }
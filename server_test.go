@@ -5,10 +5,13 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"io"
+	"log"
 	"math/big"
 	"net"
 	"net/http"
@@ -65,6 +68,27 @@ func Test_NewServer(t *testing.T) {
 				assert.Equal(t, 120*time.Second, srv.Server.IdleTimeout)
 			},
 		},
+		{
+			name: "durations_override_seconds",
+			config: ServerConfig{
+				Host:                           "localhost",
+				Port:                           8080,
+				TCPReadTimeout:                 30,
+				TCPReadTimeoutDuration:         1500 * time.Millisecond,
+				TCPReadHeaderTimeoutDuration:   500 * time.Millisecond,
+				TCPWriteTimeout:                60,
+				TCPIdleTimeoutDuration:         time.Minute,
+				TCPWriteTimeoutPaddingDuration: 250 * time.Millisecond,
+			},
+			router: http.NewServeMux(),
+			validate: func(t *testing.T, srv *Server) {
+				assert.Equal(t, 1500*time.Millisecond, srv.Server.ReadTimeout)
+				assert.Equal(t, 500*time.Millisecond, srv.Server.ReadHeaderTimeout)
+				assert.Equal(t, 60*time.Second, srv.Server.WriteTimeout)
+				assert.Equal(t, time.Minute, srv.Server.IdleTimeout)
+				assert.Equal(t, 250*time.Millisecond, srv.writeTimeoutPadding)
+			},
+		},
 		{
 			name: "empty_host",
 			config: ServerConfig{
@@ -140,6 +164,49 @@ func Test_NewServer(t *testing.T) {
 				assert.True(t, srv.Server.DisableGeneralOptionsHandler)
 			},
 		},
+		{
+			name: "http_server_fields_are_passed_through",
+			config: ServerConfig{
+				Host:           "localhost",
+				Port:           8080,
+				MaxHeaderBytes: 4096,
+				TLSConfig:      &tls.Config{MinVersion: tls.VersionTLS13},
+				TLSNextProto:   map[string]func(*http.Server, *tls.Conn, http.Handler){},
+				ConnState:      func(net.Conn, http.ConnState) {},
+				ErrorLog:       log.New(io.Discard, "", 0),
+				BaseContext: func(net.Listener) context.Context {
+					return context.Background()
+				},
+				ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+					return ctx
+				},
+			},
+			router: http.NewServeMux(),
+			validate: func(t *testing.T, srv *Server) {
+				assert.Equal(t, 4096, srv.Server.MaxHeaderBytes)
+				require.NotNil(t, srv.Server.TLSConfig)
+				assert.Equal(t, uint16(tls.VersionTLS13), srv.Server.TLSConfig.MinVersion)
+				assert.NotNil(t, srv.Server.TLSNextProto)
+				assert.NotNil(t, srv.Server.ConnState)
+				assert.NotNil(t, srv.Server.ErrorLog)
+				assert.NotNil(t, srv.Server.BaseContext)
+				assert.NotNil(t, srv.Server.ConnContext)
+				assert.Nil(t, srv.ConnTracker)
+			},
+		},
+		{
+			name: "connection_tracking_enabled",
+			config: ServerConfig{
+				Host:             "localhost",
+				Port:             8080,
+				TrackConnections: true,
+			},
+			router: http.NewServeMux(),
+			validate: func(t *testing.T, srv *Server) {
+				assert.NotNil(t, srv.ConnTracker)
+				assert.NotNil(t, srv.Server.ConnState)
+			},
+		},
 		{
 			name: "partial_http2_config_merges_with_defaults",
 			config: ServerConfig{
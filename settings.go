@@ -0,0 +1,160 @@
+package rmhttp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------
+// SETTINGS
+// ------------------------------------------------------------------------------------------------
+
+// SettingSource describes where the value of a setting came from.
+type SettingSource string
+
+// The possible sources of a setting.
+const (
+	SourceDefault SettingSource = "default"
+	SourceEnv     SettingSource = "env"
+	SourceFile    SettingSource = "file"
+	SourceCode    SettingSource = "code"
+)
+
+// RedactedValue replaces the value of a secret Setting.
+const RedactedValue = "[REDACTED]"
+
+// A Setting describes a single effective config value, and where it came from.
+type Setting struct {
+	// Name is the path of the field within the Config, such as Server.Port.
+	Name string `json:"name"`
+	// Key is the (prefixed) environment variable name for the field, if it has one.
+	Key string `json:"key,omitempty"`
+	// Value is the effective value, formatted as a string. Secrets are replaced with
+	// RedactedValue, and values that cannot be sensibly printed (such as funcs and pointers) are
+	// shown as "(set)" if they are not nil.
+	Value  string        `json:"value"`
+	Source SettingSource `json:"source"`
+	// Origin is the environment variable or config file that the value was read from, if any.
+	Origin string `json:"origin,omitempty"`
+}
+
+// Settings returns every setting in the Config, along with its source. This is intended for
+// startup diagnostics, so values that were read from _FILE variables, or fields tagged with
+// secret:"true", are redacted.
+//
+// Sources are only recorded by LoadConfig, so any Config that has not been loaded will report
+// every setting as coming from its default.
+func (c Config) Settings() []Setting {
+	settings := []Setting{}
+	walkConfig(reflect.ValueOf(c), "", c.EnvPrefix, func(f configField) {
+		settings = append(settings, newSetting(f, c.sources[f.name]))
+	})
+	return settings
+}
+
+// newSetting creates the Setting for the passed field and origin, redacting secret values.
+func newSetting(f configField, origin configOrigin) Setting {
+	if origin.source == "" {
+		origin.source = SourceDefault
+	}
+	value := formatSettingValue(f.value)
+	if (f.secret || origin.secret) && value != "" {
+		value = RedactedValue
+	}
+	return Setting{
+		Name:   f.name,
+		Key:    f.key,
+		Value:  value,
+		Source: origin.source,
+		Origin: origin.from,
+	}
+}
+
+// configSources determines the origin of every setting in the Config. Non-zero values supplied
+// in code take precedence, as they do when the configs are merged, followed by any variable that
+// was set. Everything else is a default.
+func configSources(
+	code Config,
+	vars map[string]string,
+	origins map[string]configOrigin,
+) map[string]configOrigin {
+	sources := map[string]configOrigin{}
+	walkConfig(reflect.ValueOf(code), "", code.EnvPrefix, func(f configField) {
+		switch {
+		case !f.value.IsZero():
+			sources[f.name] = configOrigin{source: SourceCode}
+		case f.key != "" && vars[f.key] != "":
+			if origin, ok := origins[f.key]; ok {
+				sources[f.name] = origin
+			}
+		}
+	})
+	return sources
+}
+
+// configField is a single leaf field of a Config.
+type configField struct {
+	name   string
+	key    string
+	value  reflect.Value
+	secret bool
+}
+
+// walkConfig calls fn for every exported leaf field of the passed struct, recursing into nested
+// structs. Keys are built in the same way as the env package builds them.
+func walkConfig(v reflect.Value, name string, prefix string, fn func(configField)) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldName := name + field.Name
+		if field.Type.Kind() == reflect.Struct {
+			walkConfig(v.Field(i), fieldName+".", prefix+field.Tag.Get("envPrefix"), fn)
+			continue
+		}
+
+		key, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		if key != "" {
+			key = prefix + key
+		}
+		fn(configField{
+			name:   fieldName,
+			key:    key,
+			value:  v.Field(i),
+			secret: field.Tag.Get("secret") == "true",
+		})
+	}
+}
+
+// formatSettingValue formats a config value for display.
+func formatSettingValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Func, reflect.Map, reflect.Interface, reflect.Chan:
+		if v.IsNil() {
+			return ""
+		}
+		return "(set)"
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Len() == 0 {
+				return ""
+			}
+			return fmt.Sprintf("(%d bytes)", v.Len())
+		}
+		parts := make([]string, 0, v.Len())
+		for i := range v.Len() {
+			parts = append(parts, formatSettingValue(v.Index(i)))
+		}
+		return strings.Join(parts, ",")
+	case reflect.String:
+		return v.String()
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
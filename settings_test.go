@@ -0,0 +1,140 @@
+package rmhttp
+
+import (
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ------------------------------------------------------------------------------------------------
+// SETTINGS TESTS
+// ------------------------------------------------------------------------------------------------
+
+// settingsByName indexes the passed settings by name.
+func settingsByName(settings []Setting) map[string]Setting {
+	byName := map[string]Setting{}
+	for _, s := range settings {
+		byName[s.Name] = s
+	}
+	return byName
+}
+
+// Test_LoadConfig_env_prefix checks that each App only reads its own prefixed variables.
+func Test_LoadConfig_env_prefix(t *testing.T) {
+	t.Setenv("PORT", "8081")
+	t.Setenv("INTERNAL_PORT", "9091")
+	t.Setenv("INTERNAL_HTTP_TIMEOUT_MESSAGE_FILE", writeTestConfigFile(t, "message", "internal"))
+	t.Setenv(
+		"INTERNAL_CONFIG_FILE",
+		writeTestConfigFile(
+			t,
+			"internal.yaml",
+			"host: internal-host\ntcp_idle_timeout_duration: 1m\n",
+		),
+	)
+
+	public, err := LoadConfig(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, 8081, public.Server.Port)
+	assert.Equal(t, "", public.Server.Host)
+	assert.Equal(t, defaultServerConfig.TimeoutMessage, public.Server.TimeoutMessage)
+
+	internal, err := LoadConfig(Config{EnvPrefix: "INTERNAL_"})
+	require.NoError(t, err)
+	assert.Equal(t, 9091, internal.Server.Port)
+	assert.Equal(t, "internal-host", internal.Server.Host)
+	assert.Equal(t, time.Minute, internal.Server.TCPIdleTimeoutDuration)
+	assert.Equal(t, "internal", internal.Server.TimeoutMessage)
+}
+
+// Test_Config_Settings checks that every setting is listed with its source and origin, and that
+// secrets are redacted.
+func Test_Config_Settings(t *testing.T) {
+	file := writeTestConfigFile(t, "config.yaml", "host: file-host\n")
+	t.Setenv("APP_CONFIG_FILE", file)
+	t.Setenv("APP_PORT", "9000")
+	t.Setenv("APP_HTTP_TIMEOUT_MESSAGE_FILE", writeTestConfigFile(t, "message", "hunter2"))
+
+	cfg, err := LoadConfig(Config{
+		EnvPrefix: "APP_",
+		Server: ServerConfig{
+			RequestTimeoutDuration: 5 * time.Second,
+			ConnState:              func(c net.Conn, s http.ConnState) {},
+		},
+	})
+	require.NoError(t, err)
+
+	settings := settingsByName(cfg.Settings())
+	tests := []struct {
+		name     string
+		expected Setting
+	}{
+		{"Server.Host", Setting{"Server.Host", "APP_HOST", "file-host", SourceFile, file}},
+		{"Server.Port", Setting{"Server.Port", "APP_PORT", "9000", SourceEnv, "APP_PORT"}},
+		{
+			"Server.TimeoutMessage",
+			Setting{
+				"Server.TimeoutMessage",
+				"APP_HTTP_TIMEOUT_MESSAGE",
+				RedactedValue,
+				SourceEnv,
+				"APP_HTTP_TIMEOUT_MESSAGE_FILE",
+			},
+		},
+		{
+			"Server.RequestTimeoutDuration",
+			Setting{
+				"Server.RequestTimeoutDuration",
+				"APP_HTTP_REQUEST_TIMEOUT_DURATION",
+				"5s",
+				SourceCode,
+				"",
+			},
+		},
+		{
+			"Server.TCPIdleTimeout",
+			Setting{"Server.TCPIdleTimeout", "APP_TCP_IDLE_TIMEOUT", "120", SourceDefault, ""},
+		},
+		{"Server.ConnState", Setting{"Server.ConnState", "", "(set)", SourceCode, ""}},
+		{"Server.TLSConfig", Setting{"Server.TLSConfig", "", "", SourceDefault, ""}},
+		{
+			"Server.ClientAuth.Mode",
+			Setting{"Server.ClientAuth.Mode", "APP_TLS_CLIENT_AUTH", "", SourceDefault, ""},
+		},
+		{"EnvPrefix", Setting{"EnvPrefix", "", "APP_", SourceCode, ""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, settings[test.name])
+		})
+	}
+}
+
+// Test_Config_Settings_secret_tag checks that fields tagged as secret are always redacted.
+func Test_Config_Settings_secret_tag(t *testing.T) {
+	var settings []Setting
+	walkConfig(reflect.ValueOf(struct {
+		Token string `env:"TOKEN" secret:"true"`
+		Empty string `env:"EMPTY" secret:"true"`
+	}{Token: "hunter2"}), "", "APP_", func(f configField) {
+		settings = append(settings, newSetting(f, configOrigin{}))
+	})
+
+	require.Len(t, settings, 2)
+	assert.Equal(t, Setting{"Token", "APP_TOKEN", RedactedValue, SourceDefault, ""}, settings[0])
+	assert.Equal(t, "", settings[1].Value)
+}
+
+// Test_App_Settings checks that the App exposes the settings it was configured with.
+func Test_App_Settings(t *testing.T) {
+	app := New(Config{Server: ServerConfig{Port: 9999}})
+	settings := settingsByName(app.Settings())
+	assert.Equal(t, "9999", settings["Server.Port"].Value)
+	assert.Equal(t, SourceCode, settings["Server.Port"].Source)
+}
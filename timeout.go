@@ -1,7 +1,9 @@
 package rmhttp
 
 import (
+	"context"
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,8 +36,72 @@ func NewTimeout(duration time.Duration, message string) Timeout {
 // handler in the stack with a timeout handler. The http.TimeoutHandler is created once when the
 // middleware is applied (at compile time), not per-request, avoiding per-request goroutine,
 // channel, and context allocations.
+//
+// Any functions registered on the request context with OnTimeout are called when the timeout
+// fires.
 func TimeoutMiddleware(timeout Timeout) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return http.TimeoutHandler(next, timeout.Duration, timeout.Message)
+		th := http.TimeoutHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r)
+				if state, ok := r.Context().Value(timeoutStateKey{}).(*timeoutState); ok {
+					state.finished.Store(true)
+				}
+			}),
+			timeout.Duration,
+			timeout.Message,
+		)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hooks, ok := r.Context().Value(timeoutHookKey{}).(*timeoutHook)
+			if !ok {
+				th.ServeHTTP(w, r)
+				return
+			}
+
+			state := &timeoutState{}
+			th.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), timeoutStateKey{}, state)))
+
+			// The timeout handler returns early, before the wrapped handler has finished, on
+			// either a timeout or the client going away. Only the former is a timeout.
+			if !state.finished.Load() && r.Context().Err() == nil {
+				for hook := hooks; hook != nil; hook = hook.parent {
+					hook.fn(timeout)
+				}
+			}
+		})
 	}
 }
+
+// ------------------------------------------------------------------------------------------------
+// TIMEOUT HOOKS
+// ------------------------------------------------------------------------------------------------
+
+// timeoutHookKey is the context key for the registered timeout hooks.
+type timeoutHookKey struct{}
+
+// timeoutStateKey is the context key for the per-request timeout state.
+type timeoutStateKey struct{}
+
+// timeoutHook is a function registered with OnTimeout. Hooks are chained, so that each middleware
+// in the stack can register its own.
+type timeoutHook struct {
+	fn     func(Timeout)
+	parent *timeoutHook
+}
+
+// timeoutState records whether the handler wrapped by the timeout handler finished.
+type timeoutState struct {
+	finished atomic.Bool
+}
+
+// OnTimeout returns a copy of the passed context, which will cause fn to be called with the
+// applied Timeout if a Route timeout fires while handling a request that uses the context. This
+// allows middleware that runs before the timeout, such as metrics or tracing, to record it.
+//
+// fn is called after the timeout response has been written, from the goroutine serving the
+// request.
+func OnTimeout(ctx context.Context, fn func(Timeout)) context.Context {
+	parent, _ := ctx.Value(timeoutHookKey{}).(*timeoutHook)
+	return context.WithValue(ctx, timeoutHookKey{}, &timeoutHook{fn: fn, parent: parent})
+}
@@ -1,6 +1,7 @@
 package rmhttp
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -97,3 +98,55 @@ func Test_Timeout_applyTimeout(t *testing.T) {
 		assert.Equal(t, http.StatusOK, res.StatusCode, "they should be equal")
 	})
 }
+
+// Test_OnTimeout checks that registered timeout hooks are called when a timeout fires, and only
+// then.
+func Test_OnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	fast := http.HandlerFunc(createTestHandlerFunc(http.StatusOK, "fast"))
+	timeout := NewTimeout(20*time.Millisecond, "timed out")
+
+	tests := []struct {
+		name          string
+		handler       http.Handler
+		expectedCalls []string
+	}{
+		{"hooks are called on timeout", slow, []string{"inner", "outer"}},
+		{"hooks are not called without a timeout", fast, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			ctx := OnTimeout(context.Background(), func(to Timeout) {
+				assert.Equal(t, timeout, to)
+				calls = append(calls, "outer")
+			})
+			ctx = OnTimeout(ctx, func(Timeout) {
+				calls = append(calls, "inner")
+			})
+
+			req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/timeout", nil)
+			w := httptest.NewRecorder()
+			TimeoutMiddleware(timeout)(test.handler).ServeHTTP(w, req)
+			assert.Equal(t, test.expectedCalls, calls)
+		})
+	}
+
+	t.Run("hooks are not called when the client goes away", func(t *testing.T) {
+		called := false
+		ctx, cancel := context.WithCancel(OnTimeout(context.Background(), func(Timeout) {
+			called = true
+		}))
+		cancel()
+
+		req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/timeout", nil)
+		TimeoutMiddleware(timeout)(slow).ServeHTTP(httptest.NewRecorder(), req)
+		assert.False(t, called)
+	})
+}